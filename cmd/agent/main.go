@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"log/slog"
 	"os"
 	"os/signal"
 	"sync"
@@ -11,6 +12,7 @@ import (
 
 	"github.com/eac0de/getmetrics/internal/agent"
 	"github.com/eac0de/getmetrics/internal/config"
+	"github.com/eac0de/getmetrics/pkg/logging"
 	"github.com/eac0de/getmetrics/pkg/utils"
 )
 
@@ -20,6 +22,12 @@ var (
 	buildCommit  string
 )
 
+// fatal записывает ошибку в лог и завершает программу.
+func fatal(logger *slog.Logger, msg string, err error) {
+	logger.Error(msg, "error", err)
+	os.Exit(1)
+}
+
 func main() {
 	fmt.Printf("Build version: %s\n", utils.GetValueOrDefault(buildVersion))
 	fmt.Printf("Build date: %s\n", utils.GetValueOrDefault(buildDate))
@@ -30,17 +38,28 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
-	a := agent.NewAgent(cfg)
+	logger, err := logging.New(os.Stdout, cfg.LogLevel, cfg.LogFormat)
+	if err != nil {
+		log.Fatal(err)
+	}
+	slog.SetDefault(logger)
+	a, err := agent.NewAgent(cfg, logger)
+	if err != nil {
+		fatal(logger, "Agent init error", err)
+	}
+	defer a.Close()
 	var wg sync.WaitGroup
-	wg.Add(2)
+	wg.Add(3)
 	go a.StartPoll(ctx, &wg)
+	go a.StartPollSystem(ctx, &wg)
 	go a.StartSendReport(ctx, &wg)
 
-	log.Println("Agent is running. Press Ctrl+C to stop")
+	logger.Info("Agent is running", "server", cfg.ServerURL, "transport", cfg.Transport)
 
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT)
 	<-sigChan
 	cancel()
 	wg.Wait()
+	logger.Info("Agent stopped")
 }
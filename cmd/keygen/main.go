@@ -0,0 +1,30 @@
+// Генерирует пару ключей RSA для шифрования отчетов агента.
+// Открытый ключ передается агенту через -crypto-key, закрытый - серверу через -crypto-key.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/eac0de/getmetrics/pkg/encryptor"
+)
+
+func main() {
+	bits := flag.Int("bits", 4096, "RSA key size in bits")
+	privatePath := flag.String("private", "private.pem", "path to write private key")
+	publicPath := flag.String("public", "public.pem", "path to write public key")
+	flag.Parse()
+
+	privatePEM, publicPEM, err := encryptor.GenerateKeyPair(*bits)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*privatePath, privatePEM, 0600); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*publicPath, publicPEM, 0644); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Keys are written to %s and %s", *privatePath, *publicPath)
+}
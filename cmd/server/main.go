@@ -2,24 +2,51 @@ package main
 
 import (
 	"context"
+	"crypto/rsa"
 	"fmt"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	_ "net/http/pprof"
 
+	"github.com/eac0de/getmetrics/internal/alerting"
+	"github.com/eac0de/getmetrics/internal/api/grpchandlers"
 	"github.com/eac0de/getmetrics/internal/api/handlers"
+	"github.com/eac0de/getmetrics/internal/api/pb"
 	"github.com/eac0de/getmetrics/internal/api/server"
 	"github.com/eac0de/getmetrics/internal/config"
+	"github.com/eac0de/getmetrics/internal/graphite"
+	"github.com/eac0de/getmetrics/internal/pubsub"
+	"github.com/eac0de/getmetrics/internal/statsd"
 	"github.com/eac0de/getmetrics/internal/storage/fileservice"
 	"github.com/eac0de/getmetrics/internal/storage/memstore"
 	"github.com/eac0de/getmetrics/internal/storage/pgstore"
+	"github.com/eac0de/getmetrics/pkg/encryptor"
+	"github.com/eac0de/getmetrics/pkg/interceptors"
+	"github.com/eac0de/getmetrics/pkg/logging"
 	"github.com/eac0de/getmetrics/pkg/middlewares"
 	"github.com/eac0de/getmetrics/pkg/utils"
 	"github.com/go-chi/chi/v5"
+	"google.golang.org/grpc"
+)
+
+const (
+	// defaultAlertEvalInterval - интервал вычисления правил оповещений, если он не задан в конфигурации.
+	defaultAlertEvalInterval = 10 * time.Second
+	// defaultStatsDFlushInterval - интервал записи метрик StatsD, если он не задан в конфигурации.
+	defaultStatsDFlushInterval = 10 * time.Second
+	// defaultGraphiteIdleTimeout - время до закрытия неактивного соединения Graphite, если оно не задано в конфигурации.
+	defaultGraphiteIdleTimeout = 5 * time.Minute
+	// defaultShutdownTimeout - время на завершение начатых запросов при остановке, если оно не задано в конфигурации.
+	defaultShutdownTimeout = 10 * time.Second
 )
 
 var (
@@ -32,27 +59,123 @@ func setupRouter(
 	metricsStore handlers.IMetricsStore,
 	database handlers.IDatabase,
 	secretKey string,
+	privateKey *rsa.PrivateKey,
+	trustedSubnet *net.IPNet,
+	alerts handlers.IAlertsSource,
+	stream handlers.IMetricsStream,
+	logger *slog.Logger,
 ) *chi.Mux {
-	mh := handlers.NewMetricsHandlers(metricsStore, secretKey)
+	mh := handlers.NewMetricsHandlers(metricsStore, secretKey, logger)
+	mh.Alerts = alerts
+	mh.Stream = stream
 	dh := handlers.NewDatabaseHandlers(database)
 
 	r := chi.NewRouter()
-	r.Use(middlewares.LoggerMiddleware)
-	r.Use(middlewares.GetCheckSignMiddleware(secretKey))
+	r.Use(middlewares.RequestIDMiddleware)
+	r.Use(middlewares.GetLoggerMiddleware(logger))
+
+	// Prometheus не умеет подписывать запросы, поэтому /metrics не проверяет подпись
+	r.With(middlewares.GetGzipMiddleware("text/plain application/openmetrics-text")).
+		Get("/metrics", mh.PrometheusMetricsHandler())
+
+	// OTel SDK, коллектор и Prometheus не умеют подписывать и шифровать запросы, поэтому прием
+	// OTLP и remote_write без подписи и шифрования разрешен, только если задана доверенная подсеть.
+	// Иначе эти маршруты защищены так же, как остальные обновления.
+	r.Group(func(r chi.Router) {
+		if trustedSubnet == nil {
+			r.Use(middlewares.GetDecryptMiddleware(privateKey, true))
+			r.Use(middlewares.GetCheckSignMiddleware(secretKey))
+		}
+		r.Use(middlewares.GetTrustedSubnetMiddleware(trustedSubnet))
+		r.Use(middlewares.GetGzipMiddleware("application/json application/x-protobuf"))
+		r.Post("/v1/metrics", mh.OTLPMetricsHandler())
+		r.Post("/api/v1/write", mh.PrometheusRemoteWriteHandler())
+	})
+
+	// EventSource в браузере не может добавить заголовок HashSHA256, поэтому поток не проверяет подпись
+	r.With(middlewares.GetGzipMiddleware("application/json text/html")).
+		Get("/api/v1/stream", mh.StreamHandler())
+
 	contentTypesForCompress := "application/json text/html"
-	r.Use(middlewares.GetGzipMiddleware(contentTypesForCompress))
+	// Если задан закрытый ключ, эти маршруты принимают метрики только в зашифрованных запросах.
+	// Без шифрования метрики принимаются только из доверенной подсети, см. checkPlaintextIngestion.
+	r.Group(func(r chi.Router) {
+		r.Use(middlewares.GetDecryptMiddleware(privateKey, true))
+		r.Use(middlewares.GetCheckSignMiddleware(secretKey))
+		r.Use(middlewares.GetGzipMiddleware(contentTypesForCompress))
+		r.Use(middlewares.GetTrustedSubnetMiddleware(trustedSubnet))
+		r.Post("/update/{metricType}/{metricName}/{metricValue}", mh.UpdateMetricHandler())
+		r.Post("/update/", mh.UpdateMetricJSONHandler())
+		r.Post("/updates/", mh.UpdateMetricsJSONHandler())
+		r.Post("/write", mh.InfluxWriteHandler())
+	})
 
-	r.Get("/", mh.ShowMetricsSummaryHandler())
-	r.Post("/update/{metricType}/{metricName}/{metricValue}", mh.UpdateMetricHandler())
-	r.Post("/update/", mh.UpdateMetricJSONHandler())
-	r.Post("/updates/", mh.UpdateMetricsJSONHandler())
-	r.Get("/value/{metricType}/{metricName}", mh.GetMetricHandler())
-	r.Post("/value/", mh.GetMetricJSONHandler())
+	r.Group(func(r chi.Router) {
+		r.Use(middlewares.GetDecryptMiddleware(privateKey, false))
+		r.Use(middlewares.GetCheckSignMiddleware(secretKey))
+		r.Use(middlewares.GetGzipMiddleware(contentTypesForCompress))
 
-	r.Get("/ping", dh.PingHandler())
+		r.Get("/", mh.ShowMetricsSummaryHandler())
+		r.Get("/value/{metricType}/{metricName}", mh.GetMetricHandler())
+		r.Post("/value/", mh.GetMetricJSONHandler())
+		r.Get("/api/v1/query_range", mh.QueryRangeHandler())
+		r.Get("/api/v1/alerts", mh.AlertsHandler())
+
+		r.Get("/ping", dh.PingHandler())
+	})
 	return r
 }
 
+func setupGRPCServer(
+	metricsStore handlers.IMetricsStore,
+	secretKey string,
+	trustedSubnet *net.IPNet,
+	logger *slog.Logger,
+) *grpc.Server {
+	grpcServer := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(
+			interceptors.RequestIDInterceptor,
+			interceptors.GetLoggerInterceptor(logger),
+			interceptors.GetTrustedSubnetInterceptor(
+				trustedSubnet,
+				pb.MetricsService_UpdateMetrics_FullMethodName,
+			),
+			interceptors.GetCheckSignInterceptor(secretKey),
+		),
+	)
+	pb.RegisterMetricsServiceServer(grpcServer, grpchandlers.NewMetricsServer(metricsStore, logger))
+	return grpcServer
+}
+
+// checkPlaintextIngestion возвращает ошибку, если задан закрытый ключ, а включенные приемники
+// без поддержки шифрования (gRPC, StatsD, Graphite) принимали бы метрики с любого адреса.
+// С закрытым ключом такие приемники запускаются, только если задана доверенная подсеть.
+func checkPlaintextIngestion(cfg *config.AppConfig, privateKey *rsa.PrivateKey, trustedSubnet *net.IPNet) error {
+	if privateKey == nil || trustedSubnet != nil {
+		return nil
+	}
+	var receivers []string
+	if cfg.GRPCAddr != "" {
+		receivers = append(receivers, "gRPC")
+	}
+	if cfg.StatsD.Addr != "" {
+		receivers = append(receivers, "StatsD")
+	}
+	if cfg.Graphite.Addr != "" {
+		receivers = append(receivers, "Graphite")
+	}
+	if len(receivers) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s do not support encryption, set trusted subnet or unset crypto key", strings.Join(receivers, ", "))
+}
+
+// fatal записывает ошибку в лог и завершает программу.
+func fatal(logger *slog.Logger, msg string, err error) {
+	logger.Error(msg, "error", err)
+	os.Exit(1)
+}
+
 func main() {
 	fmt.Printf("Build version: %s\n", utils.GetValueOrDefault(buildVersion))
 	fmt.Printf("Build date: %s\n", utils.GetValueOrDefault(buildDate))
@@ -65,42 +188,189 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	logger, err := logging.New(os.Stdout, cfg.LogLevel, cfg.LogFormat)
+	if err != nil {
+		log.Fatal(err)
+	}
+	slog.SetDefault(logger)
+
 	var metricStore handlers.IMetricsStore
 	var database handlers.IDatabase
+	var fileService *fileservice.FileService
+	var background sync.WaitGroup
 
-	pgStore, err := pgstore.New(ctx, cfg.DatabaseDSN)
+	pgStore, err := pgstore.New(ctx, cfg.DatabaseDSN, cfg.Retry.Policy(), logger)
 	if err != nil {
-		log.Printf("database connection error: %s\n", err.Error())
+		logger.Warn("Database connection error, metrics are stored in memory", "error", err)
 		memStore := memstore.New()
 		metricStore = memStore
-		fileService, err := fileservice.New(memStore, cfg.FileStoragePath)
-		if err != nil {
-			log.Printf("fileservice init error: %s\n", err.Error())
-		} else {
-			go fileService.StartSavingMetrics(ctx, cfg.StoreInterval)
-			defer fileService.SaveMetrics()
+		if cfg.FileStoragePath != "" {
+			// Поврежденные файлы не перезаписываются, чтобы данные можно было восстановить вручную
+			fileService, err = fileservice.New(memStore, cfg.FileStoragePath, cfg.StoreInterval, cfg.Restore, logger)
+			if err != nil {
+				fatal(logger, "Fileservice init error", err)
+			}
+			metricStore = fileService
+			background.Add(1)
+			go func() {
+				defer background.Done()
+				fileService.StartSavingMetrics(ctx)
+			}()
 		}
 	} else {
 		metricStore = pgStore
 		database = pgStore
-		defer pgStore.Close()
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			pgStore.StartPruningHistory(ctx)
+		}()
+	}
+
+	// Все обновления проходят через hub, чтобы подписчики потока видели их независимо от источника
+	hub := pubsub.NewHub(metricStore, logger)
+	metricStore = hub
+
+	var privateKey *rsa.PrivateKey
+	if cfg.PrivateKeyPath != "" {
+		privateKey, err = encryptor.LoadPrivateKey(cfg.PrivateKeyPath)
+		if err != nil {
+			fatal(logger, "Load private key error", err)
+		}
+	}
+
+	var trustedSubnet *net.IPNet
+	if cfg.TrustedSubnet != "" {
+		_, trustedSubnet, err = net.ParseCIDR(cfg.TrustedSubnet)
+		if err != nil {
+			fatal(logger, "Parse trusted subnet error", err)
+		}
 	}
 
-	r := setupRouter(metricStore, database, cfg.SecretKey)
-	s := server.New(cfg.Addr)
+	err = checkPlaintextIngestion(cfg, privateKey, trustedSubnet)
+	if err != nil {
+		fatal(logger, "Plaintext ingestion check error", err)
+	}
+
+	var alerts handlers.IAlertsSource
+	if cfg.Alerting.RulesPath != "" {
+		rules, err := alerting.LoadRules(cfg.Alerting.RulesPath)
+		if err != nil {
+			fatal(logger, "Load alert rules error", err)
+		}
+		notifier := alerting.NewWebhookNotifier(cfg.Alerting.Webhooks, cfg.Retry.Policy())
+		engine := alerting.NewEngine(metricStore, rules, notifier, logger)
+		evalInterval := cfg.Alerting.EvalInterval
+		if evalInterval <= 0 {
+			evalInterval = defaultAlertEvalInterval
+		}
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			engine.Start(ctx, evalInterval)
+		}()
+		alerts = engine
+	}
+
+	if cfg.StatsD.Addr != "" {
+		listener, err := statsd.New(cfg.StatsD.Addr, metricStore, cfg.StatsD.HistogramBuckets, logger)
+		if err != nil {
+			fatal(logger, "StatsD listener init error", err)
+		}
+		listener.TrustedSubnet = trustedSubnet
+		err = listener.Listen()
+		if err != nil {
+			fatal(logger, "StatsD listener init error", err)
+		}
+		flushInterval := cfg.StatsD.FlushInterval
+		if flushInterval <= 0 {
+			flushInterval = defaultStatsDFlushInterval
+		}
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			listener.Start(ctx, flushInterval)
+		}()
+		logger.Info("StatsD listener is running", "addr", listener.Addr)
+	}
+
+	if cfg.Graphite.Addr != "" {
+		idleTimeout := cfg.Graphite.IdleTimeout
+		if idleTimeout <= 0 {
+			idleTimeout = defaultGraphiteIdleTimeout
+		}
+		listener := graphite.New(cfg.Graphite.Addr, metricStore, idleTimeout, logger)
+		listener.TrustedSubnet = trustedSubnet
+		err := listener.Listen()
+		if err != nil {
+			fatal(logger, "Graphite listener init error", err)
+		}
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			listener.Start(ctx)
+		}()
+		logger.Info("Graphite listener is running", "addr", listener.Addr)
+	}
+
+	r := setupRouter(metricStore, database, cfg.SecretKey, privateKey, trustedSubnet, alerts, hub, logger)
 	go func() {
 		// Запускаем pprof на отдельном порту, если это необходимо
 		http.ListenAndServe(":6060", nil)
 	}()
-	if cfg.PrivateKeyPath == "" {
-		go s.Run(r)
-		log.Printf("Server http://%s is running. Press Ctrl+C to stop", s.Addr)
-	} else {
-		go s.RunTLS(r, cfg.PrivateKeyPath)
-		log.Printf("Server https://%s is running. Press Ctrl+C to stop", s.Addr)
+	serverErrs := make(chan error, 2)
+	s := server.New(cfg.Addr, r)
+	go func() { serverErrs <- s.Run() }()
+	logger.Info("HTTP server is running", "addr", s.Addr)
+	var gs *server.GRPCServer
+	if cfg.GRPCAddr != "" {
+		gs = server.NewGRPC(cfg.GRPCAddr, setupGRPCServer(metricStore, cfg.SecretKey, trustedSubnet, logger))
+		go func() { serverErrs <- gs.Run() }()
+		logger.Info("gRPC server is running", "addr", gs.Addr)
 	}
 
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT)
-	<-sigChan
+	select {
+	case sig := <-sigChan:
+		logger.Info("Received signal, shutting down", "signal", sig.String())
+	case err := <-serverErrs:
+		logger.Error("Server error, shutting down", "error", err)
+	}
+
+	shutdownTimeout := cfg.ShutdownTimeout
+	if shutdownTimeout <= 0 {
+		shutdownTimeout = defaultShutdownTimeout
+	}
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer shutdownCancel()
+
+	// Хранилище сбрасывается только после того, как серверы перестали принимать запросы
+	// и завершили начатые, иначе последние обновления могут не попасть в файл.
+	// Потоки обновлений не завершаются сами, поэтому подписчики отключаются заранее.
+	hub.Close()
+	err = s.Shutdown(shutdownCtx)
+	if err != nil {
+		logger.Error("HTTP server shutdown error", "error", err)
+	}
+	if gs != nil {
+		err = gs.Shutdown(shutdownCtx)
+		if err != nil {
+			logger.Error("gRPC server shutdown error", "error", err)
+		}
+	}
+	cancel()
+	background.Wait()
+
+	if fileService != nil {
+		err = fileService.SaveSnapshot()
+		if err != nil {
+			logger.Error("Metrics saving to file error", "error", err)
+		}
+		fileService.Close()
+	}
+	if pgStore != nil {
+		pgStore.Close()
+	}
+	logger.Info("Server stopped")
 }
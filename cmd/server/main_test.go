@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eac0de/getmetrics/internal/config"
+	"github.com/eac0de/getmetrics/internal/models"
+	"github.com/eac0de/getmetrics/internal/pubsub"
+	"github.com/eac0de/getmetrics/internal/storage/memstore"
+	"github.com/eac0de/getmetrics/pkg/encryptor"
+	"github.com/eac0de/getmetrics/pkg/remotewrite"
+	"github.com/golang/snappy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// testSecretKey - ключ подписи, с которым запросы без HashSHA256 отклоняются группой с проверкой подписи.
+const testSecretKey = "secret"
+
+func TestRouterRequiresSign(t *testing.T) {
+	r := setupRouter(memstore.New(), nil, testSecretKey, nil, nil, nil, nil, slog.Default())
+	req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader([]byte(`[]`)))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestRouterRequiresEncryption(t *testing.T) {
+	privateKey, err := encryptor.LoadPrivateKey("../../server.key")
+	require.NoError(t, err)
+	r := setupRouter(memstore.New(), nil, "", privateKey, nil, nil, nil, slog.Default())
+
+	req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader([]byte(`[]`)))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// Чтение метрик не требует шифрования
+	req = httptest.NewRequest(http.MethodGet, "/value/gauge/Alloc", nil)
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestCheckPlaintextIngestion(t *testing.T) {
+	privateKey, err := encryptor.LoadPrivateKey("../../server.key")
+	require.NoError(t, err)
+	_, trustedSubnet, err := net.ParseCIDR("192.168.1.0/24")
+	require.NoError(t, err)
+	cfg := &config.AppConfig{GRPCAddr: ":3200"}
+	cfg.StatsD.Addr = ":8125"
+
+	err = checkPlaintextIngestion(cfg, privateKey, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "gRPC, StatsD")
+	assert.NoError(t, checkPlaintextIngestion(cfg, privateKey, trustedSubnet))
+	assert.NoError(t, checkPlaintextIngestion(cfg, nil, nil))
+	assert.NoError(t, checkPlaintextIngestion(&config.AppConfig{}, privateKey, nil))
+}
+
+func TestRouterOTLPSign(t *testing.T) {
+	body, err := proto.Marshal(&colmetricspb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{{
+			ScopeMetrics: []*metricspb.ScopeMetrics{{
+				Metrics: []*metricspb.Metric{{
+					Name: "queue_size",
+					Data: &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: []*metricspb.NumberDataPoint{
+						{Value: &metricspb.NumberDataPoint_AsDouble{AsDouble: 3}},
+					}}},
+				}},
+			}},
+		}},
+	})
+	require.NoError(t, err)
+	_, trustedSubnet, err := net.ParseCIDR("192.168.1.0/24")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		trustedSubnet *net.IPNet
+		realIP        string
+		status        int
+	}{
+		{name: "unsigned without trusted subnet", status: http.StatusBadRequest},
+		{name: "trusted address", trustedSubnet: trustedSubnet, realIP: "192.168.1.10", status: http.StatusOK},
+		{name: "untrusted address", trustedSubnet: trustedSubnet, realIP: "10.0.0.1", status: http.StatusForbidden},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := memstore.New()
+			r := setupRouter(store, nil, testSecretKey, nil, test.trustedSubnet, nil, nil, slog.Default())
+			req := httptest.NewRequest(http.MethodPost, "/v1/metrics", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/x-protobuf")
+			if test.realIP != "" {
+				req.Header.Set("X-Real-IP", test.realIP)
+			}
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			assert.Equal(t, test.status, rec.Code)
+
+			_, err := store.GetMetric(context.Background(), "queue_size", models.Gauge, nil)
+			if test.status == http.StatusOK {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestRouterRemoteWriteSign(t *testing.T) {
+	body := (&remotewrite.WriteRequest{Timeseries: []remotewrite.TimeSeries{{
+		Labels:  []remotewrite.Label{{Name: "__name__", Value: "up"}},
+		Samples: []remotewrite.Sample{{Value: 1, Timestamp: 1000}},
+	}}}).Marshal()
+	_, trustedSubnet, err := net.ParseCIDR("192.168.1.0/24")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		trustedSubnet *net.IPNet
+		realIP        string
+		status        int
+	}{
+		{name: "unsigned without trusted subnet", status: http.StatusBadRequest},
+		{name: "trusted address", trustedSubnet: trustedSubnet, realIP: "192.168.1.10", status: http.StatusNoContent},
+		{name: "untrusted address", trustedSubnet: trustedSubnet, realIP: "10.0.0.1", status: http.StatusForbidden},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := memstore.New()
+			r := setupRouter(store, nil, testSecretKey, nil, test.trustedSubnet, nil, nil, slog.Default())
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/write", bytes.NewReader(snappy.Encode(nil, body)))
+			req.Header.Set("Content-Type", "application/x-protobuf")
+			req.Header.Set("Content-Encoding", "snappy")
+			if test.realIP != "" {
+				req.Header.Set("X-Real-IP", test.realIP)
+			}
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			assert.Equal(t, test.status, rec.Code)
+
+			_, err := store.GetMetric(context.Background(), "up", models.Gauge, nil)
+			if test.status == http.StatusNoContent {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestRouterStreamWithoutSign(t *testing.T) {
+	hub := pubsub.NewHub(memstore.New(), slog.Default())
+	defer hub.Close()
+	server := httptest.NewServer(setupRouter(hub, nil, testSecretKey, nil, nil, nil, hub, slog.Default()))
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/api/v1/stream?match=cpu*", nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	assert.Empty(t, resp.Header.Get("Content-Encoding"))
+
+	// Заголовки получены, значит подписка уже создана
+	value := 0.5
+	require.NoError(t, hub.SaveMetric(context.Background(), models.Metric{ID: "cpu", MType: models.Gauge, Value: &value}))
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "event: metric\n", line)
+}
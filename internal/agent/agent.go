@@ -3,40 +3,155 @@ package agent
 import (
 	"context"
 	"crypto/hmac"
+	"crypto/rsa"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
 	"runtime"
 	"sync"
 	"time"
 
+	"github.com/eac0de/getmetrics/internal/agent/spool"
+	"github.com/eac0de/getmetrics/internal/api/pb"
 	"github.com/eac0de/getmetrics/internal/config"
 	"github.com/eac0de/getmetrics/internal/models"
 	"github.com/eac0de/getmetrics/pkg/compressor"
+	"github.com/eac0de/getmetrics/pkg/encryptor"
+	"github.com/eac0de/getmetrics/pkg/requestid"
+	"github.com/eac0de/getmetrics/pkg/retry"
 	"github.com/go-resty/resty/v2"
+	"google.golang.org/grpc"
 )
 
+const (
+	// QueuePolicyDrop отбрасывает новый отчет, если очередь отправки заполнена.
+	QueuePolicyDrop = "drop"
+	// QueuePolicyCoalesce объединяет самый старый отчет в очереди с новым.
+	QueuePolicyCoalesce = "coalesce"
+)
+
+// defaultShutdownTimeout - время на отправку последних отчетов при остановке агента.
+const defaultShutdownTimeout = 10 * time.Second
+
 type Agent struct {
-	cfg       *config.AgentConfig
-	client    *resty.Client
-	metrics   *Metric
-	pollCount int64
+	cfg         *config.AgentConfig
+	client      *resty.Client
+	grpcConn    *grpc.ClientConn
+	grpcClient  pb.MetricsServiceClient
+	publicKey   *rsa.PublicKey
+	retryPolicy retry.Policy
+	mu          sync.Mutex
+	metrics     *Metric
+	addMetrics  *AddMetrics
+	prevCPUs    []cpuTimes
+	pollCount   int64
+	// reportedPollCount - значение pollCount, уже учтенное в отправленных отчетах.
+	reportedPollCount int64
+	// reportedGCPauses - распределение пауз GC, уже учтенное в отправленных отчетах.
+	reportedGCPauses models.HistogramValue
+	// droppedDeltas - приращения counter и histogram из отчетов, отброшенных при
+	// переполнении очереди. Добавляются к следующему отчету.
+	droppedDeltas []models.Metric
+	reports       chan []models.Metric
+	spool         *spool.Spool
+	replayMu      sync.Mutex
+	// realIP - адрес агента, передаваемый серверу для проверки доверенной подсети.
+	realIP string
+	// labels - метки host и instance, добавляемые ко всем метрикам отчета.
+	labels models.Labels
+	logger *slog.Logger
 }
 
-func NewAgent(cfg *config.AgentConfig) *Agent {
-	ServerURLProtocol := "http"
+func NewAgent(cfg *config.AgentConfig, logger *slog.Logger) (*Agent, error) {
+	switch cfg.Transport {
+	case "":
+		cfg.Transport = TransportHTTP
+	case TransportHTTP, TransportGRPC:
+	default:
+		return nil, fmt.Errorf("unknown transport %q, expected %s or %s", cfg.Transport, TransportHTTP, TransportGRPC)
+	}
+	// Отчеты по gRPC не шифруются, поэтому с открытым ключом отправлять их можно только по HTTP
+	if cfg.Transport == TransportGRPC && cfg.PublicKeyPath != "" {
+		return nil, fmt.Errorf("transport %s does not support payload encryption, unset crypto key or use %s", TransportGRPC, TransportHTTP)
+	}
+	var publicKey *rsa.PublicKey
 	if cfg.PublicKeyPath != "" {
-		ServerURLProtocol = "https"
+		var err error
+		publicKey, err = encryptor.LoadPublicKey(cfg.PublicKeyPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+	serverAddr := cfg.ServerURL
+	if cfg.Transport == TransportGRPC {
+		serverAddr = cfg.GRPCAddr
+	}
+	realIP, err := outboundIP(serverAddr)
+	if err != nil {
+		logger.Warn("Unable to determine agent address", "error", err)
+	}
+	cfg.ServerURL = fmt.Sprintf("http://%s", cfg.ServerURL)
+	if cfg.RateLimit < 1 {
+		cfg.RateLimit = 1
+	}
+	if cfg.QueueSize < 1 {
+		cfg.QueueSize = 1
+	}
+	if cfg.ShutdownTimeout <= 0 {
+		cfg.ShutdownTimeout = defaultShutdownTimeout
+	}
+	if len(cfg.HistogramBuckets) == 0 {
+		cfg.HistogramBuckets = defaultHistogramBuckets
+	}
+	histogram := models.HistogramValue{Bounds: cfg.HistogramBuckets, Counts: make([]uint64, len(cfg.HistogramBuckets)+1)}
+	if err := histogram.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.QueuePolicy != QueuePolicyCoalesce {
+		cfg.QueuePolicy = QueuePolicyDrop
+	}
+	var reportSpool *spool.Spool
+	if cfg.SpoolDir != "" {
+		var err error
+		reportSpool, err = spool.New(cfg.SpoolDir, cfg.SpoolMaxSize)
+		if err != nil {
+			return nil, err
+		}
 	}
-	cfg.ServerURL = fmt.Sprintf("%s://%s", ServerURLProtocol, cfg.ServerURL)
 	client := resty.New()
-	return &Agent{
-		cfg:    cfg,
-		client: client,
+	a := &Agent{
+		cfg:         cfg,
+		client:      client,
+		publicKey:   publicKey,
+		retryPolicy: cfg.Retry.Policy(),
+		reports:     make(chan []models.Metric, cfg.QueueSize),
+		spool:       reportSpool,
+		realIP:      realIP,
+		labels:      agentLabels(realIP, logger),
+		logger:      logger,
+	}
+	if cfg.Transport == TransportGRPC {
+		conn, err := newGRPCConn(cfg.GRPCAddr, cfg.SecretKey)
+		if err != nil {
+			return nil, err
+		}
+		a.grpcConn = conn
+		a.grpcClient = pb.NewMetricsServiceClient(conn)
 	}
+	return a, nil
+}
+
+// Close освобождает соединения агента с сервером.
+func (a *Agent) Close() error {
+	if a.grpcConn != nil {
+		return a.grpcConn.Close()
+	}
+	return nil
 }
 
 func (a *Agent) StartPoll(ctx context.Context, wg *sync.WaitGroup) {
@@ -44,65 +159,175 @@ func (a *Agent) StartPoll(ctx context.Context, wg *sync.WaitGroup) {
 	for {
 		select {
 		case <-ctx.Done():
-			log.Println("Poll goroutine is shutting down...")
+			a.logger.Info("Poll goroutine is shutting down")
 			wg.Done()
 			return
 		case <-ticker.C:
-			a.metrics = a.collectMetrics()
+			metrics := a.collectMetrics()
+			a.mu.Lock()
+			a.metrics = metrics
+			a.mu.Unlock()
 		}
 	}
 }
 
+// StartSendReport по таймеру ставит отчеты в очередь, которую разбирают
+// не более RateLimit горутин-отправителей.
+//
+// После отмены ctx начатые отправки, отчеты из очереди и последний отчет
+// отправляются в течение ShutdownTimeout.
 func (a *Agent) StartSendReport(ctx context.Context, wg *sync.WaitGroup) {
+	sendCtx, cancelSend := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelSend()
+	var workersWg sync.WaitGroup
+	workersWg.Add(a.cfg.RateLimit)
+	for i := 0; i < a.cfg.RateLimit; i++ {
+		go a.startSendWorker(ctx, sendCtx, &workersWg)
+	}
 	ticker := time.NewTicker(a.cfg.ReportInterval)
 	for {
 		select {
 		case <-ctx.Done():
-			log.Println("Goroutine sending reports has been shut down...")
+			timer := time.AfterFunc(a.cfg.ShutdownTimeout, cancelSend)
+			defer timer.Stop()
+			workersWg.Wait()
+			a.flushReports(sendCtx)
+			a.logger.Info("Goroutine sending reports has been shut down")
 			wg.Done()
 			return
 		case <-ticker.C:
-			a.sendMetrics(a.metrics)
+			a.mu.Lock()
+			metrics, addMetrics := a.metrics, a.addMetrics
+			a.mu.Unlock()
+			if metrics == nil {
+				continue
+			}
+			a.enqueueReport(a.buildReport(metrics, addMetrics))
+		}
+	}
+}
+
+// startSendWorker разбирает очередь отчетов до отмены ctx. Отчеты отправляются с sendCtx,
+// чтобы начатая отправка не прерывалась в момент остановки агента.
+func (a *Agent) startSendWorker(ctx context.Context, sendCtx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case report := <-a.reports:
+			a.deliverReport(sendCtx, report)
 		}
 	}
 }
 
+// enqueueReport ставит отчет в очередь отправки, не блокируя вызывающую горутину.
+// Если очередь заполнена, отчет обрабатывается согласно QueuePolicy.
+// У отброшенного отчета сохраняются приращения counter и histogram, чтобы они
+// попали в следующий отчет и не были потеряны.
+func (a *Agent) enqueueReport(report []models.Metric) {
+	report = a.takeDroppedDeltas(report)
+	select {
+	case a.reports <- report:
+		return
+	default:
+	}
+	if a.cfg.QueuePolicy == QueuePolicyCoalesce {
+		select {
+		case oldest := <-a.reports:
+			report = mergeReports(oldest, report)
+		default:
+		}
+		select {
+		case a.reports <- report:
+			return
+		default:
+		}
+	}
+	a.logger.Warn("Report queue is full, report dropped")
+	for _, metric := range report {
+		if metric.MType == models.Counter || metric.MType == models.Histogram {
+			a.droppedDeltas = append(a.droppedDeltas, metric)
+		}
+	}
+}
+
+// takeDroppedDeltas добавляет к отчету приращения из отброшенных ранее отчетов.
+func (a *Agent) takeDroppedDeltas(report []models.Metric) []models.Metric {
+	if len(a.droppedDeltas) == 0 {
+		return report
+	}
+	report = mergeReports(a.droppedDeltas, report)
+	a.droppedDeltas = nil
+	return report
+}
+
+// mergeReports объединяет два отчета в один. Значения gauge из более нового отчета
+// замещают значения из старого, приращения counter и histogram складываются.
+func mergeReports(older, newer []models.Metric) []models.Metric {
+	type key struct{ seriesKey, mType string }
+	merged := make([]models.Metric, 0, len(older)+len(newer))
+	index := make(map[key]int, len(older)+len(newer))
+	for _, report := range [][]models.Metric{older, newer} {
+		for _, metric := range report {
+			k := key{metric.SeriesKey(), metric.MType}
+			if i, ok := index[k]; ok {
+				switch metric.MType {
+				case models.Counter:
+					delta := *merged[i].Delta + *metric.Delta
+					metric.Delta = &delta
+				case models.Histogram:
+					if histogram, err := merged[i].Histogram.Merge(metric.Histogram); err == nil {
+						metric.Histogram = histogram
+					}
+				}
+				merged[i] = metric
+				continue
+			}
+			index[k] = len(merged)
+			merged = append(merged, metric)
+		}
+	}
+	return merged
+}
+
 type Metric struct {
-	Alloc         float64 `json:"alloc"`
-	BuckHashSys   float64 `json:"buck_hash_sys"`
-	Frees         float64 `json:"frees"`
-	GCCPUFraction float64 `json:"gccpufraction"`
-	GCSys         float64 `json:"gcsys"`
-	HeapAlloc     float64 `json:"heap_alloc"`
-	HeapIdle      float64 `json:"heap_idle"`
-	HeapInuse     float64 `json:"heap_inuse"`
-	HeapObjects   float64 `json:"heap_objects"`
-	HeapReleased  float64 `json:"heap_released"`
-	HeapSys       float64 `json:"heap_sys"`
-	LastGC        float64 `json:"last_gc"`
-	Lookups       float64 `json:"lookups"`
-	MCacheInuse   float64 `json:"mcache_inuse"`
-	MCacheSys     float64 `json:"mcache_sys"`
-	MSpanInuse    float64 `json:"mspan_inuse"`
-	MSpanSys      float64 `json:"mspan_sys"`
-	Mallocs       float64 `json:"mallocs"`
-	NextGC        float64 `json:"next_gc"`
-	NumForcedGC   float64 `json:"num_forced_gc"`
-	NumGC         float64 `json:"num_gc"`
-	OtherSys      float64 `json:"other_sys"`
-	PauseTotalNs  float64 `json:"pause_total_ns"`
-	StackInuse    float64 `json:"stack_inuse"`
-	StackSys      float64 `json:"stack_sys"`
-	Sys           float64 `json:"sys"`
-	TotalAlloc    float64 `json:"total_alloc"`
-	PollCount     int64   `json:"poll_count"`
-	RandomValue   float64 `json:"random_value"`
+	Alloc         float64               `json:"alloc"`
+	BuckHashSys   float64               `json:"buck_hash_sys"`
+	Frees         float64               `json:"frees"`
+	GCCPUFraction float64               `json:"gccpufraction"`
+	GCSys         float64               `json:"gcsys"`
+	HeapAlloc     float64               `json:"heap_alloc"`
+	HeapIdle      float64               `json:"heap_idle"`
+	HeapInuse     float64               `json:"heap_inuse"`
+	HeapObjects   float64               `json:"heap_objects"`
+	HeapReleased  float64               `json:"heap_released"`
+	HeapSys       float64               `json:"heap_sys"`
+	LastGC        float64               `json:"last_gc"`
+	Lookups       float64               `json:"lookups"`
+	MCacheInuse   float64               `json:"mcache_inuse"`
+	MCacheSys     float64               `json:"mcache_sys"`
+	MSpanInuse    float64               `json:"mspan_inuse"`
+	MSpanSys      float64               `json:"mspan_sys"`
+	Mallocs       float64               `json:"mallocs"`
+	NextGC        float64               `json:"next_gc"`
+	NumForcedGC   float64               `json:"num_forced_gc"`
+	NumGC         float64               `json:"num_gc"`
+	OtherSys      float64               `json:"other_sys"`
+	PauseTotalNs  float64               `json:"pause_total_ns"`
+	StackInuse    float64               `json:"stack_inuse"`
+	StackSys      float64               `json:"stack_sys"`
+	Sys           float64               `json:"sys"`
+	TotalAlloc    float64               `json:"total_alloc"`
+	PollCount     int64                 `json:"poll_count"`
+	RandomValue   float64               `json:"random_value"`
+	GCPauses      models.HistogramValue `json:"gc_pauses"`
 }
 
 type AddMetrics struct {
-	TotalMemory     float64 `json:"total_memory"`
-	FreeMemory      float64 `json:"free_memory"`
-	CPUutilization1 float64 `json:"cpu_utilization1"`
+	TotalMemory    float64   `json:"total_memory"`
+	FreeMemory     float64   `json:"free_memory"`
+	CPUutilization []float64 `json:"cpu_utilization"`
 }
 
 func (a *Agent) collectMetrics() *Metric {
@@ -139,10 +364,17 @@ func (a *Agent) collectMetrics() *Metric {
 		TotalAlloc:    float64(memStats.MSpanSys),
 		PollCount:     a.pollCount,
 		RandomValue:   float64(memStats.MSpanSys),
+		GCPauses:      readGCPauses(a.cfg.HistogramBuckets),
 	}
 }
 
-func (a *Agent) sendMetrics(metrics *Metric) error {
+// buildReport формирует отчет из снимка метрик. PollCount и гистограмма GCPauses передаются
+// как приращения с момента предыдущего отчета, чтобы сервер мог просто складывать значения.
+func (a *Agent) buildReport(metrics *Metric, addMetrics *AddMetrics) []models.Metric {
+	pollCount := metrics.PollCount - a.reportedPollCount
+	a.reportedPollCount = metrics.PollCount
+	gcPauses := histogramDelta(metrics.GCPauses, a.reportedGCPauses)
+	a.reportedGCPauses = metrics.GCPauses
 	values := models.MetricsData{
 		Gauge: map[string]float64{
 			"Alloc":         metrics.Alloc,
@@ -175,15 +407,30 @@ func (a *Agent) sendMetrics(metrics *Metric) error {
 			"RandomValue":   metrics.RandomValue,
 		},
 		Counter: map[string]int64{
-			"PollCount": metrics.PollCount,
+			"PollCount": pollCount,
 		},
 	}
+	if addMetrics != nil {
+		values.Gauge["TotalMemory"] = addMetrics.TotalMemory
+		values.Gauge["FreeMemory"] = addMetrics.FreeMemory
+		for i, utilization := range addMetrics.CPUutilization {
+			values.Gauge[fmt.Sprintf("CPUutilization%d", i+1)] = utilization
+		}
+	}
 	metricsList := []models.Metric{}
 	for metricName, metricValue := range values.Gauge {
-		metricsList = append(metricsList, models.Metric{ID: metricName, MType: models.Gauge, Value: &metricValue})
+		metricsList = append(metricsList, models.Metric{ID: metricName, MType: models.Gauge, Value: &metricValue, Labels: a.labels})
 	}
 	for metricName, metricDelta := range values.Counter {
-		metricsList = append(metricsList, models.Metric{ID: metricName, MType: models.Counter, Delta: &metricDelta})
+		metricsList = append(metricsList, models.Metric{ID: metricName, MType: models.Counter, Delta: &metricDelta, Labels: a.labels})
+	}
+	metricsList = append(metricsList, models.Metric{ID: "GCPauses", MType: models.Histogram, Histogram: &gcPauses, Labels: a.labels})
+	return metricsList
+}
+
+func (a *Agent) sendMetrics(ctx context.Context, metricsList []models.Metric) error {
+	if a.grpcClient != nil {
+		return a.sendMetricsGRPC(ctx, metricsList)
 	}
 	metricsListJSON, err := json.Marshal(metricsList)
 	if err != nil {
@@ -193,25 +440,77 @@ func (a *Agent) sendMetrics(metrics *Metric) error {
 	if err != nil {
 		return err
 	}
-	url := fmt.Sprintf("%s/updates/", a.cfg.ServerURL)
-	request := a.client.
-		R().
-		SetHeader("Content-Type", "application/json").
-		SetHeader("Content-Encoding", "gzip").
-		SetBody(metricGzip)
+	headers := map[string]string{
+		"Content-Type":     "application/json",
+		"Content-Encoding": "gzip",
+	}
+	if a.realIP != "" {
+		headers["X-Real-IP"] = a.realIP
+	}
+	if id := requestid.FromContext(ctx); id != "" {
+		headers[requestid.Header] = id
+	}
 	if a.cfg.SecretKey != "" {
 		h := hmac.New(sha256.New, []byte(a.cfg.SecretKey))
 		h.Write(metricGzip)
 		dst := h.Sum(nil)
-		signString := hex.EncodeToString(dst)
-		request.SetHeader("HashSHA256", signString)
+		headers["HashSHA256"] = hex.EncodeToString(dst)
+	}
+	body := metricGzip
+	if a.publicKey != nil {
+		var encryptedKey string
+		body, encryptedKey, err = encryptor.Encrypt(a.publicKey, metricGzip)
+		if err != nil {
+			return err
+		}
+		headers[encryptor.EncryptedKeyHeader] = encryptedKey
+	}
+	url := fmt.Sprintf("%s/updates/", a.cfg.ServerURL)
+	return a.retryPolicy.Do(ctx, func() error {
+		resp, err := a.client.
+			R().
+			SetContext(ctx).
+			SetHeaders(headers).
+			SetBody(body).
+			Post(url)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode() != http.StatusOK {
+			return &retry.StatusError{StatusCode: resp.StatusCode(), Body: string(resp.Body())}
+		}
+		return nil
+	}, retry.IsRetriableHTTP)
+}
+
+// outboundIP возвращает IP-адрес интерфейса, через который агент обращается к серверу.
+// UDP-соединение не отправляет пакетов, оно нужно только для выбора маршрута.
+func outboundIP(addr string) (string, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return "", err
 	}
-	resp, err := request.Post(url)
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP.String(), nil
+}
+
+// agentLabels возвращает метки, которыми агент помечает свои метрики: host - имя хоста,
+// instance - адрес агента, а если он неизвестен, то имя хоста.
+func agentLabels(realIP string, logger *slog.Logger) models.Labels {
+	labels := models.Labels{}
+	host, err := os.Hostname()
 	if err != nil {
-		return err
+		logger.Warn("Unable to determine host name", "error", err)
+	} else {
+		labels["host"] = host
 	}
-	if resp.StatusCode() != http.StatusOK {
-		return fmt.Errorf("send metrics error: %s", string(resp.Body()))
+	if realIP != "" {
+		labels["instance"] = realIP
+	} else if host != "" {
+		labels["instance"] = host
 	}
-	return nil
+	if len(labels) == 0 {
+		return nil
+	}
+	return labels
 }
@@ -2,11 +2,22 @@ package agent
 
 import (
 	"context"
+	"encoding/json"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/eac0de/getmetrics/internal/config"
+	"github.com/eac0de/getmetrics/internal/models"
+	"github.com/eac0de/getmetrics/pkg/encryptor"
+	"github.com/eac0de/getmetrics/pkg/middlewares"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -14,15 +25,25 @@ func TestNewAgent(t *testing.T) {
 	var cfg config.AgentConfig
 	serverURL := "localhost:8080"
 	cfg.ServerURL = serverURL
-	agent := NewAgent(&cfg)
+	agent, err := NewAgent(&cfg, slog.Default())
+	assert.NoError(t, err)
 	assert.Equal(t, agent.cfg.ServerURL, "http://"+serverURL)
 
 }
 
+func TestNewAgentInvalidBuckets(t *testing.T) {
+	for _, buckets := range [][]float64{{0.1, 0.01}, {0.1, 0.1}, {0.1, math.Inf(1)}} {
+		cfg := config.AgentConfig{ServerURL: "localhost:8080", HistogramBuckets: buckets}
+		_, err := NewAgent(&cfg, slog.Default())
+		assert.Error(t, err, buckets)
+	}
+}
+
 func TestStartPoll(t *testing.T) {
 	var cfg config.AgentConfig
 	cfg.PollInterval = 10 * time.Second
-	agent := NewAgent(&cfg)
+	agent, err := NewAgent(&cfg, slog.Default())
+	assert.NoError(t, err)
 
 	var wg sync.WaitGroup
 	wg.Add(1) // Увеличиваем счетчик
@@ -40,7 +61,8 @@ func TestStartPoll(t *testing.T) {
 func TestStartSendReport(t *testing.T) {
 	var cfg config.AgentConfig
 	cfg.ReportInterval = 10 * time.Second
-	agent := NewAgent(&cfg)
+	agent, err := NewAgent(&cfg, slog.Default())
+	assert.NoError(t, err)
 
 	var wg sync.WaitGroup
 	wg.Add(1) // Увеличиваем счетчик
@@ -56,8 +78,187 @@ func TestStartSendReport(t *testing.T) {
 
 func TestCollectMetrics(t *testing.T) {
 	var cfg config.AgentConfig
-	agent := NewAgent(&cfg)
+	agent, err := NewAgent(&cfg, slog.Default())
+	assert.NoError(t, err)
 	agent.pollCount = 5
 	agent.collectMetrics()
 	assert.Equal(t, agent.pollCount, int64(6))
 }
+
+func TestSendReportRateLimit(t *testing.T) {
+	var inFlight, maxInFlight, received int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := atomic.AddInt32(&inFlight, 1)
+		for {
+			prev := atomic.LoadInt32(&maxInFlight)
+			if current <= prev || atomic.CompareAndSwapInt32(&maxInFlight, prev, current) {
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+		atomic.AddInt32(&received, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	var cfg config.AgentConfig
+	cfg.ServerURL = strings.TrimPrefix(server.URL, "http://")
+	cfg.ReportInterval = time.Hour
+	cfg.RateLimit = 2
+	cfg.QueueSize = 10
+	agent, err := NewAgent(&cfg, slog.Default())
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	go agent.StartSendReport(ctx, &wg)
+
+	report := agent.buildReport(agent.collectMetrics(), nil)
+	for i := 0; i < 6; i++ {
+		agent.enqueueReport(report)
+	}
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&received) == 6
+	}, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	wg.Wait()
+	assert.LessOrEqual(t, atomic.LoadInt32(&maxInFlight), int32(2))
+}
+
+func TestEnqueueReport(t *testing.T) {
+	gauge := func(v float64) []models.Metric {
+		return []models.Metric{{ID: "test_gauge", MType: models.Gauge, Value: &v}}
+	}
+	t.Run("drop", func(t *testing.T) {
+		cfg := config.AgentConfig{QueueSize: 1, QueuePolicy: QueuePolicyDrop}
+		agent, err := NewAgent(&cfg, slog.Default())
+		assert.NoError(t, err)
+		agent.enqueueReport(gauge(1))
+		agent.enqueueReport(gauge(2))
+		assert.Len(t, agent.reports, 1)
+		report := <-agent.reports
+		assert.Equal(t, float64(1), *report[0].Value)
+	})
+	t.Run("drop keeps deltas", func(t *testing.T) {
+		cfg := config.AgentConfig{QueueSize: 1, QueuePolicy: QueuePolicyDrop}
+		agent, err := NewAgent(&cfg, slog.Default())
+		assert.NoError(t, err)
+		counter := func(v int64) models.Metric {
+			return models.Metric{ID: "PollCount", MType: models.Counter, Delta: &v}
+		}
+		agent.enqueueReport(append(gauge(1), counter(1)))
+		agent.enqueueReport(append(gauge(2), counter(2)))
+		<-agent.reports
+		agent.enqueueReport(append(gauge(3), counter(3)))
+		report := <-agent.reports
+		assert.Len(t, report, 2)
+		for _, metric := range report {
+			switch metric.MType {
+			case models.Gauge:
+				assert.Equal(t, float64(3), *metric.Value)
+			case models.Counter:
+				assert.Equal(t, int64(5), *metric.Delta)
+			}
+		}
+	})
+	t.Run("coalesce", func(t *testing.T) {
+		cfg := config.AgentConfig{QueueSize: 1, QueuePolicy: QueuePolicyCoalesce}
+		agent, err := NewAgent(&cfg, slog.Default())
+		assert.NoError(t, err)
+		counter := func(v int64) models.Metric {
+			return models.Metric{ID: "test_counter", MType: models.Counter, Delta: &v}
+		}
+		agent.enqueueReport(append(gauge(1), counter(1)))
+		agent.enqueueReport(append(gauge(2), counter(2)))
+		assert.Len(t, agent.reports, 1)
+		report := <-agent.reports
+		assert.Len(t, report, 2)
+		assert.Equal(t, float64(2), *report[0].Value)
+		assert.Equal(t, int64(3), *report[1].Delta)
+	})
+}
+
+func TestSendMetricsEncrypted(t *testing.T) {
+	privateKey, err := encryptor.LoadPrivateKey("../../server.key")
+	assert.NoError(t, err)
+	var received []models.Metric
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := json.NewDecoder(r.Body).Decode(&received)
+		assert.NoError(t, err)
+		w.WriteHeader(http.StatusOK)
+	})
+	server := httptest.NewServer(
+		middlewares.GetDecryptMiddleware(privateKey, true)(
+			middlewares.GetGzipMiddleware("application/json")(handler),
+		),
+	)
+	defer server.Close()
+
+	cfg := config.AgentConfig{
+		ServerURL:     strings.TrimPrefix(server.URL, "http://"),
+		PublicKeyPath: "../../public.pem",
+	}
+	agent, err := NewAgent(&cfg, slog.Default())
+	assert.NoError(t, err)
+	delta := int64(1)
+	report := []models.Metric{{ID: "test_counter", MType: models.Counter, Delta: &delta}}
+	err = agent.sendMetrics(context.Background(), report)
+	assert.NoError(t, err)
+	assert.Equal(t, report, received)
+}
+
+func TestSendMetricsRealIP(t *testing.T) {
+	var realIP string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		realIP = r.Header.Get("X-Real-IP")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := config.AgentConfig{ServerURL: strings.TrimPrefix(server.URL, "http://")}
+	agent, err := NewAgent(&cfg, slog.Default())
+	assert.NoError(t, err)
+	err = agent.sendMetrics(context.Background(), []models.Metric{})
+	assert.NoError(t, err)
+	assert.Equal(t, "127.0.0.1", realIP)
+}
+
+func TestSendMetricsRetry(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := config.AgentConfig{
+		ServerURL: strings.TrimPrefix(server.URL, "http://"),
+		Retry: config.RetryConfig{
+			Attempts:  3,
+			BaseDelay: time.Millisecond,
+		},
+	}
+	agent, err := NewAgent(&cfg, slog.Default())
+	assert.NoError(t, err)
+	err = agent.sendMetrics(context.Background(), agent.buildReport(agent.collectMetrics(), nil))
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestBuildReportLabels(t *testing.T) {
+	var cfg config.AgentConfig
+	agent, err := NewAgent(&cfg, slog.Default())
+	assert.NoError(t, err)
+	host, err := os.Hostname()
+	assert.NoError(t, err)
+	assert.Equal(t, host, agent.labels["host"])
+	assert.NotEmpty(t, agent.labels["instance"])
+	for _, metric := range agent.buildReport(agent.collectMetrics(), nil) {
+		assert.Equal(t, agent.labels, metric.Labels)
+	}
+}
@@ -0,0 +1,121 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/eac0de/getmetrics/internal/models"
+	"github.com/eac0de/getmetrics/pkg/requestid"
+)
+
+// deliverReport отправляет отчет на сервер. Если отправить отчет не удалось,
+// он сохраняется в спул и будет отправлен повторно, когда сервер снова станет доступен.
+//
+// Каждой отправке назначается идентификатор запроса, который передается серверу
+// и попадает в записи лога агента об этой отправке.
+func (a *Agent) deliverReport(ctx context.Context, report []models.Metric) {
+	if a.spool == nil {
+		ctx := requestid.NewContext(ctx, requestid.New())
+		err := a.sendMetrics(ctx, report)
+		if err != nil {
+			a.logger.ErrorContext(ctx, "Send report error", "error", err)
+		}
+		return
+	}
+	// Пока в спуле есть неотправленные отчеты, новые ставятся за ними,
+	// чтобы сервер получил значения gauge в исходном порядке.
+	if a.spool.Len() == 0 {
+		ctx := requestid.NewContext(ctx, requestid.New())
+		err := a.sendMetrics(ctx, report)
+		if err == nil {
+			return
+		}
+		a.logger.WarnContext(ctx, "Send report error, report is spooled", "error", err)
+		a.spoolReport(report)
+		return
+	}
+	a.spoolReport(report)
+	a.replaySpool(ctx)
+}
+
+// flushReports вызывается при остановке агента. Отправляет отчеты, оставшиеся в очереди,
+// и последний отчет с метриками, собранными после предыдущей отправки.
+// Отчеты, которые не удалось отправить до отмены ctx, сохраняются в спул.
+func (a *Agent) flushReports(ctx context.Context) {
+pending:
+	for {
+		select {
+		case report := <-a.reports:
+			a.deliverReport(ctx, report)
+		default:
+			break pending
+		}
+	}
+	a.mu.Lock()
+	metrics, addMetrics := a.metrics, a.addMetrics
+	a.mu.Unlock()
+	if metrics == nil {
+		return
+	}
+	a.deliverReport(ctx, a.takeDroppedDeltas(a.buildReport(metrics, addMetrics)))
+}
+
+func (a *Agent) spoolReport(report []models.Metric) {
+	data, err := json.Marshal(report)
+	if err != nil {
+		a.logger.Error("Spool report error", "error", err)
+		return
+	}
+	err = a.spool.Append(data)
+	if err != nil {
+		a.logger.Error("Spool report error", "error", err)
+	}
+}
+
+// replaySpool отправляет отчеты из спула, начиная с самого старого, до первой ошибки.
+// Отчет удаляется из спула только после успешной отправки, поэтому
+// приращения счетчиков не учитываются сервером дважды.
+//
+// Если спул уже отправляет другая горутина, функция сразу завершается. Отчет, добавленный
+// в спул в это время, отправит та горутина: освободив блокировку, она проверяет спул повторно.
+func (a *Agent) replaySpool(ctx context.Context) {
+	for a.spool.Len() > 0 && a.replayMu.TryLock() {
+		drained := a.drainSpool(ctx)
+		a.replayMu.Unlock()
+		if !drained {
+			return
+		}
+	}
+}
+
+// drainSpool отправляет отчеты из спула, пока он не опустеет. Возвращает false,
+// если отправка прервана ошибкой. Вызывается под a.replayMu.
+func (a *Agent) drainSpool(ctx context.Context) bool {
+	for {
+		name, data, err := a.spool.Peek()
+		if err != nil {
+			a.logger.ErrorContext(ctx, "Read spool error", "error", err)
+			return false
+		}
+		if name == "" {
+			return true
+		}
+		var report []models.Metric
+		err = json.Unmarshal(data, &report)
+		if err != nil {
+			a.logger.WarnContext(ctx, "Spooled report is corrupted and skipped", "name", name, "error", err)
+		} else {
+			sendCtx := requestid.NewContext(ctx, requestid.New())
+			err = a.sendMetrics(sendCtx, report)
+			if err != nil {
+				a.logger.WarnContext(sendCtx, "Replay spooled report error", "name", name, "error", err)
+				return false
+			}
+		}
+		err = a.spool.Remove(name)
+		if err != nil {
+			a.logger.ErrorContext(ctx, "Remove spooled report error", "error", err)
+			return false
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eac0de/getmetrics/internal/config"
+	"github.com/eac0de/getmetrics/internal/models"
+	"github.com/eac0de/getmetrics/pkg/middlewares"
+	"github.com/eac0de/getmetrics/pkg/requestid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeliverReportSpool(t *testing.T) {
+	var available atomic.Bool
+	var mu sync.Mutex
+	var received [][]models.Metric
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !available.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var report []models.Metric
+		err := json.NewDecoder(r.Body).Decode(&report)
+		assert.NoError(t, err)
+		mu.Lock()
+		received = append(received, report)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	})
+	server := httptest.NewServer(middlewares.GetGzipMiddleware("application/json")(handler))
+	defer server.Close()
+
+	cfg := config.AgentConfig{
+		ServerURL: strings.TrimPrefix(server.URL, "http://"),
+		SpoolDir:  t.TempDir(),
+	}
+	agent, err := NewAgent(&cfg, slog.Default())
+	assert.NoError(t, err)
+	counter := func(delta int64) []models.Metric {
+		return []models.Metric{{ID: "test_counter", MType: models.Counter, Delta: &delta}}
+	}
+
+	agent.deliverReport(context.Background(), counter(1))
+	agent.deliverReport(context.Background(), counter(2))
+	assert.Equal(t, 2, agent.spool.Len())
+	assert.Empty(t, received)
+
+	available.Store(true)
+	agent.deliverReport(context.Background(), counter(3))
+	assert.Equal(t, 0, agent.spool.Len())
+
+	var deltas []int64
+	for _, report := range received {
+		deltas = append(deltas, *report[0].Delta)
+	}
+	assert.Equal(t, []int64{1, 2, 3}, deltas)
+}
+
+func TestBuildReportPollCountDelta(t *testing.T) {
+	var cfg config.AgentConfig
+	agent, err := NewAgent(&cfg, slog.Default())
+	assert.NoError(t, err)
+	pollCount := func(report []models.Metric) int64 {
+		for _, metric := range report {
+			if metric.ID == "PollCount" {
+				return *metric.Delta
+			}
+		}
+		return 0
+	}
+	agent.collectMetrics()
+	agent.collectMetrics()
+	assert.Equal(t, int64(3), pollCount(agent.buildReport(agent.collectMetrics(), nil)))
+	assert.Equal(t, int64(1), pollCount(agent.buildReport(agent.collectMetrics(), nil)))
+}
+
+func TestFlushReports(t *testing.T) {
+	var mu sync.Mutex
+	var received [][]models.Metric
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var report []models.Metric
+		err := json.NewDecoder(r.Body).Decode(&report)
+		assert.NoError(t, err)
+		mu.Lock()
+		received = append(received, report)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	})
+	server := httptest.NewServer(middlewares.GetGzipMiddleware("application/json")(handler))
+	defer server.Close()
+
+	cfg := config.AgentConfig{
+		ServerURL:      strings.TrimPrefix(server.URL, "http://"),
+		ReportInterval: time.Hour,
+	}
+	agent, err := NewAgent(&cfg, slog.Default())
+	assert.NoError(t, err)
+	delta := int64(1)
+	agent.enqueueReport([]models.Metric{{ID: "test_counter", MType: models.Counter, Delta: &delta}})
+	agent.metrics = agent.collectMetrics()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	agent.StartSendReport(ctx, &wg)
+	wg.Wait()
+
+	// Сначала отправляется отчет из очереди, затем последний отчет с текущими метриками
+	assert.Len(t, received, 2)
+	assert.Equal(t, "test_counter", received[0][0].ID)
+	assert.Greater(t, len(received[1]), 1)
+}
+
+func TestDeliverReportRequestID(t *testing.T) {
+	var mu sync.Mutex
+	var ids []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ids = append(ids, r.Header.Get(requestid.Header))
+		first := len(ids) == 1
+		mu.Unlock()
+		if first {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := config.AgentConfig{
+		ServerURL: strings.TrimPrefix(server.URL, "http://"),
+		Retry: config.RetryConfig{
+			Attempts:  2,
+			BaseDelay: time.Millisecond,
+		},
+	}
+	agent, err := NewAgent(&cfg, slog.Default())
+	assert.NoError(t, err)
+	agent.deliverReport(context.Background(), []models.Metric{})
+	agent.deliverReport(context.Background(), []models.Metric{})
+
+	// Повторные попытки отправки отчета идут с тем же идентификатором, а новый отчет получает новый
+	assert.Len(t, ids, 3)
+	assert.True(t, requestid.Valid(ids[0]))
+	assert.Equal(t, ids[0], ids[1])
+	assert.NotEqual(t, ids[1], ids[2])
+}
@@ -0,0 +1,46 @@
+package agent
+
+import (
+	"context"
+
+	"github.com/eac0de/getmetrics/internal/api/pb"
+	"github.com/eac0de/getmetrics/internal/models"
+	"github.com/eac0de/getmetrics/pkg/interceptors"
+	"github.com/eac0de/getmetrics/pkg/requestid"
+	"github.com/eac0de/getmetrics/pkg/retry"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// TransportHTTP - отправка отчетов по HTTP в формате JSON.
+	TransportHTTP = "http"
+	// TransportGRPC - отправка отчетов через gRPC-сервис MetricsService.
+	TransportGRPC = "grpc"
+)
+
+func newGRPCConn(addr string, secretKey string) (*grpc.ClientConn, error) {
+	return grpc.NewClient(
+		addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(
+			interceptors.GzipClientInterceptor,
+			interceptors.GetSignClientInterceptor(secretKey),
+		),
+	)
+}
+
+func (a *Agent) sendMetricsGRPC(ctx context.Context, metricsList []models.Metric) error {
+	req := &pb.UpdateMetricsRequest{Metrics: pb.FromModels(metricsList)}
+	if a.realIP != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, interceptors.RealIPMetadataKey, a.realIP)
+	}
+	if id := requestid.FromContext(ctx); id != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, requestid.MetadataKey, id)
+	}
+	return a.retryPolicy.Do(ctx, func() error {
+		_, err := a.grpcClient.UpdateMetrics(ctx, req)
+		return err
+	}, retry.IsRetriableGRPC)
+}
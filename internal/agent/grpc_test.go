@@ -0,0 +1,58 @@
+package agent
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"testing"
+
+	"github.com/eac0de/getmetrics/internal/api/grpchandlers"
+	"github.com/eac0de/getmetrics/internal/api/pb"
+	"github.com/eac0de/getmetrics/internal/config"
+	"github.com/eac0de/getmetrics/internal/models"
+	"github.com/eac0de/getmetrics/internal/storage/memstore"
+	"github.com/eac0de/getmetrics/pkg/interceptors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+func TestSendMetricsGRPC(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	store := memstore.New()
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(interceptors.GetCheckSignInterceptor("mysecretkey")))
+	pb.RegisterMetricsServiceServer(server, grpchandlers.NewMetricsServer(store, slog.Default()))
+	go server.Serve(listener)
+	defer server.Stop()
+
+	cfg := config.AgentConfig{
+		Transport: TransportGRPC,
+		GRPCAddr:  listener.Addr().String(),
+		SecretKey: "mysecretkey",
+	}
+	agent, err := NewAgent(&cfg, slog.Default())
+	assert.NoError(t, err)
+	defer agent.Close()
+
+	err = agent.sendMetrics(context.Background(), agent.buildReport(agent.collectMetrics(), nil))
+	assert.NoError(t, err)
+	metric, err := store.GetMetric(context.Background(), "PollCount", models.Counter, agent.labels)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), *metric.Delta)
+}
+
+func TestNewAgentGRPCWithCryptoKey(t *testing.T) {
+	cfg := config.AgentConfig{
+		Transport:     TransportGRPC,
+		GRPCAddr:      "localhost:3200",
+		PublicKeyPath: "../../public.pem",
+	}
+	_, err := NewAgent(&cfg, slog.Default())
+	assert.Error(t, err)
+}
+
+func TestNewAgentUnknownTransport(t *testing.T) {
+	cfg := config.AgentConfig{Transport: "gprc", GRPCAddr: "localhost:3200"}
+	_, err := NewAgent(&cfg, slog.Default())
+	assert.Error(t, err)
+}
@@ -0,0 +1,79 @@
+package agent
+
+import (
+	"math"
+	"runtime/metrics"
+	"slices"
+	"sort"
+
+	"github.com/eac0de/getmetrics/internal/models"
+)
+
+// defaultHistogramBuckets - границы корзин гистограмм по умолчанию, в секундах.
+var defaultHistogramBuckets = []float64{0.00001, 0.00005, 0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1}
+
+// gcPausesMetric - распределение длительности пауз сборщика мусора, в секундах.
+const gcPausesMetric = "/sched/pauses/total/gc:seconds"
+
+// readGCPauses читает накопленное распределение пауз сборщика мусора из runtime/metrics
+// и переносит его в корзины с границами bounds.
+func readGCPauses(bounds []float64) models.HistogramValue {
+	sample := []metrics.Sample{{Name: gcPausesMetric}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindFloat64Histogram {
+		return rebucket(nil, bounds)
+	}
+	return rebucket(sample[0].Value.Float64Histogram(), bounds)
+}
+
+// rebucket переносит гистограмму runtime/metrics в корзины с границами bounds.
+//
+// Каждая исходная корзина целиком относится к первой корзине, верхняя граница которой
+// не меньше верхней границы исходной. Runtime не сообщает сумму наблюдений, поэтому она
+// оценивается по серединам исходных корзин.
+func rebucket(h *metrics.Float64Histogram, bounds []float64) models.HistogramValue {
+	result := models.HistogramValue{
+		Bounds: slices.Clone(bounds),
+		Counts: make([]uint64, len(bounds)+1),
+	}
+	if h == nil {
+		return result
+	}
+	for i, count := range h.Counts {
+		if count == 0 {
+			continue
+		}
+		lower, upper := h.Buckets[i], h.Buckets[i+1]
+		target := sort.SearchFloat64s(bounds, upper)
+		result.Counts[target] += count
+		result.Count += count
+		switch {
+		case math.IsInf(lower, -1) && math.IsInf(upper, 1):
+		case math.IsInf(lower, -1):
+			result.Sum += upper * float64(count)
+		case math.IsInf(upper, 1):
+			result.Sum += lower * float64(count)
+		default:
+			result.Sum += (lower + upper) / 2 * float64(count)
+		}
+	}
+	return result
+}
+
+// histogramDelta возвращает приращение накопленной гистограммы cur с момента prev.
+// Если границы корзин не совпадают, возвращается cur целиком.
+func histogramDelta(cur, prev models.HistogramValue) models.HistogramValue {
+	if !cur.SameBounds(&prev) || len(prev.Counts) != len(cur.Counts) {
+		return cur
+	}
+	delta := models.HistogramValue{
+		Bounds: slices.Clone(cur.Bounds),
+		Counts: make([]uint64, len(cur.Counts)),
+		Sum:    cur.Sum - prev.Sum,
+		Count:  cur.Count - prev.Count,
+	}
+	for i := range cur.Counts {
+		delta.Counts[i] = cur.Counts[i] - prev.Counts[i]
+	}
+	return delta
+}
@@ -0,0 +1,62 @@
+package agent
+
+import (
+	"log/slog"
+	"math"
+	"runtime"
+	"runtime/metrics"
+	"testing"
+
+	"github.com/eac0de/getmetrics/internal/config"
+	"github.com/eac0de/getmetrics/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRebucket(t *testing.T) {
+	h := &metrics.Float64Histogram{
+		Counts:  []uint64{1, 2, 3, 4},
+		Buckets: []float64{math.Inf(-1), 0.5, 1.5, 3, math.Inf(1)},
+	}
+	result := rebucket(h, []float64{1, 2})
+	assert.Equal(t, []float64{1, 2}, result.Bounds)
+	assert.Equal(t, []uint64{1, 2, 7}, result.Counts)
+	assert.Equal(t, uint64(10), result.Count)
+	// 1*0.5 + 2*1 + 3*2.25 + 4*3
+	assert.InDelta(t, 21.25, result.Sum, 1e-9)
+}
+
+func TestHistogramDelta(t *testing.T) {
+	prev := models.HistogramValue{Bounds: []float64{1}, Counts: []uint64{1, 2}, Sum: 3, Count: 3}
+	cur := models.HistogramValue{Bounds: []float64{1}, Counts: []uint64{4, 2}, Sum: 5, Count: 6}
+	assert.Equal(t, models.HistogramValue{Bounds: []float64{1}, Counts: []uint64{3, 0}, Sum: 2, Count: 3}, histogramDelta(cur, prev))
+	assert.Equal(t, cur, histogramDelta(cur, models.HistogramValue{}))
+}
+
+func TestBuildReportGCPauses(t *testing.T) {
+	cfg := config.AgentConfig{HistogramBuckets: []float64{0.001, 0.01}}
+	agent, err := NewAgent(&cfg, slog.Default())
+	assert.NoError(t, err)
+	runtime.GC()
+
+	gcPauses := func(report []models.Metric) *models.HistogramValue {
+		for _, metric := range report {
+			if metric.ID == "GCPauses" {
+				assert.Equal(t, models.Histogram, metric.MType)
+				return metric.Histogram
+			}
+		}
+		return nil
+	}
+	first := gcPauses(agent.buildReport(agent.collectMetrics(), nil))
+	assert.NotNil(t, first)
+	assert.NoError(t, first.Validate())
+	assert.Equal(t, []float64{0.001, 0.01}, first.Bounds)
+	assert.NotZero(t, first.Count)
+
+	// Второй отчет содержит только паузы, случившиеся после первого
+	runtime.GC()
+	second := gcPauses(agent.buildReport(agent.collectMetrics(), nil))
+	assert.NoError(t, second.Validate())
+	assert.NotZero(t, second.Count)
+	assert.Equal(t, agent.reportedGCPauses.Count, first.Count+second.Count)
+}
@@ -0,0 +1,197 @@
+// Package spool реализует очередь записей на диске для отчетов агента, которые не удалось
+// отправить.
+//
+// Каждая запись хранится в отдельном файле каталога, имена файлов задают порядок добавления.
+// Записи пишутся атомарно и сбрасываются на диск, поэтому после перезапуска агента или сбоя
+// питания в каталоге остаются только целые записи, и их можно отправить повторно.
+package spool
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	recordExt = ".json"
+	tmpExt    = ".tmp"
+)
+
+type record struct {
+	name string
+	size int64
+}
+
+// Spool хранит записи в каталоге на диске в порядке добавления.
+// Если суммарный размер записей превышает maxSize, самые старые записи удаляются.
+type Spool struct {
+	mu      sync.Mutex
+	dir     string
+	maxSize int64
+	records []record
+	size    int64
+	nextSeq uint64
+}
+
+// New открывает спул в каталоге dir, создавая его при необходимости,
+// и загружает список записей, оставшихся от предыдущего запуска.
+// Значение maxSize <= 0 снимает ограничение на размер.
+func New(dir string, maxSize int64) (*Spool, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	s := &Spool{dir: dir, maxSize: maxSize}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			continue
+		}
+		if strings.HasSuffix(name, tmpExt) {
+			// Незавершенная запись после аварийного останова.
+			os.Remove(filepath.Join(dir, name))
+			continue
+		}
+		seq, ok := parseSeq(name)
+		if !ok {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		s.records = append(s.records, record{name: name, size: info.Size()})
+		s.size += info.Size()
+		if seq >= s.nextSeq {
+			s.nextSeq = seq + 1
+		}
+	}
+	sort.Slice(s.records, func(i, j int) bool {
+		return s.records[i].name < s.records[j].name
+	})
+	s.evict()
+	return s, nil
+}
+
+// Append атомарно записывает data в конец спула и удаляет самые старые записи,
+// если превышен допустимый размер. Запись сбрасывается на диск до возврата,
+// поэтому сохраненный отчет переживает сбой питания.
+func (s *Spool) Append(data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name := fmt.Sprintf("%020d%s", s.nextSeq, recordExt)
+	path := filepath.Join(s.dir, name)
+	if err := writeFileAtomic(path, data); err != nil {
+		return err
+	}
+	s.nextSeq++
+	s.records = append(s.records, record{name: name, size: int64(len(data))})
+	s.size += int64(len(data))
+	s.evict()
+	return nil
+}
+
+// Peek возвращает имя и содержимое самой старой записи.
+// Если спул пуст, возвращает пустое имя.
+func (s *Spool) Peek() (string, []byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.records) == 0 {
+		return "", nil, nil
+	}
+	name := s.records[0].name
+	data, err := os.ReadFile(filepath.Join(s.dir, name))
+	if err != nil {
+		return "", nil, err
+	}
+	return name, data, nil
+}
+
+// Remove удаляет запись с именем name.
+func (s *Spool) Remove(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, r := range s.records {
+		if r.name != name {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.dir, name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		s.records = append(s.records[:i], s.records[i+1:]...)
+		s.size -= r.size
+		return nil
+	}
+	return nil
+}
+
+// Len возвращает количество записей в спуле.
+func (s *Spool) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.records)
+}
+
+// Size возвращает суммарный размер записей в байтах.
+func (s *Spool) Size() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size
+}
+
+func (s *Spool) evict() {
+	for s.maxSize > 0 && s.size > s.maxSize && len(s.records) > 0 {
+		oldest := s.records[0]
+		os.Remove(filepath.Join(s.dir, oldest.name))
+		s.records = s.records[1:]
+		s.size -= oldest.size
+	}
+}
+
+// writeFileAtomic записывает данные во временный файл, сбрасывает его на диск
+// и переименовывает в path, после чего сбрасывает на диск каталог.
+func writeFileAtomic(path string, data []byte) error {
+	tmpPath := path + tmpExt
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+func parseSeq(name string) (uint64, bool) {
+	if !strings.HasSuffix(name, recordExt) {
+		return 0, false
+	}
+	seq, err := strconv.ParseUint(strings.TrimSuffix(name, recordExt), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return seq, true
+}
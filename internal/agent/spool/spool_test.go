@@ -0,0 +1,73 @@
+package spool
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppendPeekRemove(t *testing.T) {
+	s, err := New(t.TempDir(), 0)
+	require.NoError(t, err)
+
+	name, data, err := s.Peek()
+	assert.NoError(t, err)
+	assert.Empty(t, name)
+	assert.Nil(t, data)
+
+	for _, record := range []string{"first", "second", "third"} {
+		require.NoError(t, s.Append([]byte(record)))
+	}
+	assert.Equal(t, 3, s.Len())
+	assert.Equal(t, int64(len("first")+len("second")+len("third")), s.Size())
+
+	for _, want := range []string{"first", "second", "third"} {
+		name, data, err := s.Peek()
+		require.NoError(t, err)
+		assert.Equal(t, want, string(data))
+		require.NoError(t, s.Remove(name))
+	}
+	assert.Equal(t, 0, s.Len())
+	assert.Equal(t, int64(0), s.Size())
+}
+
+func TestEvictOldest(t *testing.T) {
+	s, err := New(t.TempDir(), 10)
+	require.NoError(t, err)
+	require.NoError(t, s.Append([]byte("aaaa")))
+	require.NoError(t, s.Append([]byte("bbbb")))
+	require.NoError(t, s.Append([]byte("cccc")))
+	assert.Equal(t, 2, s.Len())
+
+	_, data, err := s.Peek()
+	require.NoError(t, err)
+	assert.Equal(t, "bbbb", string(data))
+}
+
+func TestReopen(t *testing.T) {
+	dir := t.TempDir()
+	s, err := New(dir, 0)
+	require.NoError(t, err)
+	require.NoError(t, s.Append([]byte("first")))
+	require.NoError(t, s.Append([]byte("second")))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken.json.tmp"), []byte("x"), 0644))
+
+	s, err = New(dir, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 2, s.Len())
+	require.NoError(t, s.Append([]byte("third")))
+
+	var records []string
+	for s.Len() > 0 {
+		name, data, err := s.Peek()
+		require.NoError(t, err)
+		records = append(records, string(data))
+		require.NoError(t, s.Remove(name))
+	}
+	assert.Equal(t, []string{"first", "second", "third"}, records)
+	_, err = os.Stat(filepath.Join(dir, "broken.json.tmp"))
+	assert.True(t, os.IsNotExist(err))
+}
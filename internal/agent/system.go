@@ -0,0 +1,179 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	memInfoPath = "/proc/meminfo"
+	statPath    = "/proc/stat"
+)
+
+// cpuTimes хранит счетчики времени одного ядра из /proc/stat в тиках.
+type cpuTimes struct {
+	idle  uint64
+	total uint64
+}
+
+// StartPollSystem с интервалом PollInterval собирает метрики хоста:
+// объем памяти и загрузку каждого ядра процессора.
+func (a *Agent) StartPollSystem(ctx context.Context, wg *sync.WaitGroup) {
+	ticker := time.NewTicker(a.cfg.PollInterval)
+	for {
+		select {
+		case <-ctx.Done():
+			a.logger.Info("System poll goroutine is shutting down")
+			wg.Done()
+			return
+		case <-ticker.C:
+			addMetrics, err := a.collectAddMetrics()
+			if err != nil {
+				a.logger.Error("Collect system metrics error", "error", err)
+				continue
+			}
+			a.mu.Lock()
+			a.addMetrics = addMetrics
+			a.mu.Unlock()
+		}
+	}
+}
+
+func (a *Agent) collectAddMetrics() (*AddMetrics, error) {
+	memFile, err := os.Open(memInfoPath)
+	if err != nil {
+		return nil, err
+	}
+	defer memFile.Close()
+	totalMemory, freeMemory, err := readMemInfo(memFile)
+	if err != nil {
+		return nil, err
+	}
+
+	statFile, err := os.Open(statPath)
+	if err != nil {
+		return nil, err
+	}
+	defer statFile.Close()
+	cpus, err := readCPUTimes(statFile)
+	if err != nil {
+		return nil, err
+	}
+	// Загрузка считается по разнице между двумя замерами, поэтому после первого
+	// замера метрики CPUutilization не отправляются.
+	prevCPUs := a.prevCPUs
+	a.prevCPUs = cpus
+	return &AddMetrics{
+		TotalMemory:    totalMemory,
+		FreeMemory:     freeMemory,
+		CPUutilization: cpuUtilization(prevCPUs, cpus),
+	}, nil
+}
+
+// readMemInfo возвращает общий и свободный объем памяти в байтах из содержимого /proc/meminfo.
+func readMemInfo(r io.Reader) (float64, float64, error) {
+	var total, free float64
+	var hasTotal, hasFree bool
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		var dst *float64
+		switch fields[0] {
+		case "MemTotal:":
+			dst, hasTotal = &total, true
+		case "MemFree:":
+			dst, hasFree = &free, true
+		default:
+			continue
+		}
+		value, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("parse %s: %w", fields[0], err)
+		}
+		if len(fields) > 2 && fields[2] == "kB" {
+			value *= 1024
+		}
+		*dst = value
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, 0, err
+	}
+	if !hasTotal || !hasFree {
+		return 0, 0, fmt.Errorf("MemTotal or MemFree not found in meminfo")
+	}
+	return total, free, nil
+}
+
+// readCPUTimes возвращает счетчики времени для каждого ядра из содержимого /proc/stat.
+// Суммарная строка "cpu" пропускается.
+func readCPUTimes(r io.Reader) ([]cpuTimes, error) {
+	var cpus []cpuTimes
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 || !strings.HasPrefix(fields[0], "cpu") || fields[0] == "cpu" {
+			continue
+		}
+		var times cpuTimes
+		for i, field := range fields[1:] {
+			// Поля guest и guest_nice уже учтены в user и nice.
+			if i >= 8 {
+				break
+			}
+			value, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("parse %s: %w", fields[0], err)
+			}
+			// Поля idle и iowait — время простоя ядра.
+			if i == 3 || i == 4 {
+				times.idle += value
+			}
+			times.total += value
+		}
+		cpus = append(cpus, times)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(cpus) == 0 {
+		return nil, fmt.Errorf("no cpu lines found in stat")
+	}
+	return cpus, nil
+}
+
+// cpuUtilization вычисляет загрузку каждого ядра в процентах между двумя замерами.
+// Если предыдущего замера нет или число ядер изменилось, возвращает nil.
+func cpuUtilization(prev, cur []cpuTimes) []float64 {
+	if len(prev) != len(cur) {
+		return nil
+	}
+	utilization := make([]float64, len(cur))
+	for i := range cur {
+		// Счетчик iowait может уменьшаться, поэтому разница ограничивается снизу нулем.
+		total := counterDelta(prev[i].total, cur[i].total)
+		idle := min(counterDelta(prev[i].idle, cur[i].idle), total)
+		if total == 0 {
+			continue
+		}
+		utilization[i] = float64(total-idle) / float64(total) * 100
+	}
+	return utilization
+}
+
+// counterDelta возвращает прирост счетчика или 0, если счетчик уменьшился.
+func counterDelta(prev, cur uint64) uint64 {
+	if cur < prev {
+		return 0
+	}
+	return cur - prev
+}
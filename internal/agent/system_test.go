@@ -0,0 +1,72 @@
+package agent
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/eac0de/getmetrics/internal/config"
+	"github.com/eac0de/getmetrics/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadMemInfo(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		data := "MemTotal:        2048 kB\nMemFree:         1024 kB\nMemAvailable:    1536 kB\n"
+		total, free, err := readMemInfo(strings.NewReader(data))
+		assert.NoError(t, err)
+		assert.Equal(t, float64(2048*1024), total)
+		assert.Equal(t, float64(1024*1024), free)
+	})
+	t.Run("missing fields", func(t *testing.T) {
+		_, _, err := readMemInfo(strings.NewReader("MemAvailable: 1536 kB\n"))
+		assert.Error(t, err)
+	})
+}
+
+func TestReadCPUTimes(t *testing.T) {
+	data := "cpu  30 0 10 60 0 0 0 0 0 0\n" +
+		"cpu0 10 0 5 35 0 0 0 0 0 0\n" +
+		"cpu1 20 0 5 20 5 0 0 0 0 0\n" +
+		"intr 1 2 3\n"
+	cpus, err := readCPUTimes(strings.NewReader(data))
+	assert.NoError(t, err)
+	assert.Equal(t, []cpuTimes{{idle: 35, total: 50}, {idle: 25, total: 50}}, cpus)
+}
+
+func TestCPUUtilization(t *testing.T) {
+	prev := []cpuTimes{{idle: 35, total: 50}, {idle: 25, total: 50}}
+	cur := []cpuTimes{{idle: 60, total: 150}, {idle: 25, total: 50}}
+	assert.Equal(t, []float64{75, 0}, cpuUtilization(prev, cur))
+	// Без предыдущего замера загрузка неизвестна
+	assert.Nil(t, cpuUtilization(nil, cur))
+	assert.Nil(t, cpuUtilization(prev[:1], cur))
+}
+
+func TestCPUUtilizationCountersGoBackwards(t *testing.T) {
+	// iowait уменьшился, поэтому idle второго замера меньше первого
+	prev := []cpuTimes{{idle: 100, total: 200}, {idle: 50, total: 100}}
+	cur := []cpuTimes{{idle: 90, total: 300}, {idle: 200, total: 150}}
+	assert.Equal(t, []float64{100, 0}, cpuUtilization(prev, cur))
+}
+
+func TestBuildReportWithAddMetrics(t *testing.T) {
+	var cfg config.AgentConfig
+	agent, err := NewAgent(&cfg, slog.Default())
+	assert.NoError(t, err)
+	report := agent.buildReport(agent.collectMetrics(), &AddMetrics{
+		TotalMemory:    2048,
+		FreeMemory:     1024,
+		CPUutilization: []float64{10, 20},
+	})
+	gauges := map[string]float64{}
+	for _, metric := range report {
+		if metric.MType == models.Gauge {
+			gauges[metric.ID] = *metric.Value
+		}
+	}
+	assert.Equal(t, float64(2048), gauges["TotalMemory"])
+	assert.Equal(t, float64(1024), gauges["FreeMemory"])
+	assert.Equal(t, float64(10), gauges["CPUutilization1"])
+	assert.Equal(t, float64(20), gauges["CPUutilization2"])
+}
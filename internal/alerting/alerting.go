@@ -0,0 +1,260 @@
+package alerting
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/eac0de/getmetrics/internal/models"
+)
+
+// Состояния оповещения.
+const (
+	// StatePending - условие выполняется, но еще не дольше, чем For правила.
+	StatePending = "pending"
+	// StateFiring - условие выполняется дольше, чем For правила.
+	StateFiring = "firing"
+	// StateResolved - условие сработавшего оповещения перестало выполняться.
+	StateResolved = "resolved"
+)
+
+// maxPendingNotifications - максимальное количество неотправленных уведомлений.
+// При превышении отбрасываются самые старые.
+const maxPendingNotifications = 1000
+
+// MetricsStore - источник метрик для вычисления правил.
+type MetricsStore interface {
+	ListAllMetrics(ctx context.Context) ([]*models.Metric, error)
+}
+
+// Alert описывает состояние оповещения для одной метрики, подходящей под правило.
+type Alert struct {
+	Rule       string        `json:"rule"`
+	MetricID   string        `json:"metric_id"`
+	MType      string        `json:"type"`
+	Labels     models.Labels `json:"labels,omitempty"`
+	Severity   string        `json:"severity"`
+	State      string        `json:"state"`
+	Op         string        `json:"op"`
+	Threshold  float64       `json:"threshold"`
+	Value      float64       `json:"value"`
+	ActiveAt   time.Time     `json:"active_at"`
+	FiredAt    *time.Time    `json:"fired_at,omitempty"`
+	ResolvedAt *time.Time    `json:"resolved_at,omitempty"`
+}
+
+// Engine периодически вычисляет правила по метрикам хранилища и отслеживает состояние оповещений.
+//
+// Оповещение создается в состоянии pending, когда условие правила начинает выполняться,
+// переходит в firing, если условие выполняется не меньше For, и в resolved, когда условие
+// сработавшего оповещения перестает выполняться. Уведомления отправляются при переходах
+// в firing и resolved.
+//
+// Уведомления ставятся в очередь и отправляются отдельно от вычисления правил, поэтому
+// медленный webhook не задерживает вычисление. Неотправленные уведомления остаются в очереди
+// и отправляются повторно после следующего вычисления, поэтому могут быть доставлены дважды.
+type Engine struct {
+	store    MetricsStore
+	rules    []Rule
+	notifier Notifier
+	mu       sync.Mutex
+	alerts   map[alertKey]*Alert
+	// pending - уведомления, которые еще не отправлены.
+	pending []Alert
+	// notifyCh сообщает отправителю, что в pending есть уведомления.
+	notifyCh chan struct{}
+	logger   *slog.Logger
+}
+
+type alertKey struct {
+	rule      string
+	seriesKey string
+}
+
+func NewEngine(store MetricsStore, rules []Rule, notifier Notifier, logger *slog.Logger) *Engine {
+	return &Engine{
+		store:    store,
+		rules:    rules,
+		notifier: notifier,
+		alerts:   make(map[alertKey]*Alert),
+		notifyCh: make(chan struct{}, 1),
+		logger:   logger,
+	}
+}
+
+func (e *Engine) Start(ctx context.Context, interval time.Duration) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		e.startNotifying(ctx)
+	}()
+	defer wg.Wait()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			e.logger.Info("Alerting engine is shutting down")
+			return
+		case <-ticker.C:
+			err := e.Evaluate(ctx, time.Now())
+			if err != nil {
+				e.logger.Error("Alert rules evaluation error", "error", err)
+			}
+		}
+	}
+}
+
+// Evaluate вычисляет все правила на момент now и ставит уведомления об изменениях состояния
+// в очередь отправки.
+func (e *Engine) Evaluate(ctx context.Context, now time.Time) error {
+	metrics, err := e.store.ListAllMetrics(ctx)
+	if err != nil {
+		return err
+	}
+	notifications := e.evaluate(metrics, now)
+	if e.notifier == nil {
+		return nil
+	}
+	e.mu.Lock()
+	e.queueNotifications(notifications)
+	hasPending := len(e.pending) > 0
+	e.mu.Unlock()
+	if hasPending {
+		select {
+		case e.notifyCh <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// SendNotifications отправляет уведомления из очереди. Если отправить их не удалось,
+// они возвращаются в очередь.
+func (e *Engine) SendNotifications(ctx context.Context) error {
+	e.mu.Lock()
+	alerts := e.pending
+	e.pending = nil
+	e.mu.Unlock()
+	if len(alerts) == 0 || e.notifier == nil {
+		return nil
+	}
+	err := e.notifier.Notify(ctx, alerts)
+	if err != nil {
+		// Уведомления, поставленные в очередь во время отправки, идут после неотправленных
+		e.mu.Lock()
+		pending := e.pending
+		e.pending = alerts
+		e.queueNotifications(pending)
+		e.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// startNotifying отправляет уведомления, поставленные в очередь, до отмены ctx.
+func (e *Engine) startNotifying(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-e.notifyCh:
+			err := e.SendNotifications(ctx)
+			if err != nil {
+				e.logger.Error("Alert notifications sending error", "error", err)
+			}
+		}
+	}
+}
+
+// queueNotifications добавляет уведомления в конец очереди. Вызывается под e.mu.
+func (e *Engine) queueNotifications(alerts []Alert) {
+	e.pending = append(e.pending, alerts...)
+	if dropped := len(e.pending) - maxPendingNotifications; dropped > 0 {
+		e.logger.Warn("Alert notifications queue is full, oldest notifications are dropped", "count", dropped)
+		e.pending = slices.Clone(e.pending[dropped:])
+	}
+}
+
+func (e *Engine) evaluate(metrics []*models.Metric, now time.Time) []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var notifications []Alert
+	active := make(map[alertKey]bool)
+	for _, rule := range e.rules {
+		for _, metric := range metrics {
+			if !rule.Matches(metric) {
+				continue
+			}
+			value := rule.Value(metric)
+			if math.IsNaN(value) || !rule.Compare(value) {
+				continue
+			}
+			key := alertKey{rule: rule.Name, seriesKey: metric.SeriesKey()}
+			active[key] = true
+			alert, ok := e.alerts[key]
+			if !ok {
+				alert = &Alert{
+					Rule:      rule.Name,
+					MetricID:  metric.ID,
+					MType:     metric.MType,
+					Labels:    metric.Labels,
+					Severity:  rule.Severity,
+					State:     StatePending,
+					Op:        rule.Op,
+					Threshold: rule.Threshold,
+					ActiveAt:  now,
+				}
+				e.alerts[key] = alert
+			}
+			alert.Value = value
+			if alert.State == StatePending && now.Sub(alert.ActiveAt) >= rule.For {
+				firedAt := now
+				alert.State = StateFiring
+				alert.FiredAt = &firedAt
+				notifications = append(notifications, *alert)
+			}
+		}
+	}
+	for key, alert := range e.alerts {
+		if active[key] {
+			continue
+		}
+		delete(e.alerts, key)
+		if alert.State == StateFiring {
+			resolvedAt := now
+			alert.State = StateResolved
+			alert.ResolvedAt = &resolvedAt
+			notifications = append(notifications, *alert)
+		}
+	}
+	sortAlerts(notifications)
+	return notifications
+}
+
+// ActiveAlerts возвращает оповещения в состояниях pending и firing.
+func (e *Engine) ActiveAlerts() []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	alerts := make([]Alert, 0, len(e.alerts))
+	for _, alert := range e.alerts {
+		alerts = append(alerts, *alert)
+	}
+	sortAlerts(alerts)
+	return alerts
+}
+
+func sortAlerts(alerts []Alert) {
+	sort.Slice(alerts, func(i, j int) bool {
+		if alerts[i].Rule == alerts[j].Rule {
+			return models.SeriesKey(alerts[i].MetricID, alerts[i].Labels) <
+				models.SeriesKey(alerts[j].MetricID, alerts[j].Labels)
+		}
+		return alerts[i].Rule < alerts[j].Rule
+	})
+}
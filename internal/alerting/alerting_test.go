@@ -0,0 +1,196 @@
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eac0de/getmetrics/internal/models"
+	"github.com/eac0de/getmetrics/pkg/retry"
+	"github.com/stretchr/testify/assert"
+)
+
+type staticStore struct {
+	metrics []*models.Metric
+}
+
+func (s *staticStore) ListAllMetrics(ctx context.Context) ([]*models.Metric, error) {
+	return s.metrics, nil
+}
+
+func gauge(id string, value float64) *models.Metric {
+	return &models.Metric{ID: id, MType: models.Gauge, Value: &value}
+}
+
+// webhookReceiver запускает локальный сервер, сохраняющий полученные уведомления.
+func webhookReceiver(t *testing.T) (*httptest.Server, func() []Alert) {
+	var mu sync.Mutex
+	var received []Alert
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload WebhookPayload
+		err := json.NewDecoder(r.Body).Decode(&payload)
+		assert.NoError(t, err)
+		mu.Lock()
+		received = append(received, payload.Alerts...)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	return server, func() []Alert {
+		mu.Lock()
+		defer mu.Unlock()
+		return received
+	}
+}
+
+func TestEngineStateTransitions(t *testing.T) {
+	server, received := webhookReceiver(t)
+	defer server.Close()
+
+	store := &staticStore{metrics: []*models.Metric{gauge("CPUutilization1", 95), gauge("CPUutilization2", 10)}}
+	rules := []Rule{{
+		Name:      "HighCPU",
+		Metric:    "CPUutilization*",
+		MType:     models.Gauge,
+		Op:        OpGreater,
+		Threshold: 90,
+		For:       time.Minute,
+		Severity:  "critical",
+	}}
+	engine := NewEngine(store, rules, NewWebhookNotifier([]string{server.URL}, retry.Policy{}), slog.Default())
+	ctx := context.Background()
+	now := time.Unix(1000, 0)
+
+	// Условие выполняется, но еще не дольше For
+	assert.NoError(t, engine.Evaluate(ctx, now))
+	assert.NoError(t, engine.SendNotifications(ctx))
+	alerts := engine.ActiveAlerts()
+	assert.Len(t, alerts, 1)
+	assert.Equal(t, StatePending, alerts[0].State)
+	assert.Equal(t, "CPUutilization1", alerts[0].MetricID)
+	assert.Empty(t, received())
+
+	// Условие выполняется дольше For
+	assert.NoError(t, engine.Evaluate(ctx, now.Add(time.Minute)))
+	assert.NoError(t, engine.SendNotifications(ctx))
+	alerts = engine.ActiveAlerts()
+	assert.Len(t, alerts, 1)
+	assert.Equal(t, StateFiring, alerts[0].State)
+	assert.Len(t, received(), 1)
+	assert.Equal(t, StateFiring, received()[0].State)
+
+	// Повторное вычисление не отправляет уведомление снова
+	assert.NoError(t, engine.Evaluate(ctx, now.Add(2*time.Minute)))
+	assert.NoError(t, engine.SendNotifications(ctx))
+	assert.Len(t, received(), 1)
+
+	// Условие перестало выполняться
+	store.metrics = []*models.Metric{gauge("CPUutilization1", 50)}
+	assert.NoError(t, engine.Evaluate(ctx, now.Add(3*time.Minute)))
+	assert.NoError(t, engine.SendNotifications(ctx))
+	assert.Empty(t, engine.ActiveAlerts())
+	assert.Len(t, received(), 2)
+	assert.Equal(t, StateResolved, received()[1].State)
+	assert.NotNil(t, received()[1].ResolvedAt)
+}
+
+func TestEnginePendingAlertIsDroppedSilently(t *testing.T) {
+	server, received := webhookReceiver(t)
+	defer server.Close()
+
+	store := &staticStore{metrics: []*models.Metric{gauge("HeapAlloc", 100)}}
+	rules := []Rule{{Name: "HighHeap", Metric: "HeapAlloc", MType: models.Gauge, Op: OpGreaterEqual, Threshold: 100, For: time.Minute}}
+	engine := NewEngine(store, rules, NewWebhookNotifier([]string{server.URL}, retry.Policy{}), slog.Default())
+	now := time.Unix(1000, 0)
+
+	assert.NoError(t, engine.Evaluate(context.Background(), now))
+	assert.Len(t, engine.ActiveAlerts(), 1)
+	store.metrics = nil
+	assert.NoError(t, engine.Evaluate(context.Background(), now.Add(time.Second)))
+	assert.NoError(t, engine.SendNotifications(context.Background()))
+	assert.Empty(t, engine.ActiveAlerts())
+	assert.Empty(t, received())
+}
+
+func TestEngineKeepsUnsentNotifications(t *testing.T) {
+	var mu sync.Mutex
+	failing := true
+	var received []Alert
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if failing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var payload WebhookPayload
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		received = append(received, payload.Alerts...)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	store := &staticStore{metrics: []*models.Metric{gauge("HeapAlloc", 200)}}
+	rules := []Rule{{Name: "HighHeap", Metric: "HeapAlloc", MType: models.Gauge, Op: OpGreater, Threshold: 100}}
+	engine := NewEngine(store, rules, NewWebhookNotifier([]string{server.URL}, retry.Policy{}), slog.Default())
+	ctx := context.Background()
+	now := time.Unix(1000, 0)
+
+	assert.NoError(t, engine.Evaluate(ctx, now))
+	assert.Error(t, engine.SendNotifications(ctx))
+
+	// Уведомление о срабатывании не потеряно и отправляется вместе с уведомлением о разрешении
+	mu.Lock()
+	failing = false
+	mu.Unlock()
+	store.metrics = nil
+	assert.NoError(t, engine.Evaluate(ctx, now.Add(time.Minute)))
+	assert.NoError(t, engine.SendNotifications(ctx))
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(t, received, 2)
+	assert.Equal(t, StateFiring, received[0].State)
+	assert.Equal(t, StateResolved, received[1].State)
+}
+
+func TestWebhookNotifierRetry(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	notifier := NewWebhookNotifier([]string{server.URL}, retry.Policy{Attempts: 3, BaseDelay: time.Millisecond})
+	err := notifier.Notify(context.Background(), []Alert{{Rule: "test", State: StateFiring}})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+}
+
+func TestEngineSeparatesLabeledSeries(t *testing.T) {
+	host1 := gauge("HeapAlloc", 200)
+	host1.Labels = models.Labels{"host": "host1"}
+	host2 := gauge("HeapAlloc", 300)
+	host2.Labels = models.Labels{"host": "host2"}
+	store := &staticStore{metrics: []*models.Metric{host1, host2}}
+	rules := []Rule{{Name: "HighHeap", Metric: "HeapAlloc", MType: models.Gauge, Op: OpGreater, Threshold: 100}}
+	engine := NewEngine(store, rules, nil, slog.Default())
+
+	assert.NoError(t, engine.Evaluate(context.Background(), time.Unix(1000, 0)))
+	alerts := engine.ActiveAlerts()
+	assert.Len(t, alerts, 2)
+	assert.Equal(t, models.Labels{"host": "host1"}, alerts[0].Labels)
+	assert.Equal(t, models.Labels{"host": "host2"}, alerts[1].Labels)
+	assert.Equal(t, StateFiring, alerts[0].State)
+}
@@ -0,0 +1,66 @@
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	stderr "errors"
+	"net/http"
+
+	"github.com/eac0de/getmetrics/pkg/retry"
+	"github.com/go-resty/resty/v2"
+)
+
+// Notifier отправляет уведомления об изменении состояния оповещений.
+type Notifier interface {
+	Notify(ctx context.Context, alerts []Alert) error
+}
+
+// WebhookPayload - тело запроса, отправляемого на webhook.
+type WebhookPayload struct {
+	Alerts []Alert `json:"alerts"`
+}
+
+// WebhookNotifier отправляет уведомления POST-запросом в формате JSON на каждый из адресов.
+type WebhookNotifier struct {
+	urls        []string
+	client      *resty.Client
+	retryPolicy retry.Policy
+}
+
+func NewWebhookNotifier(urls []string, retryPolicy retry.Policy) *WebhookNotifier {
+	return &WebhookNotifier{
+		urls:        urls,
+		client:      resty.New(),
+		retryPolicy: retryPolicy,
+	}
+}
+
+// Notify отправляет оповещения на все адреса. Ошибка одного адреса не мешает отправке на остальные.
+func (n *WebhookNotifier) Notify(ctx context.Context, alerts []Alert) error {
+	body, err := json.Marshal(WebhookPayload{Alerts: alerts})
+	if err != nil {
+		return err
+	}
+	var errsList []error
+	for _, url := range n.urls {
+		err := n.retryPolicy.Do(ctx, func() error {
+			resp, err := n.client.
+				R().
+				SetContext(ctx).
+				SetHeader("Content-Type", "application/json").
+				SetBody(body).
+				Post(url)
+			if err != nil {
+				return err
+			}
+			if resp.StatusCode() < http.StatusOK || resp.StatusCode() >= http.StatusMultipleChoices {
+				return &retry.StatusError{StatusCode: resp.StatusCode(), Body: string(resp.Body())}
+			}
+			return nil
+		}, retry.IsRetriableHTTP)
+		if err != nil {
+			errsList = append(errsList, err)
+		}
+	}
+	return stderr.Join(errsList...)
+}
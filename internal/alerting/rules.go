@@ -0,0 +1,155 @@
+package alerting
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"time"
+
+	"github.com/eac0de/getmetrics/internal/models"
+	"gopkg.in/yaml.v3"
+)
+
+// Операторы сравнения значения метрики с порогом.
+const (
+	OpGreater      = ">"
+	OpGreaterEqual = ">="
+	OpLess         = "<"
+	OpLessEqual    = "<="
+	OpEqual        = "=="
+	OpNotEqual     = "!="
+)
+
+// Rule описывает правило оповещения.
+type Rule struct {
+	// Name - уникальное имя правила.
+	Name string `yaml:"name" json:"name"`
+	// Metric - имя метрики или шаблон в формате path.Match, например "CPUutilization*".
+	Metric string `yaml:"metric" json:"metric"`
+	// MType - тип метрики: gauge, counter или histogram.
+	MType string `yaml:"type" json:"type"`
+	// Quantile - квантиль гистограммы, с которым сравнивается порог. Только для histogram.
+	Quantile float64 `yaml:"quantile" json:"quantile,omitempty"`
+	// Labels - метки, которые должны быть у метрики. Необязательны.
+	Labels models.Labels `yaml:"labels" json:"labels,omitempty"`
+	// Op - оператор сравнения значения метрики с порогом.
+	Op string `yaml:"op" json:"op"`
+	// Threshold - пороговое значение.
+	Threshold float64 `yaml:"threshold" json:"threshold"`
+	// For - как долго условие должно выполняться, прежде чем оповещение сработает.
+	For time.Duration `yaml:"for" json:"for"`
+	// Severity - важность оповещения, например warning или critical.
+	Severity string `yaml:"severity" json:"severity"`
+}
+
+type rulesFile struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// LoadRules читает правила оповещения из YAML-файла и проверяет их.
+func LoadRules(filename string) ([]Rule, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var file rulesFile
+	err = yaml.Unmarshal(data, &file)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool, len(file.Rules))
+	for _, rule := range file.Rules {
+		err = rule.Validate()
+		if err != nil {
+			return nil, err
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("duplicate rule name: %s", rule.Name)
+		}
+		names[rule.Name] = true
+	}
+	return file.Rules, nil
+}
+
+// Validate проверяет, что правило заполнено корректно.
+func (r Rule) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("rule name is required")
+	}
+	if r.Metric == "" {
+		return fmt.Errorf("rule %s: metric is required", r.Name)
+	}
+	if _, err := path.Match(r.Metric, ""); err != nil {
+		return fmt.Errorf("rule %s: invalid metric pattern: %w", r.Name, err)
+	}
+	if err := r.Labels.Validate(); err != nil {
+		return fmt.Errorf("rule %s: %w", r.Name, err)
+	}
+	switch r.MType {
+	case models.Gauge, models.Counter:
+	case models.Histogram:
+		if r.Quantile <= 0 || r.Quantile > 1 {
+			return fmt.Errorf("rule %s: quantile must be in (0, 1] for histogram", r.Name)
+		}
+	default:
+		return fmt.Errorf("rule %s: invalid metric type: %s", r.Name, r.MType)
+	}
+	switch r.Op {
+	case OpGreater, OpGreaterEqual, OpLess, OpLessEqual, OpEqual, OpNotEqual:
+	default:
+		return fmt.Errorf("rule %s: invalid operator: %s", r.Name, r.Op)
+	}
+	if r.For < 0 {
+		return fmt.Errorf("rule %s: for must not be negative", r.Name)
+	}
+	return nil
+}
+
+// Matches сообщает, подходит ли метрика под селектор правила.
+func (r Rule) Matches(metric *models.Metric) bool {
+	if metric.MType != r.MType || !metric.Labels.Matches(r.Labels) {
+		return false
+	}
+	ok, _ := path.Match(r.Metric, metric.ID)
+	return ok
+}
+
+// Value возвращает значение метрики, с которым сравнивается порог.
+// Для гистограммы это оценка квантиля Quantile.
+func (r Rule) Value(metric *models.Metric) float64 {
+	switch metric.MType {
+	case models.Gauge:
+		if metric.Value != nil {
+			return *metric.Value
+		}
+	case models.Counter:
+		if metric.Delta != nil {
+			return float64(*metric.Delta)
+		}
+	case models.Histogram:
+		if metric.Histogram != nil {
+			return metric.Histogram.Quantile(r.Quantile)
+		}
+	}
+	return math.NaN()
+}
+
+// Compare сообщает, выполняется ли условие правила для значения.
+func (r Rule) Compare(value float64) bool {
+	switch r.Op {
+	case OpGreater:
+		return value > r.Threshold
+	case OpGreaterEqual:
+		return value >= r.Threshold
+	case OpLess:
+		return value < r.Threshold
+	case OpLessEqual:
+		return value <= r.Threshold
+	case OpEqual:
+		return value == r.Threshold
+	case OpNotEqual:
+		return value != r.Threshold
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package alerting
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/eac0de/getmetrics/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadRules(t *testing.T) {
+	dir := t.TempDir()
+	writeRules := func(data string) string {
+		filename := filepath.Join(dir, "rules.yml")
+		assert.NoError(t, os.WriteFile(filename, []byte(data), 0644))
+		return filename
+	}
+
+	t.Run("success", func(t *testing.T) {
+		rules, err := LoadRules(writeRules(`
+rules:
+  - name: HighHeap
+    metric: HeapAlloc
+    type: gauge
+    op: ">"
+    threshold: 1024
+    for: 1m
+    severity: warning
+`))
+		assert.NoError(t, err)
+		assert.Equal(t, []Rule{{
+			Name:      "HighHeap",
+			Metric:    "HeapAlloc",
+			MType:     models.Gauge,
+			Op:        OpGreater,
+			Threshold: 1024,
+			For:       time.Minute,
+			Severity:  "warning",
+		}}, rules)
+	})
+	t.Run("invalid operator", func(t *testing.T) {
+		_, err := LoadRules(writeRules("rules:\n  - {name: a, metric: b, type: gauge, op: '=>'}\n"))
+		assert.Error(t, err)
+	})
+	t.Run("duplicate name", func(t *testing.T) {
+		_, err := LoadRules(writeRules("rules:\n" +
+			"  - {name: a, metric: b, type: gauge, op: '>'}\n" +
+			"  - {name: a, metric: c, type: gauge, op: '>'}\n"))
+		assert.Error(t, err)
+	})
+	t.Run("repository example", func(t *testing.T) {
+		_, err := LoadRules("../../configs/alerts.yml")
+		assert.NoError(t, err)
+	})
+}
+
+func TestRuleMatchesAndCompare(t *testing.T) {
+	rule := Rule{Name: "r", Metric: "CPU*", MType: models.Gauge, Op: OpLessEqual, Threshold: 10}
+	assert.True(t, rule.Matches(gauge("CPUutilization1", 1)))
+	assert.False(t, rule.Matches(gauge("HeapAlloc", 1)))
+	assert.False(t, rule.Matches(&models.Metric{ID: "CPU", MType: models.Counter}))
+	rule.Labels = models.Labels{"host": "host1"}
+	assert.False(t, rule.Matches(gauge("CPUutilization1", 1)))
+	assert.True(t, rule.Matches(&models.Metric{ID: "CPU1", MType: models.Gauge, Labels: models.Labels{"host": "host1", "instance": "a"}}))
+	assert.True(t, rule.Compare(10))
+	assert.False(t, rule.Compare(10.5))
+}
+
+func TestRuleHistogramQuantile(t *testing.T) {
+	rule := Rule{Name: "SlowGC", Metric: "GCPauses", MType: models.Histogram, Op: OpGreater, Threshold: 1.5, Quantile: 0.9}
+	assert.NoError(t, rule.Validate())
+	metric := &models.Metric{
+		ID:        "GCPauses",
+		MType:     models.Histogram,
+		Histogram: &models.HistogramValue{Bounds: []float64{1, 2}, Counts: []uint64{5, 5, 0}, Count: 10},
+	}
+	assert.InDelta(t, 1.8, rule.Value(metric), 1e-9)
+	assert.True(t, rule.Compare(rule.Value(metric)))
+
+	rule.Quantile = 0
+	assert.Error(t, rule.Validate())
+}
@@ -0,0 +1,77 @@
+// Package grpchandlers предоставляет gRPC-обработчики для управления метриками.
+//
+// Обработчики используют то же хранилище и ту же логику валидации и объединения
+// счетчиков, что и HTTP-обработчики из пакета handlers.
+package grpchandlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/eac0de/getmetrics/internal/api/handlers"
+	"github.com/eac0de/getmetrics/internal/api/pb"
+	"github.com/eac0de/getmetrics/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// MetricsServer реализует gRPC-сервис MetricsService.
+type MetricsServer struct {
+	pb.UnimplementedMetricsServiceServer
+	metricsHandlers *handlers.MetricsHandlers
+}
+
+// NewMetricsServer создает новый экземпляр MetricsServer.
+//
+// Принимает интерфейс хранилища метрик и логгер.
+func NewMetricsServer(metricsStore handlers.IMetricsStore, logger *slog.Logger) *MetricsServer {
+	return &MetricsServer{
+		metricsHandlers: handlers.NewMetricsHandlers(metricsStore, "", logger),
+	}
+}
+
+// UpdateMetrics обновляет пакет метрик.
+func (s *MetricsServer) UpdateMetrics(ctx context.Context, req *pb.UpdateMetricsRequest) (*pb.UpdateMetricsResponse, error) {
+	metricsList, err := s.metricsHandlers.UpdateMetrics(ctx, pb.ToModels(req.GetMetrics()))
+	if err != nil {
+		return nil, toStatusError(ctx, err)
+	}
+	return &pb.UpdateMetricsResponse{Metrics: pb.FromModels(metricsList)}, nil
+}
+
+// GetMetric возвращает метрику по имени, типу и меткам.
+func (s *MetricsServer) GetMetric(ctx context.Context, req *pb.GetMetricRequest) (*pb.GetMetricResponse, error) {
+	metric, err := s.metricsHandlers.MetricsStore.GetMetric(ctx, req.GetId(), req.GetType(), pb.ToLabels(req.GetLabels()))
+	if err != nil {
+		return nil, toStatusError(ctx, err)
+	}
+	return &pb.GetMetricResponse{Metric: pb.FromModel(*metric)}, nil
+}
+
+// ListMetrics возвращает все метрики из хранилища.
+func (s *MetricsServer) ListMetrics(ctx context.Context, req *pb.ListMetricsRequest) (*pb.ListMetricsResponse, error) {
+	metrics, err := s.metricsHandlers.MetricsStore.ListAllMetrics(ctx)
+	if err != nil {
+		return nil, toStatusError(ctx, err)
+	}
+	resp := &pb.ListMetricsResponse{Metrics: make([]*pb.Metric, 0, len(metrics))}
+	for _, metric := range metrics {
+		resp.Metrics = append(resp.Metrics, pb.FromModel(*metric))
+	}
+	return resp, nil
+}
+
+// toStatusError преобразует ошибку с HTTP-статусом в ошибку gRPC с соответствующим кодом.
+// К сообщению добавляется идентификатор запроса.
+func toStatusError(ctx context.Context, err error) error {
+	msg, statusCode := errors.GetMessageAndStatusCode(err)
+	code := codes.Internal
+	switch statusCode {
+	case http.StatusBadRequest:
+		code = codes.InvalidArgument
+	case http.StatusNotFound:
+		code = codes.NotFound
+	}
+	return status.Error(code, errors.WithRequestID(ctx, msg))
+}
@@ -0,0 +1,91 @@
+package grpchandlers
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"testing"
+
+	"github.com/eac0de/getmetrics/internal/api/pb"
+	"github.com/eac0de/getmetrics/internal/models"
+	"github.com/eac0de/getmetrics/internal/storage/memstore"
+	"github.com/eac0de/getmetrics/pkg/interceptors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newTestClient(t *testing.T, serverKey, clientKey string) pb.MetricsServiceClient {
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		interceptors.GetLoggerInterceptor(slog.Default()),
+		interceptors.GetCheckSignInterceptor(serverKey),
+	))
+	pb.RegisterMetricsServiceServer(server, NewMetricsServer(memstore.New(), slog.Default()))
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(
+			interceptors.GzipClientInterceptor,
+			interceptors.GetSignClientInterceptor(clientKey),
+		),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewMetricsServiceClient(conn)
+}
+
+func TestMetricsServer(t *testing.T) {
+	client := newTestClient(t, "mysecretkey", "mysecretkey")
+	ctx := context.Background()
+	delta := func(v int64) *int64 { return &v }
+	value := func(v float64) *float64 { return &v }
+
+	_, err := client.UpdateMetrics(ctx, &pb.UpdateMetricsRequest{Metrics: []*pb.Metric{
+		{Id: "test_counter", Type: models.Counter, Delta: delta(1)},
+		{Id: "test_counter", Type: models.Counter, Delta: delta(2)},
+		{Id: "test_gauge", Type: models.Gauge, Value: value(5)},
+	}})
+	require.NoError(t, err)
+	_, err = client.UpdateMetrics(ctx, &pb.UpdateMetricsRequest{Metrics: []*pb.Metric{
+		{Id: "test_counter", Type: models.Counter, Delta: delta(4)},
+	}})
+	require.NoError(t, err)
+
+	t.Run("get metric", func(t *testing.T) {
+		resp, err := client.GetMetric(ctx, &pb.GetMetricRequest{Id: "test_counter", Type: models.Counter})
+		require.NoError(t, err)
+		assert.Equal(t, int64(7), resp.GetMetric().GetDelta())
+	})
+	t.Run("list metrics", func(t *testing.T) {
+		resp, err := client.ListMetrics(ctx, &pb.ListMetricsRequest{})
+		require.NoError(t, err)
+		assert.Len(t, resp.GetMetrics(), 2)
+	})
+	t.Run("not found", func(t *testing.T) {
+		_, err := client.GetMetric(ctx, &pb.GetMetricRequest{Id: "unknown", Type: models.Gauge})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+	t.Run("invalid metric", func(t *testing.T) {
+		_, err := client.UpdateMetrics(ctx, &pb.UpdateMetricsRequest{Metrics: []*pb.Metric{
+			{Id: "test_gauge", Type: models.Gauge},
+		}})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
+
+func TestMetricsServerInvalidSign(t *testing.T) {
+	client := newTestClient(t, "mysecretkey", "otherkey")
+	_, err := client.ListMetrics(context.Background(), &pb.ListMetricsRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
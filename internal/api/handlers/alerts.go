@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/eac0de/getmetrics/internal/alerting"
+)
+
+// IAlertsSource интерфейс источника активных оповещений.
+type IAlertsSource interface {
+	ActiveAlerts() []alerting.Alert
+}
+
+// AlertsResponse представляет ответ со списком активных оповещений.
+type AlertsResponse struct {
+	Alerts []alerting.Alert `json:"alerts"`
+}
+
+// AlertsHandler возвращает HTTP-обработчик, отдающий активные оповещения в формате JSON.
+//
+// Возвращает оповещения в состояниях pending и firing. Если оповещения не настроены,
+// возвращает пустой список.
+func (h *MetricsHandlers) AlertsHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data, err := json.Marshal(AlertsResponse{Alerts: h.activeAlerts()})
+		if err != nil {
+			http.Error(w, "Invalid server data", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		h.addSign(w, data)
+		w.WriteHeader(http.StatusOK)
+		w.Write(data)
+	}
+}
+
+func (h *MetricsHandlers) activeAlerts() []alerting.Alert {
+	if h.Alerts == nil {
+		return []alerting.Alert{}
+	}
+	return h.Alerts.ActiveAlerts()
+}
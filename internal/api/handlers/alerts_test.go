@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/eac0de/getmetrics/internal/alerting"
+	"github.com/eac0de/getmetrics/internal/models"
+	"github.com/eac0de/getmetrics/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+type staticAlerts []alerting.Alert
+
+func (a staticAlerts) ActiveAlerts() []alerting.Alert {
+	return a
+}
+
+var testAlerts = staticAlerts{
+	{
+		Rule:      "HighHeapAlloc",
+		MetricID:  "HeapAlloc",
+		MType:     models.Gauge,
+		Severity:  "warning",
+		State:     alerting.StateFiring,
+		Op:        alerting.OpGreater,
+		Threshold: 1,
+		Value:     1.5,
+		ActiveAt:  time.Unix(1000, 0).UTC(),
+	},
+}
+
+func TestAlertsHandler(t *testing.T) {
+	t.Run("no alerting", func(t *testing.T) {
+		mh := NewMetricsHandlers(nil, "", slog.Default())
+		rec := httptest.NewRecorder()
+		mh.AlertsHandler()(rec, httptest.NewRequest(http.MethodGet, "/api/v1/alerts", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"alerts":[]}`, rec.Body.String())
+	})
+	t.Run("active alerts", func(t *testing.T) {
+		mh := NewMetricsHandlers(nil, "", slog.Default())
+		mh.Alerts = testAlerts
+		rec := httptest.NewRecorder()
+		mh.AlertsHandler()(rec, httptest.NewRequest(http.MethodGet, "/api/v1/alerts", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var resp AlertsResponse
+		err := json.NewDecoder(rec.Body).Decode(&resp)
+		assert.NoError(t, err)
+		assert.Equal(t, []alerting.Alert(testAlerts), resp.Alerts)
+	})
+}
+
+func TestShowMetricsSummaryHandlerAlerts(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	metricsStore := mocks.NewMockIMetricsStore(ctrl)
+	metricsStore.EXPECT().ListAllMetrics(gomock.Any()).Return([]*models.Metric{
+		{ID: "HeapAlloc", MType: models.Gauge, Value: func(v float64) *float64 { return &v }(1.5)},
+		{ID: "PollCount", MType: models.Counter, Delta: func(v int64) *int64 { return &v }(5)},
+	}, nil)
+	// История всех строк загружается одним запросом
+	metricsStore.EXPECT().QueryRanges(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ any, series []models.Metric, _, _ any) ([][]models.Sample, error) {
+			return make([][]models.Sample, len(series)), nil
+		},
+	)
+	mh := NewMetricsHandlers(metricsStore, "", slog.Default())
+	mh.Alerts = testAlerts
+	rec := httptest.NewRecorder()
+	mh.ShowMetricsSummaryHandler()(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	body := rec.Body.String()
+	assert.Contains(t, body, "Active Alerts")
+	assert.Contains(t, body, `<tr data-name="HeapAlloc" class="alert-firing">`)
+	assert.Contains(t, body, `<td class="number">1.5</td>`)
+	assert.Contains(t, body, `<tr data-name="PollCount" >`)
+	assert.Contains(t, body, `<td class="number">5</td>`)
+}
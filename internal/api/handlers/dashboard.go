@@ -0,0 +1,374 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eac0de/getmetrics/internal/alerting"
+	"github.com/eac0de/getmetrics/internal/models"
+	"github.com/eac0de/getmetrics/templates"
+)
+
+const (
+	// dashboardHistoryRange - интервал истории, по которой строятся графики и скорость счетчиков.
+	dashboardHistoryRange = 15 * time.Minute
+	// sparklinePoints - количество точек графика.
+	sparklinePoints = 30
+	// sparklineWidth и sparklineHeight - размеры области графика в единицах viewBox.
+	sparklineWidth  = 120
+	sparklineHeight = 24
+	// defaultDashboardRefresh - интервал автообновления страницы по умолчанию, в секундах.
+	defaultDashboardRefresh = 10
+)
+
+// Колонки, по которым можно сортировать страницу.
+const (
+	sortByName  = "name"
+	sortByType  = "type"
+	sortByValue = "value"
+	sortByRate  = "rate"
+)
+
+var summaryTemplate = template.Must(template.ParseFS(templates.FS, "metrics_summary.html"))
+
+// summaryRow - строка страницы со списком метрик.
+type summaryRow struct {
+	*models.Metric
+	AlertState string // Состояние оповещения по метрике, пустое, если оповещений нет
+	Display    string // Значение метрики для отображения
+	Rate       string // Скорость счетчика в секунду, пустая, если ее не удалось вычислить
+	Sparkline  string // Точки графика последних значений для SVG polyline
+	value      float64
+	rate       float64
+}
+
+// summaryGroup - группа строк с общим префиксом имени.
+type summaryGroup struct {
+	Prefix string
+	Rows   []summaryRow
+}
+
+// summaryFilter - параметры отображения страницы из строки запроса.
+type summaryFilter struct {
+	Type    string
+	Name    string
+	Sort    string
+	Desc    bool
+	Group   bool
+	Refresh int
+	labels  models.Labels
+}
+
+// summaryColumn - заголовок колонки со ссылкой для сортировки.
+type summaryColumn struct {
+	Title string
+	URL   string
+	// Order - направление текущей сортировки по колонке: asc, desc или пустая строка.
+	Order string
+}
+
+// summaryPage - данные шаблона страницы со списком метрик.
+type summaryPage struct {
+	Alerts  []alerting.Alert
+	Groups  []summaryGroup
+	Columns []summaryColumn
+	Filter  summaryFilter
+	Labels  []string // Фильтр по меткам в виде name=value для повторной отправки формы
+	Shown   int
+	Total   int
+}
+
+// parseSummaryFilter разбирает параметры страницы: type, name, sort, order, group, refresh и label.
+func parseSummaryFilter(query url.Values) (summaryFilter, error) {
+	filter := summaryFilter{
+		Type:    query.Get("type"),
+		Name:    query.Get("name"),
+		Sort:    query.Get("sort"),
+		Desc:    query.Get("order") == "desc",
+		Refresh: defaultDashboardRefresh,
+	}
+	switch filter.Type {
+	case "", models.Gauge, models.Counter, models.Histogram:
+	default:
+		return summaryFilter{}, fmt.Errorf("invalid metric type: %s", filter.Type)
+	}
+	switch filter.Sort {
+	case "":
+		filter.Sort = sortByName
+	case sortByName, sortByType, sortByValue, sortByRate:
+	default:
+		return summaryFilter{}, fmt.Errorf("invalid sort column: %s", filter.Sort)
+	}
+	if query.Has("group") {
+		group, err := strconv.ParseBool(query.Get("group"))
+		if err != nil {
+			return summaryFilter{}, fmt.Errorf("invalid group parameter")
+		}
+		filter.Group = group
+	}
+	if query.Has("refresh") {
+		refresh, err := strconv.Atoi(query.Get("refresh"))
+		if err != nil || refresh < 0 {
+			return summaryFilter{}, fmt.Errorf("invalid refresh parameter")
+		}
+		filter.Refresh = refresh
+	}
+	labels, err := parseLabelsQuery(query)
+	if err != nil {
+		return summaryFilter{}, err
+	}
+	filter.labels = labels
+	return filter, nil
+}
+
+// matches проверяет, подходит ли метрика под фильтр по типу, части имени и меткам.
+func (f *summaryFilter) matches(metric *models.Metric) bool {
+	if f.Type != "" && metric.MType != f.Type {
+		return false
+	}
+	if f.Name != "" && !strings.Contains(strings.ToLower(metric.ID), strings.ToLower(f.Name)) {
+		return false
+	}
+	return metric.Labels.Matches(f.labels)
+}
+
+// buildSummaryPage отбирает, сортирует и группирует метрики и дополняет их историей.
+func (h *MetricsHandlers) buildSummaryPage(ctx context.Context, metrics []*models.Metric, filter summaryFilter, query url.Values) summaryPage {
+	page := summaryPage{
+		Alerts:  h.activeAlerts(),
+		Filter:  filter,
+		Labels:  query[labelQueryParam],
+		Total:   len(metrics),
+		Columns: summaryColumns(filter, query),
+	}
+	alertStates := make(map[string]string, len(page.Alerts))
+	for _, alert := range page.Alerts {
+		key := alert.MType + ":" + models.SeriesKey(alert.MetricID, alert.Labels)
+		if alertStates[key] != alerting.StateFiring {
+			alertStates[key] = alert.State
+		}
+	}
+	to := time.Now()
+	from := to.Add(-dashboardHistoryRange)
+	var rows []summaryRow
+	for _, metric := range metrics {
+		if !filter.matches(metric) {
+			continue
+		}
+		row := summaryRow{
+			Metric:     metric,
+			AlertState: alertStates[metric.MType+":"+metric.SeriesKey()],
+			rate:       math.NaN(),
+		}
+		switch metric.MType {
+		case models.Gauge:
+			row.value = *metric.Value
+			row.Display = strconv.FormatFloat(row.value, 'g', -1, 64)
+		case models.Counter:
+			row.value = float64(*metric.Delta)
+			row.Display = strconv.FormatInt(*metric.Delta, 10)
+		case models.Histogram:
+			row.value = float64(metric.Histogram.Count)
+			row.Display = metric.Histogram.String()
+		}
+		rows = append(rows, row)
+	}
+	h.addSummaryHistory(ctx, rows, from, to)
+	sortSummaryRows(rows, filter.Sort, filter.Desc)
+	page.Shown = len(rows)
+	page.Groups = groupSummaryRows(rows, filter.Group)
+	return page
+}
+
+// addSummaryHistory загружает историю всех показанных рядов одним запросом к хранилищу
+// и заполняет спарклайны и скорость счетчиков. Гистограммы истории не имеют.
+func (h *MetricsHandlers) addSummaryHistory(ctx context.Context, rows []summaryRow, from time.Time, to time.Time) {
+	var series []models.Metric
+	var indexes []int
+	for i, row := range rows {
+		if row.MType == models.Histogram {
+			continue
+		}
+		series = append(series, *row.Metric)
+		indexes = append(indexes, i)
+	}
+	if len(series) == 0 {
+		return
+	}
+	history, err := h.MetricsStore.QueryRanges(ctx, series, from, to)
+	if err != nil {
+		h.Logger.WarnContext(ctx, "Metrics history loading error", "error", err)
+		return
+	}
+	for j, i := range indexes {
+		row := &rows[i]
+		row.Sparkline = sparkline(history[j], from, to, row.MType == models.Counter)
+		if rate, ok := counterRate(history[j]); ok && row.MType == models.Counter {
+			row.rate = rate
+			row.Rate = strconv.FormatFloat(rate, 'g', 4, 64) + "/s"
+		}
+	}
+}
+
+// summaryColumns возвращает заголовки колонок. Повторный выбор колонки меняет направление сортировки.
+func summaryColumns(filter summaryFilter, query url.Values) []summaryColumn {
+	columns := []summaryColumn{
+		{Title: "Name"}, {Title: "Type"}, {Title: "Value"}, {Title: "Rate"},
+	}
+	for i, column := range []string{sortByName, sortByType, sortByValue, sortByRate} {
+		q := url.Values{}
+		for name, values := range query {
+			q[name] = values
+		}
+		q.Set("sort", column)
+		q.Del("order")
+		if column == filter.Sort {
+			columns[i].Order = "asc"
+			if filter.Desc {
+				columns[i].Order = "desc"
+			} else {
+				q.Set("order", "desc")
+			}
+		}
+		columns[i].URL = "?" + q.Encode()
+	}
+	return columns
+}
+
+// sortSummaryRows сортирует строки по колонке. Строки с равными значениями и без скорости
+// упорядочиваются по имени и меткам, строки без скорости всегда идут последними.
+func sortSummaryRows(rows []summaryRow, column string, desc bool) {
+	byName := func(a, b summaryRow) bool {
+		if a.ID == b.ID {
+			return a.Labels.String() < b.Labels.String()
+		}
+		return a.ID < b.ID
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if desc {
+			a, b = b, a
+		}
+		switch column {
+		case sortByType:
+			if a.MType != b.MType {
+				return a.MType < b.MType
+			}
+		case sortByValue:
+			if a.value != b.value {
+				return a.value < b.value
+			}
+		case sortByRate:
+			aNaN, bNaN := math.IsNaN(rows[i].rate), math.IsNaN(rows[j].rate)
+			if aNaN || bNaN {
+				if aNaN != bNaN {
+					return bNaN
+				}
+				break
+			}
+			if a.rate != b.rate {
+				return a.rate < b.rate
+			}
+		}
+		return byName(a, b)
+	})
+}
+
+// groupSummaryRows разбивает строки на группы по префиксу имени, сохраняя порядок сортировки внутри групп.
+// Если группировка выключена, возвращает одну группу без префикса.
+func groupSummaryRows(rows []summaryRow, group bool) []summaryGroup {
+	if !group {
+		return []summaryGroup{{Rows: rows}}
+	}
+	index := make(map[string]int)
+	var groups []summaryGroup
+	for _, row := range rows {
+		prefix := metricPrefix(row.ID)
+		i, ok := index[prefix]
+		if !ok {
+			i = len(groups)
+			index[prefix] = i
+			groups = append(groups, summaryGroup{Prefix: prefix})
+		}
+		groups[i].Rows = append(groups[i].Rows, row)
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].Prefix < groups[j].Prefix
+	})
+	return groups
+}
+
+// metricPrefix возвращает часть имени до первого разделителя '.', '_', ':' или '/'.
+// Для имен без разделителя возвращает имя целиком.
+func metricPrefix(id string) string {
+	if i := strings.IndexAny(id, "._:/"); i > 0 {
+		return id[:i]
+	}
+	return id
+}
+
+// counterRate вычисляет среднюю скорость роста счетчика в секунду по истории значений.
+// Уменьшение значения считается сбросом счетчика.
+func counterRate(samples []models.Sample) (float64, bool) {
+	if len(samples) < 2 {
+		return 0, false
+	}
+	elapsed := samples[len(samples)-1].Timestamp.Sub(samples[0].Timestamp).Seconds()
+	if elapsed <= 0 {
+		return 0, false
+	}
+	var increase float64
+	for i := 1; i < len(samples); i++ {
+		increase += counterDelta(samples[i-1].Value, samples[i].Value)
+	}
+	return increase / elapsed, true
+}
+
+func counterDelta(prev, cur float64) float64 {
+	if cur < prev {
+		return cur
+	}
+	return cur - prev
+}
+
+// sparkline возвращает точки графика значений за интервал [from, to] в формате атрибута points
+// SVG polyline. Для счетчиков строится прирост между соседними точками. Если точек меньше двух,
+// возвращает пустую строку.
+func sparkline(samples []models.Sample, from, to time.Time, counter bool) string {
+	points := aggregateSamples(samples, from, to, to.Sub(from)/sparklinePoints, aggregators[AggregationLast])
+	values := make([]float64, 0, len(points))
+	for i, point := range points {
+		if !counter {
+			values = append(values, point.Value)
+		} else if i > 0 {
+			values = append(values, counterDelta(points[i-1].Value, point.Value))
+		}
+	}
+	if len(values) < 2 {
+		return ""
+	}
+	low, high := values[0], values[0]
+	for _, v := range values {
+		low, high = math.Min(low, v), math.Max(high, v)
+	}
+	var b strings.Builder
+	for i, v := range values {
+		y := 0.5
+		if high > low {
+			y = (v - low) / (high - low)
+		}
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		x := float64(i) * sparklineWidth / float64(len(values)-1)
+		fmt.Fprintf(&b, "%.1f,%.1f", x, (1-y)*(sparklineHeight-2)+1)
+	}
+	return b.String()
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eac0de/getmetrics/internal/models"
+	"github.com/eac0de/getmetrics/internal/storage/memstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShowMetricsSummaryHandlerDashboard(t *testing.T) {
+	ctx := context.Background()
+	store := memstore.New()
+	save := func(metric models.Metric) {
+		require.NoError(t, store.SaveMetric(ctx, metric))
+	}
+	gauge := func(id string, v float64) models.Metric {
+		return models.Metric{ID: id, MType: models.Gauge, Value: &v}
+	}
+	counter := func(id string, v int64) models.Metric {
+		return models.Metric{ID: id, MType: models.Counter, Delta: &v}
+	}
+	save(gauge("cpu.user", 3))
+	save(gauge("cpu.system", 7))
+	save(gauge("memory.free", 1))
+	save(counter("cpu.ticks", 10))
+	time.Sleep(20 * time.Millisecond)
+	save(counter("cpu.ticks", 20))
+	save(gauge("cpu.user", 5))
+
+	mh := NewMetricsHandlers(store, "", slog.Default())
+	get := func(query string) string {
+		rec := httptest.NewRecorder()
+		mh.ShowMetricsSummaryHandler()(rec, httptest.NewRequest(http.MethodGet, "/?"+query, nil))
+		require.Equal(t, http.StatusOK, rec.Code, query)
+		return rec.Body.String()
+	}
+
+	body := get("name=CPU&type=gauge&sort=value&order=desc")
+	assert.Contains(t, body, "Showing 2 of 4 metrics")
+	assert.NotContains(t, body, `data-name="memory.free"`)
+	assert.NotContains(t, body, `data-name="cpu.ticks"`)
+	assert.Less(t, strings.Index(body, `data-name="cpu.system"`), strings.Index(body, `data-name="cpu.user"`))
+	assert.Contains(t, body, `<th class="desc"><a href="?name=CPU&amp;sort=value&amp;type=gauge">Value</a></th>`)
+
+	body = get("type=counter")
+	assert.Regexp(t, `<td class="number">20</td>\s*<td class="number">[0-9.e+]+/s</td>`, body)
+
+	body = get("group=true")
+	assert.Contains(t, body, `<tr class="group"><th colspan="5">cpu (3)</th></tr>`)
+	assert.Contains(t, body, `<tr class="group"><th colspan="5">memory (1)</th></tr>`)
+
+	for _, query := range []string{"type=summary", "sort=size", "group=maybe", "refresh=-1", "label=bad"} {
+		rec := httptest.NewRecorder()
+		mh.ShowMetricsSummaryHandler()(rec, httptest.NewRequest(http.MethodGet, "/?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+}
+
+func TestCounterRate(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	samples := []models.Sample{
+		{Timestamp: start, Value: 10},
+		{Timestamp: start.Add(10 * time.Second), Value: 30},
+		// Сброс счетчика
+		{Timestamp: start.Add(20 * time.Second), Value: 5},
+		{Timestamp: start.Add(40 * time.Second), Value: 15},
+	}
+	rate, ok := counterRate(samples)
+	assert.True(t, ok)
+	assert.Equal(t, 35.0/40, rate)
+
+	_, ok = counterRate(samples[:1])
+	assert.False(t, ok)
+}
+
+func TestSparkline(t *testing.T) {
+	to := time.Unix(1700000000, 0)
+	from := to.Add(-dashboardHistoryRange)
+	step := dashboardHistoryRange / sparklinePoints
+	samples := []models.Sample{
+		{Timestamp: from, Value: 1},
+		{Timestamp: from.Add(step), Value: 3},
+		{Timestamp: from.Add(2 * step), Value: 2},
+	}
+	assert.Equal(t, "0.0,23.0 60.0,1.0 120.0,12.0", sparkline(samples, from, to, false))
+	// Для счетчика строится прирост: 2, затем сброс до 2
+	assert.Equal(t, "0.0,12.0 120.0,12.0", sparkline(samples, from, to, true))
+	assert.Empty(t, sparkline(samples[:1], from, to, false))
+}
+
+func TestMetricPrefix(t *testing.T) {
+	assert.Equal(t, "cpu", metricPrefix("cpu.user"))
+	assert.Equal(t, "http", metricPrefix("http_requests_total"))
+	assert.Equal(t, "HeapAlloc", metricPrefix("HeapAlloc"))
+	assert.Equal(t, "_private", metricPrefix("_private"))
+}
+
+func TestSummaryColumns(t *testing.T) {
+	query := url.Values{"sort": {"name"}}
+	columns := summaryColumns(summaryFilter{Sort: sortByName}, query)
+	require.Len(t, columns, 4)
+	assert.Equal(t, summaryColumn{Title: "Name", URL: "?order=desc&sort=name", Order: "asc"}, columns[0])
+	assert.Equal(t, summaryColumn{Title: "Rate", URL: "?sort=rate"}, columns[3])
+}
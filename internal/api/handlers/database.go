@@ -39,8 +39,7 @@ func (h *DatabaseHandlers) PingHandler() func(http.ResponseWriter, *http.Request
 	}
 	return func(w http.ResponseWriter, r *http.Request) {
 		if err := h.Database.PingContext(r.Context()); err != nil {
-			msg, statusCode := errors.GetMessageAndStatusCode(err)
-			http.Error(w, msg, statusCode)
+			errors.WriteHTTPError(w, r, err)
 			return
 		}
 		w.WriteHeader(http.StatusOK)
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eac0de/getmetrics/internal/models"
+	"github.com/eac0de/getmetrics/mocks"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateMetricsJSONHandlerHistogram(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	metricsStore := mocks.NewMockIMetricsStore(ctrl)
+	mh := NewMetricsHandlers(metricsStore, "", slog.Default())
+
+	var saved []models.Metric
+	metricsStore.EXPECT().UpdateMetrics(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ any, metricsList []models.Metric) ([]models.Metric, error) {
+			saved = metricsList
+			return metricsList, nil
+		},
+	)
+
+	body := `[
+		{"id":"Latency","type":"histogram","histogram":{"bounds":[0.1,1],"counts":[2,0,0],"sum":0.1,"count":2}},
+		{"id":"Latency","type":"histogram","histogram":{"bounds":[0.1,1],"counts":[0,1,0],"sum":0.5,"count":1}}
+	]`
+	req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewBufferString(body))
+	rec := httptest.NewRecorder()
+	mh.UpdateMetricsJSONHandler()(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Len(t, saved, 1)
+	assert.Equal(t, []uint64{2, 1, 0}, saved[0].Histogram.Counts)
+	assert.Equal(t, uint64(3), saved[0].Histogram.Count)
+	assert.InDelta(t, 0.6, saved[0].Histogram.Sum, 1e-9)
+}
+
+func TestUpdateMetricsJSONHandlerInvalidHistogram(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mh := NewMetricsHandlers(mocks.NewMockIMetricsStore(ctrl), "", slog.Default())
+	tests := []string{
+		`[{"id":"Latency","type":"histogram"}]`,
+		`[{"id":"Latency","type":"histogram","histogram":{"bounds":[1],"counts":[1],"count":1}}]`,
+		`[{"id":"Latency","type":"histogram","histogram":{"bounds":[1],"counts":[1,0],"count":1}},
+		  {"id":"Latency","type":"histogram","histogram":{"bounds":[2],"counts":[1,0],"count":1}}]`,
+	}
+	for _, body := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewBufferString(body))
+		rec := httptest.NewRecorder()
+		mh.UpdateMetricsJSONHandler()(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+	}
+}
+
+func TestGetMetricHandlerHistogramQuantile(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	metricsStore := mocks.NewMockIMetricsStore(ctrl)
+	mh := NewMetricsHandlers(metricsStore, "", slog.Default())
+	metricsStore.EXPECT().
+		GetMetric(gomock.Any(), "Latency", models.Histogram, gomock.Any()).
+		Return(&models.Metric{
+			ID:        "Latency",
+			MType:     models.Histogram,
+			Histogram: &models.HistogramValue{Bounds: []float64{1, 2}, Counts: []uint64{5, 5, 0}, Sum: 12, Count: 10},
+		}, nil).
+		Times(3)
+
+	r := chi.NewRouter()
+	r.Get("/value/{metricType}/{metricName}", mh.GetMetricHandler())
+	tests := []struct {
+		query      string
+		statusCode int
+		body       string
+	}{
+		{query: "?quantile=0.75", statusCode: http.StatusOK, body: "1.5"},
+		{query: "", statusCode: http.StatusOK, body: "count=10 sum=12 p50=1 p90=1.8 p99=1.98"},
+		{query: "?quantile=2", statusCode: http.StatusBadRequest, body: "quantile must be a number between 0 and 1\n"},
+	}
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/value/histogram/Latency"+test.query, nil))
+		assert.Equal(t, test.statusCode, rec.Code)
+		assert.Equal(t, test.body, rec.Body.String())
+	}
+}
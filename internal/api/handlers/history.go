@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/eac0de/getmetrics/internal/models"
+)
+
+const (
+	// defaultQueryRange - интервал запроса истории, если параметр from не указан.
+	defaultQueryRange = time.Hour
+	// maxQueryPoints - максимальное количество точек в ответе.
+	maxQueryPoints = 11000
+)
+
+// Функции агрегации значений истории метрики.
+const (
+	AggregationAvg  = "avg"
+	AggregationMin  = "min"
+	AggregationMax  = "max"
+	AggregationLast = "last"
+	AggregationSum  = "sum"
+)
+
+// QueryRangeResponse представляет ответ на запрос истории метрики.
+type QueryRangeResponse struct {
+	ID     string          `json:"id"`
+	MType  string          `json:"type"`
+	Labels models.Labels   `json:"labels,omitempty"`
+	Points []models.Sample `json:"points"`
+}
+
+// QueryRangeHandler возвращает HTTP-обработчик для получения истории значений метрики.
+//
+// Параметры запроса: id и type - имя и тип метрики, label - метки ряда в виде name=value
+// (может повторяться), from и to - границы интервала (RFC 3339 или Unix-время в секундах),
+// step - шаг агрегации (например, 30s или 60), agg - функция агрегации (avg, min, max, last, sum).
+// Без step и agg возвращаются все сохраненные значения, но не более maxQueryPoints:
+// для больших интервалов нужно задать step. Если задана только agg,
+// значения агрегируются по всему интервалу. Если задан step без agg, используется last.
+//
+// История счетчика хранит накопленные значения, а не приращения, поэтому их сумма не имеет
+// смысла: agg=sum для счетчиков отклоняется. Прирост счетчика за окно - разность значений last.
+func (h *MetricsHandlers) QueryRangeHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		metricName := query.Get("id")
+		metricType := query.Get("type")
+		if metricName == "" {
+			http.Error(w, "metric name is required", http.StatusBadRequest)
+			return
+		}
+		if metricType != models.Gauge && metricType != models.Counter {
+			http.Error(w, fmt.Sprintf("invalid metric type: %s", metricType), http.StatusBadRequest)
+			return
+		}
+		labels, err := parseLabelsQuery(query)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		to := time.Now()
+		if query.Has("to") {
+			to, err = parseQueryTime(query.Get("to"))
+			if err != nil {
+				http.Error(w, "invalid to parameter", http.StatusBadRequest)
+				return
+			}
+		}
+		from := to.Add(-defaultQueryRange)
+		if query.Has("from") {
+			from, err = parseQueryTime(query.Get("from"))
+			if err != nil {
+				http.Error(w, "invalid from parameter", http.StatusBadRequest)
+				return
+			}
+		}
+		if to.Before(from) {
+			http.Error(w, "to must not be before from", http.StatusBadRequest)
+			return
+		}
+		var step time.Duration
+		if query.Has("step") {
+			step, err = parseQueryStep(query.Get("step"))
+			if err != nil {
+				http.Error(w, "invalid step parameter", http.StatusBadRequest)
+				return
+			}
+			if int64(to.Sub(from)/step) >= maxQueryPoints {
+				http.Error(w, "too many points, increase step", http.StatusBadRequest)
+				return
+			}
+		}
+		agg := query.Get("agg")
+		if agg == "" && step > 0 {
+			agg = AggregationLast
+		}
+		if agg != "" && aggregators[agg] == nil {
+			http.Error(w, fmt.Sprintf("invalid aggregation: %s", agg), http.StatusBadRequest)
+			return
+		}
+		if agg == AggregationSum && metricType == models.Counter {
+			http.Error(w, "sum aggregation is not supported for counters", http.StatusBadRequest)
+			return
+		}
+
+		samples, err := h.MetricsStore.QueryRange(r.Context(), metricName, metricType, labels, from, to)
+		if err != nil {
+			h.writeError(w, r, err)
+			return
+		}
+		if agg != "" {
+			samples = aggregateSamples(samples, from, to, step, aggregators[agg])
+		} else if len(samples) > maxQueryPoints {
+			http.Error(w, "too many points, set step", http.StatusBadRequest)
+			return
+		}
+		data, err := json.Marshal(QueryRangeResponse{
+			ID:     metricName,
+			MType:  metricType,
+			Labels: labels,
+			Points: samples,
+		})
+		if err != nil {
+			http.Error(w, "Invalid server data", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		h.addSign(w, data)
+		w.WriteHeader(http.StatusOK)
+		w.Write(data)
+	}
+}
+
+// aggregators содержит функции агрегации по их именам.
+var aggregators = map[string]func([]float64) float64{
+	AggregationAvg: func(values []float64) float64 {
+		var sum float64
+		for _, v := range values {
+			sum += v
+		}
+		return sum / float64(len(values))
+	},
+	AggregationMin: func(values []float64) float64 {
+		result := math.Inf(1)
+		for _, v := range values {
+			result = math.Min(result, v)
+		}
+		return result
+	},
+	AggregationMax: func(values []float64) float64 {
+		result := math.Inf(-1)
+		for _, v := range values {
+			result = math.Max(result, v)
+		}
+		return result
+	},
+	AggregationLast: func(values []float64) float64 {
+		return values[len(values)-1]
+	},
+	AggregationSum: func(values []float64) float64 {
+		var sum float64
+		for _, v := range values {
+			sum += v
+		}
+		return sum
+	},
+}
+
+// aggregateSamples разбивает интервал [from, to] на окна длиной step, начиная с from,
+// и агрегирует значения в каждом непустом окне. Точка окна получает время его начала.
+// Если step равен нулю, весь интервал считается одним окном.
+// Значения должны быть упорядочены по времени.
+func aggregateSamples(samples []models.Sample, from time.Time, to time.Time, step time.Duration, aggregate func([]float64) float64) []models.Sample {
+	if step <= 0 {
+		step = to.Sub(from) + 1
+	}
+	points := []models.Sample{}
+	var values []float64
+	var bucket int64 = -1
+	flush := func() {
+		if len(values) > 0 {
+			points = append(points, models.Sample{
+				Timestamp: from.Add(time.Duration(bucket) * step),
+				Value:     aggregate(values),
+			})
+		}
+		values = values[:0]
+	}
+	for _, sample := range samples {
+		b := int64(sample.Timestamp.Sub(from) / step)
+		if b != bucket {
+			flush()
+			bucket = b
+		}
+		values = append(values, sample.Value)
+	}
+	flush()
+	return points
+}
+
+// parseQueryTime разбирает время в формате RFC 3339 или Unix-время в секундах.
+func parseQueryTime(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		sec, frac := math.Modf(seconds)
+		return time.Unix(int64(sec), int64(frac*float64(time.Second))), nil
+	}
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+// parseQueryStep разбирает шаг в формате длительности Go или количество секунд.
+func parseQueryStep(value string) (time.Duration, error) {
+	step, err := time.ParseDuration(value)
+	if err != nil {
+		seconds, parseErr := strconv.ParseFloat(value, 64)
+		if parseErr != nil {
+			return 0, err
+		}
+		step = time.Duration(seconds * float64(time.Second))
+	}
+	if step <= 0 {
+		return 0, fmt.Errorf("step must be positive")
+	}
+	return step, nil
+}
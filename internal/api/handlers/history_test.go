@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/eac0de/getmetrics/internal/models"
+	"github.com/eac0de/getmetrics/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryRangeHandler(t *testing.T) {
+	from := time.Unix(1000, 0)
+	samples := []models.Sample{
+		{Timestamp: from.Add(5 * time.Second), Value: 1},
+		{Timestamp: from.Add(15 * time.Second), Value: 4},
+		{Timestamp: from.Add(25 * time.Second), Value: 2},
+		{Timestamp: from.Add(35 * time.Second), Value: 6},
+	}
+	tests := []struct {
+		name       string
+		query      string
+		statusCode int
+		points     []models.Sample
+	}{
+		{
+			name:       "raw samples",
+			query:      "?id=HeapAlloc&type=gauge&from=1000&to=1060",
+			statusCode: http.StatusOK,
+			points:     samples,
+		},
+		{
+			name:       "avg with step",
+			query:      "?id=HeapAlloc&type=gauge&from=1000&to=1060&step=20s&agg=avg",
+			statusCode: http.StatusOK,
+			points: []models.Sample{
+				{Timestamp: from, Value: 2.5},
+				{Timestamp: from.Add(20 * time.Second), Value: 4},
+			},
+		},
+		{
+			name:       "last by default",
+			query:      "?id=HeapAlloc&type=gauge&from=1000&to=1060&step=20",
+			statusCode: http.StatusOK,
+			points: []models.Sample{
+				{Timestamp: from, Value: 4},
+				{Timestamp: from.Add(20 * time.Second), Value: 6},
+			},
+		},
+		{
+			name:       "max over whole range",
+			query:      "?id=HeapAlloc&type=gauge&from=1970-01-01T00:16:40Z&to=1060&agg=max",
+			statusCode: http.StatusOK,
+			points:     []models.Sample{{Timestamp: from, Value: 6}},
+		},
+		{
+			name:       "invalid type",
+			query:      "?id=HeapAlloc&type=histogram",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid aggregation",
+			query:      "?id=HeapAlloc&type=gauge&agg=median",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "sum over counter",
+			query:      "?id=PollCount&type=counter&step=20s&agg=sum",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid step",
+			query:      "?id=HeapAlloc&type=gauge&step=-1s",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "too many points",
+			query:      "?id=HeapAlloc&type=gauge&from=0&to=100000&step=1",
+			statusCode: http.StatusBadRequest,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	metricsStore := mocks.NewMockIMetricsStore(ctrl)
+	mh := NewMetricsHandlers(metricsStore, "", slog.Default())
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.statusCode == http.StatusOK {
+				metricsStore.EXPECT().
+					QueryRange(gomock.Any(), "HeapAlloc", models.Gauge, gomock.Nil(), gomock.Any(), gomock.Any()).
+					Return(samples, nil)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/query_range"+test.query, nil)
+			rec := httptest.NewRecorder()
+			mh.QueryRangeHandler()(rec, req)
+			assert.Equal(t, test.statusCode, rec.Code)
+			if test.statusCode != http.StatusOK {
+				return
+			}
+			var resp QueryRangeResponse
+			err := json.NewDecoder(rec.Body).Decode(&resp)
+			assert.NoError(t, err)
+			assert.Equal(t, "HeapAlloc", resp.ID)
+			assert.Len(t, resp.Points, len(test.points))
+			for i, point := range test.points {
+				assert.True(t, point.Timestamp.Equal(resp.Points[i].Timestamp))
+				assert.Equal(t, point.Value, resp.Points[i].Value)
+			}
+		})
+	}
+}
+
+func TestQueryRangeHandlerTooManyRawPoints(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	metricsStore := mocks.NewMockIMetricsStore(ctrl)
+	mh := NewMetricsHandlers(metricsStore, "", slog.Default())
+	samples := make([]models.Sample, maxQueryPoints+1)
+	for i := range samples {
+		samples[i] = models.Sample{Timestamp: time.Unix(int64(i), 0), Value: float64(i)}
+	}
+	metricsStore.EXPECT().
+		QueryRange(gomock.Any(), "HeapAlloc", models.Gauge, gomock.Nil(), gomock.Any(), gomock.Any()).
+		Return(samples, nil).
+		Times(2)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/query_range?id=HeapAlloc&type=gauge&from=0&to=20000", nil)
+	rec := httptest.NewRecorder()
+	mh.QueryRangeHandler()(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// С шагом те же значения агрегируются и возвращаются
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/query_range?id=HeapAlloc&type=gauge&from=0&to=20000&step=60", nil)
+	rec = httptest.NewRecorder()
+	mh.QueryRangeHandler()(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
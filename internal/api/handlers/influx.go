@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"bufio"
+	stderr "errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eac0de/getmetrics/internal/models"
+	"github.com/eac0de/getmetrics/pkg/errors"
+)
+
+// maxLineProtocolLineSize - максимальная длина строки line protocol.
+const maxLineProtocolLineSize = 1 << 20
+
+// precisionMultipliers задает множители для перевода временной метки в наносекунды
+// для значений параметра precision.
+var precisionMultipliers = map[string]int64{
+	"":   1,
+	"ns": 1,
+	"us": int64(time.Microsecond),
+	"ms": int64(time.Millisecond),
+	"s":  int64(time.Second),
+}
+
+// timedMetric - метрика с временной меткой значения в наносекундах.
+type timedMetric struct {
+	metric    models.Metric
+	timestamp int64
+}
+
+// InfluxWriteHandler возвращает HTTP-обработчик для записи метрик в формате InfluxDB line protocol.
+//
+// Каждая строка имеет вид measurement[,tag=value...] field=value[,field=value...] [timestamp].
+// Каждое числовое поле становится отдельной метрикой с именем measurement_field (для поля value -
+// просто measurement), а теги - ее метками. Целые поля (123i, 123u) сохраняются как значения
+// счетчиков, дробные - как значения gauge. Строковые и логические поля пропускаются.
+//
+// Временная метка, если она есть, переводится в наносекунды по параметру precision (ns, us, ms, s)
+// и определяет, какое из нескольких значений одной метрики в запросе сохраняется.
+// Строки без временной метки считаются записанными в момент получения запроса.
+//
+// Корректные строки сохраняются, даже если в запросе есть ошибочные. При ошибках возвращается
+// статус 400 со списком ошибок по номерам строк, иначе - статус 204 (No Content).
+func (h *MetricsHandlers) InfluxWriteHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		multiplier, ok := precisionMultipliers[r.URL.Query().Get("precision")]
+		if !ok {
+			http.Error(w, "precision must be one of ns, us, ms, s", http.StatusBadRequest)
+			return
+		}
+		now := time.Now().UnixNano()
+		var points []timedMetric
+		var errsList []error
+		scanner := bufio.NewScanner(r.Body)
+		scanner.Buffer(nil, maxLineProtocolLineSize)
+		for lineNumber := 1; scanner.Scan(); lineNumber++ {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			linePoints, err := h.parseInfluxLine(line, multiplier, now)
+			if err != nil {
+				errsList = append(errsList, fmt.Errorf("line %d: %w", lineNumber, err))
+				continue
+			}
+			points = append(points, linePoints...)
+		}
+		if err := scanner.Err(); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request payload: %s", err.Error()), http.StatusBadRequest)
+			return
+		}
+		metricsList := latestMetrics(points)
+		if len(metricsList) > 0 {
+			err := h.MetricsStore.SaveMetrics(r.Context(), metricsList)
+			if err != nil {
+				h.writeError(w, r, err)
+				return
+			}
+		}
+		if len(errsList) > 0 {
+			err := stderr.Join(errsList...)
+			h.writeError(w, r, errors.NewErrorWithHTTPStatus(err, err.Error(), http.StatusBadRequest))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// parseInfluxLine разбирает строку line protocol в метрики.
+func (h *MetricsHandlers) parseInfluxLine(line string, multiplier int64, now int64) ([]timedMetric, error) {
+	sections := splitUnescaped(line, ' ')
+	if len(sections) < 2 || len(sections) > 3 {
+		return nil, fmt.Errorf("expected measurement, fields and optional timestamp separated by spaces")
+	}
+	key := splitUnescaped(sections[0], ',')
+	measurement := unescapeInflux(key[0])
+	if measurement == "" {
+		return nil, fmt.Errorf("measurement is required")
+	}
+	var labels models.Labels
+	for _, tag := range key[1:] {
+		name, value, ok := cutUnescaped(tag, '=')
+		if !ok || name == "" || value == "" {
+			return nil, fmt.Errorf("invalid tag %q: expected key=value", tag)
+		}
+		if labels == nil {
+			labels = models.Labels{}
+		}
+		labels[sanitizeLabelName(unescapeInflux(name))] = unescapeInflux(value)
+	}
+	timestamp := now
+	if len(sections) == 3 {
+		ts, err := strconv.ParseInt(sections[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp %q", sections[2])
+		}
+		if ts > math.MaxInt64/multiplier || ts < math.MinInt64/multiplier {
+			return nil, fmt.Errorf("timestamp %q is out of range", sections[2])
+		}
+		timestamp = ts * multiplier
+	}
+	var points []timedMetric
+	for _, field := range splitUnescaped(sections[1], ',') {
+		name, value, ok := cutUnescaped(field, '=')
+		if !ok || name == "" || value == "" {
+			return nil, fmt.Errorf("invalid field %q: expected key=value", field)
+		}
+		metric, err := parseInfluxField(value)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", unescapeInflux(name), err)
+		}
+		if metric == nil {
+			continue
+		}
+		metric.ID = measurement
+		if name := unescapeInflux(name); name != "value" {
+			metric.ID += "_" + name
+		}
+		metric.Labels = labels
+		err = h.validateMetric(*metric)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, timedMetric{metric: *metric, timestamp: timestamp})
+	}
+	return points, nil
+}
+
+// parseInfluxField разбирает значение поля. Для строковых и логических значений возвращает nil.
+func parseInfluxField(value string) (*models.Metric, error) {
+	if strings.HasPrefix(value, `"`) {
+		if len(value) < 2 || !strings.HasSuffix(value, `"`) {
+			return nil, fmt.Errorf("unterminated string value")
+		}
+		return nil, nil
+	}
+	switch value {
+	case "t", "T", "true", "True", "TRUE", "f", "F", "false", "False", "FALSE":
+		return nil, nil
+	}
+	switch value[len(value)-1] {
+	case 'i':
+		delta, err := strconv.ParseInt(value[:len(value)-1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer value %q", value)
+		}
+		return &models.Metric{MType: models.Counter, Delta: &delta}, nil
+	case 'u':
+		u, err := strconv.ParseUint(value[:len(value)-1], 10, 64)
+		if err != nil || u > math.MaxInt64 {
+			return nil, fmt.Errorf("invalid unsigned integer value %q", value)
+		}
+		delta := int64(u)
+		return &models.Metric{MType: models.Counter, Delta: &delta}, nil
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil, fmt.Errorf("invalid float value %q", value)
+	}
+	return &models.Metric{MType: models.Gauge, Value: &v}, nil
+}
+
+// latestMetrics оставляет для каждой метрики значение с наибольшей временной меткой,
+// а при равных метках - последнее в списке.
+func latestMetrics(points []timedMetric) []models.Metric {
+	latest := make(map[string]int, len(points))
+	var metricsList []models.Metric
+	var timestamps []int64
+	for _, point := range points {
+		key := point.metric.MType + ":" + point.metric.SeriesKey()
+		i, ok := latest[key]
+		if !ok {
+			latest[key] = len(metricsList)
+			metricsList = append(metricsList, point.metric)
+			timestamps = append(timestamps, point.timestamp)
+			continue
+		}
+		if point.timestamp >= timestamps[i] {
+			metricsList[i] = point.metric
+			timestamps[i] = point.timestamp
+		}
+	}
+	return metricsList
+}
+
+// splitUnescaped разбивает строку по разделителю sep, не экранированному обратной косой
+// чертой и не находящемуся внутри строки в двойных кавычках.
+func splitUnescaped(s string, sep byte) []string {
+	var parts []string
+	start := 0
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			quoted = !quoted
+		case sep:
+			if !quoted {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// cutUnescaped разделяет строку по первому неэкранированному разделителю sep.
+func cutUnescaped(s string, sep byte) (string, string, bool) {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case sep:
+			return s[:i], s[i+1:], true
+		}
+	}
+	return s, "", false
+}
+
+// unescapeInflux убирает экранирование запятых, пробелов и знаков равенства.
+func unescapeInflux(s string) string {
+	if !strings.ContainsRune(s, '\\') {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && strings.IndexByte(`, ="\`, s[i+1]) >= 0 {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// sanitizeLabelName заменяет символы, недопустимые в имени метки, на подчеркивание.
+func sanitizeLabelName(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		if c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 0 && c >= '0' && c <= '9' {
+			continue
+		}
+		b[i] = '_'
+	}
+	return string(b)
+}
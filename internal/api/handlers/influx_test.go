@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/eac0de/getmetrics/internal/models"
+	"github.com/eac0de/getmetrics/internal/storage/memstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInfluxWriteHandler(t *testing.T) {
+	store := memstore.New()
+	mh := NewMetricsHandlers(store, "", slog.Default())
+
+	body := strings.Join([]string{
+		`# комментарий`,
+		`cpu,host=server01,cpu-id=0 usage_idle=92.5,usage_user=3i 1700000000000000000`,
+		`cpu,host=server01,cpu-id=0 usage_idle=90.1 1699999999000000000`,
+		`net,host=server01 bytes_recv=1024u,iface="eth0",up=true`,
+		``,
+		`temperature,room=my\ room value=21.5`,
+		`my\,measurement field\=x=1`,
+	}, "\n")
+	req := httptest.NewRequest(http.MethodPost, "/write", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	mh.InfluxWriteHandler()(rec, req)
+	require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+
+	ctx := context.Background()
+	cpuLabels := models.Labels{"host": "server01", "cpu_id": "0"}
+	metric, err := store.GetMetric(ctx, "cpu_usage_idle", models.Gauge, cpuLabels)
+	require.NoError(t, err)
+	// Значение с меньшей временной меткой не заменяет более новое
+	assert.Equal(t, 92.5, *metric.Value)
+	metric, err = store.GetMetric(ctx, "cpu_usage_user", models.Counter, cpuLabels)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), *metric.Delta)
+	metric, err = store.GetMetric(ctx, "net_bytes_recv", models.Counter, models.Labels{"host": "server01"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1024), *metric.Delta)
+	metric, err = store.GetMetric(ctx, "temperature", models.Gauge, models.Labels{"room": "my room"})
+	require.NoError(t, err)
+	assert.Equal(t, 21.5, *metric.Value)
+	metric, err = store.GetMetric(ctx, "my,measurement_field=x", models.Gauge, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, *metric.Value)
+
+	metrics, err := store.ListAllMetrics(ctx)
+	require.NoError(t, err)
+	assert.Len(t, metrics, 5)
+}
+
+func TestInfluxWriteHandlerErrors(t *testing.T) {
+	store := memstore.New()
+	mh := NewMetricsHandlers(store, "", slog.Default())
+
+	body := strings.Join([]string{
+		`ok value=1`,
+		`no_fields`,
+		`bad_int value=1.5i`,
+		`bad_ts value=1 yesterday`,
+		`bad_tag,host value=1`,
+		`nan value=NaN`,
+	}, "\n")
+	req := httptest.NewRequest(http.MethodPost, "/write", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	mh.InfluxWriteHandler()(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	for _, line := range []string{"line 2:", "line 3:", "line 4:", "line 5:", "line 6:"} {
+		assert.Contains(t, rec.Body.String(), line)
+	}
+	assert.NotContains(t, rec.Body.String(), "line 1:")
+	// Корректные строки сохраняются несмотря на ошибки в других
+	metric, err := store.GetMetric(context.Background(), "ok", models.Gauge, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, *metric.Value)
+}
+
+func TestInfluxWriteHandlerPrecision(t *testing.T) {
+	store := memstore.New()
+	mh := NewMetricsHandlers(store, "", slog.Default())
+
+	body := "load value=2 1700000001\nload value=1 1700000000"
+	req := httptest.NewRequest(http.MethodPost, "/write?precision=s", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	mh.InfluxWriteHandler()(rec, req)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	metric, err := store.GetMetric(context.Background(), "load", models.Gauge, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2.0, *metric.Value)
+
+	req = httptest.NewRequest(http.MethodPost, "/write?precision=h", strings.NewReader(body))
+	rec = httptest.NewRecorder()
+	mh.InfluxWriteHandler()(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
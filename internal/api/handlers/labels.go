@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/eac0de/getmetrics/internal/models"
+)
+
+// labelQueryParam - параметр запроса, задающий метку в виде name=value. Может повторяться.
+const labelQueryParam = "label"
+
+// parseLabelsQuery возвращает метки из параметров label запроса.
+// Если метки не заданы, возвращает nil.
+func parseLabelsQuery(query url.Values) (models.Labels, error) {
+	values := query[labelQueryParam]
+	if len(values) == 0 {
+		return nil, nil
+	}
+	labels := make(models.Labels, len(values))
+	for _, value := range values {
+		name, labelValue, ok := strings.Cut(value, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid label %q: expected name=value", value)
+		}
+		labels[name] = labelValue
+	}
+	if err := labels.Validate(); err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/eac0de/getmetrics/internal/models"
+	"github.com/eac0de/getmetrics/mocks"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLabelsQuery(t *testing.T) {
+	labels, err := parseLabelsQuery(url.Values{"label": {"host=host1", "instance=10.0.0.1:8080"}})
+	assert.NoError(t, err)
+	assert.Equal(t, models.Labels{"host": "host1", "instance": "10.0.0.1:8080"}, labels)
+
+	labels, err = parseLabelsQuery(url.Values{})
+	assert.NoError(t, err)
+	assert.Nil(t, labels)
+
+	_, err = parseLabelsQuery(url.Values{"label": {"host"}})
+	assert.Error(t, err)
+	_, err = parseLabelsQuery(url.Values{"label": {"host-name=x"}})
+	assert.Error(t, err)
+}
+
+func TestUpdateMetricsJSONHandlerLabels(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	metricsStore := mocks.NewMockIMetricsStore(ctrl)
+	mh := NewMetricsHandlers(metricsStore, "", slog.Default())
+
+	var saved []models.Metric
+	metricsStore.EXPECT().UpdateMetrics(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ any, metricsList []models.Metric) ([]models.Metric, error) {
+			saved = metricsList
+			return metricsList, nil
+		},
+	)
+
+	body := `[
+		{"id":"PollCount","type":"counter","delta":1,"labels":{"host":"host1"}},
+		{"id":"PollCount","type":"counter","delta":2,"labels":{"host":"host1"}},
+		{"id":"PollCount","type":"counter","delta":5,"labels":{"host":"host2"}}
+	]`
+	req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewBufferString(body))
+	rec := httptest.NewRecorder()
+	mh.UpdateMetricsJSONHandler()(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	deltas := map[string]int64{}
+	for _, metric := range saved {
+		deltas[metric.SeriesKey()] = *metric.Delta
+	}
+	assert.Equal(t, map[string]int64{
+		`PollCount{host="host1"}`: 3,
+		`PollCount{host="host2"}`: 5,
+	}, deltas)
+}
+
+func TestUpdateMetricsJSONHandlerInvalidLabels(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mh := NewMetricsHandlers(mocks.NewMockIMetricsStore(ctrl), "", slog.Default())
+	body := `[{"id":"HeapAlloc","type":"gauge","value":1,"labels":{"host-name":"host1"}}]`
+	req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewBufferString(body))
+	rec := httptest.NewRecorder()
+	mh.UpdateMetricsJSONHandler()(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestGetMetricHandlerLabels(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	metricsStore := mocks.NewMockIMetricsStore(ctrl)
+	mh := NewMetricsHandlers(metricsStore, "", slog.Default())
+	value := 1.5
+	metricsStore.EXPECT().
+		GetMetric(gomock.Any(), "HeapAlloc", models.Gauge, models.Labels{"host": "host1"}).
+		Return(&models.Metric{ID: "HeapAlloc", MType: models.Gauge, Value: &value}, nil)
+
+	r := chi.NewRouter()
+	r.Get("/value/{metricType}/{metricName}", mh.GetMetricHandler())
+	req := httptest.NewRequest(http.MethodGet, "/value/gauge/HeapAlloc?label=host=host1", nil)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "1.5", rec.Body.String())
+}
+
+func TestShowMetricsSummaryHandlerLabelsFilter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	metricsStore := mocks.NewMockIMetricsStore(ctrl)
+	metricsStore.EXPECT().ListAllMetrics(gomock.Any()).Return([]*models.Metric{
+		{ID: "HeapAlloc", MType: models.Gauge, Value: func(v float64) *float64 { return &v }(1), Labels: models.Labels{"host": "host1"}},
+		{ID: "HeapAlloc", MType: models.Gauge, Value: func(v float64) *float64 { return &v }(2), Labels: models.Labels{"host": "host2"}},
+	}, nil)
+	metricsStore.EXPECT().QueryRanges(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ any, series []models.Metric, _, _ any) ([][]models.Sample, error) {
+			return make([][]models.Sample, len(series)), nil
+		},
+	)
+	mh := NewMetricsHandlers(metricsStore, "", slog.Default())
+	rec := httptest.NewRecorder()
+	mh.ShowMetricsSummaryHandler()(rec, httptest.NewRequest(http.MethodGet, "/?label=host=host2", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	body := rec.Body.String()
+	assert.Contains(t, body, "host2")
+	assert.NotContains(t, body, "host1")
+}
@@ -12,18 +12,16 @@
 package handlers
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	stderr "errors"
 	"fmt"
-	"html/template"
-	"io"
-	"log"
+	"log/slog"
 	"net/http"
-	"os"
-	"path/filepath"
-	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/eac0de/getmetrics/internal/models"
 	"github.com/eac0de/getmetrics/pkg/errors"
@@ -35,23 +33,38 @@ import (
 type IMetricsStore interface {
 	SaveMetric(ctx context.Context, metric models.Metric) error
 	SaveMetrics(ctx context.Context, metricsList []models.Metric) error
-	GetMetric(ctx context.Context, metricName string, metricType string) (*models.Metric, error)
+	// IncrementCounter атомарно прибавляет приращение к счетчику и возвращает накопленное значение.
+	IncrementCounter(ctx context.Context, metric models.Metric) (*models.Metric, error)
+	// IncrementCounters атомарно прибавляет приращения к счетчикам и возвращает накопленные значения.
+	IncrementCounters(ctx context.Context, metricsList []models.Metric) ([]models.Metric, error)
+	// UpdateMetrics атомарно применяет пакет метрик: значения gauge записываются, приращения
+	// counter и histogram прибавляются к сохраненным значениям. Если границы корзин гистограммы
+	// изменились, сохраненная гистограмма заменяется новой. Возвращает сохраненные значения.
+	UpdateMetrics(ctx context.Context, metricsList []models.Metric) ([]models.Metric, error)
+	GetMetric(ctx context.Context, metricName string, metricType string, labels models.Labels) (*models.Metric, error)
 	ListAllMetrics(ctx context.Context) ([]*models.Metric, error)
+	QueryRange(ctx context.Context, metricName string, metricType string, labels models.Labels, from time.Time, to time.Time) ([]models.Sample, error)
+	// QueryRanges возвращает истории нескольких рядов одним запросом, в порядке series.
+	QueryRanges(ctx context.Context, series []models.Metric, from time.Time, to time.Time) ([][]models.Sample, error)
 }
 
 // MetricsHandlers представляет набор обработчиков для работы с метриками.
 type MetricsHandlers struct {
-	MetricsStore IMetricsStore // Хранилище метрик
-	SecretKey    string        // Секретный ключ для генерации подписи
+	MetricsStore IMetricsStore  // Хранилище метрик
+	SecretKey    string         // Секретный ключ для генерации подписи
+	Alerts       IAlertsSource  // Источник активных оповещений, может быть не задан
+	Stream       IMetricsStream // Источник обновлений метрик для потока событий, может быть не задан
+	Logger       *slog.Logger   // Логгер обработчиков
 }
 
 // NewMetricsHandlers создает новый экземпляр MetricsHandlers.
 //
-// Принимает на вход интерфейс хранилища метрик и секретный ключ.
-func NewMetricsHandlers(metricStore IMetricsStore, secretKey string) *MetricsHandlers {
+// Принимает на вход интерфейс хранилища метрик, секретный ключ и логгер.
+func NewMetricsHandlers(metricStore IMetricsStore, secretKey string, logger *slog.Logger) *MetricsHandlers {
 	return &MetricsHandlers{
 		MetricsStore: metricStore,
 		SecretKey:    secretKey,
+		Logger:       logger,
 	}
 }
 
@@ -67,9 +80,15 @@ func (h *MetricsHandlers) UpdateMetricHandler() func(http.ResponseWriter, *http.
 			http.Error(w, "metric name is required", http.StatusNotFound)
 			return
 		}
+		labels, err := parseLabelsQuery(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		metric := models.Metric{
-			ID:    metricName,
-			MType: metricType,
+			ID:     metricName,
+			MType:  metricType,
+			Labels: labels,
 		}
 		switch metric.MType {
 		case models.Counter:
@@ -83,25 +102,22 @@ func (h *MetricsHandlers) UpdateMetricHandler() func(http.ResponseWriter, *http.
 				metric.Value = &value
 			}
 		}
-		err := h.validateMetric(metric)
+		err = h.validateMetric(metric)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 		if metric.MType == models.Counter {
-			var oldDelta int64
-			oldDelta, err = h.getOldDelta(r.Context(), metric.ID)
-			if err != nil {
-				msg, statusCode := errors.GetMessageAndStatusCode(err)
-				http.Error(w, msg, statusCode)
-				return
+			var counter *models.Metric
+			counter, err = h.MetricsStore.IncrementCounter(r.Context(), metric)
+			if err == nil {
+				metric.Delta = counter.Delta
 			}
-			*metric.Delta += oldDelta
+		} else {
+			err = h.MetricsStore.SaveMetric(r.Context(), metric)
 		}
-		err = h.MetricsStore.SaveMetric(r.Context(), metric)
 		if err != nil {
-			msg, statusCode := errors.GetMessageAndStatusCode(err)
-			http.Error(w, msg, statusCode)
+			h.writeError(w, r, err)
 			return
 		}
 		var answer string
@@ -134,20 +150,24 @@ func (h *MetricsHandlers) UpdateMetricJSONHandler() func(http.ResponseWriter, *h
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		if metric.MType == models.Counter {
-			var oldDelta int64
-			oldDelta, err = h.getOldDelta(r.Context(), metric.ID)
-			if err != nil {
-				msg, statusCode := errors.GetMessageAndStatusCode(err)
-				http.Error(w, msg, statusCode)
-				return
+		switch metric.MType {
+		case models.Counter:
+			var counter *models.Metric
+			counter, err = h.MetricsStore.IncrementCounter(r.Context(), metric)
+			if err == nil {
+				metric.Delta = counter.Delta
 			}
-			*metric.Delta += oldDelta
+		case models.Histogram:
+			var metricsList []models.Metric
+			metricsList, err = h.MetricsStore.UpdateMetrics(r.Context(), []models.Metric{metric})
+			if err == nil {
+				metric.Histogram = metricsList[0].Histogram
+			}
+		default:
+			err = h.MetricsStore.SaveMetric(r.Context(), metric)
 		}
-		err = h.MetricsStore.SaveMetric(r.Context(), metric)
 		if err != nil {
-			msg, statusCode := errors.GetMessageAndStatusCode(err)
-			http.Error(w, msg, statusCode)
+			h.writeError(w, r, err)
 			return
 		}
 		data, err := json.Marshal(metric)
@@ -172,28 +192,9 @@ func (h *MetricsHandlers) UpdateMetricsJSONHandler() func(http.ResponseWriter, *
 			http.Error(w, "Invalid request payload", http.StatusBadRequest)
 			return
 		}
-		var errsList []error
-		for _, metric := range metricsList {
-			err := h.validateMetric(metric)
-			if err != nil {
-				errsList = append(errsList, err)
-			}
-		}
-		if len(errsList) > 0 {
-			err := stderr.Join(errsList...)
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-		metricsList, err := h.mergeMetricsList(r.Context(), metricsList)
+		metricsList, err := h.UpdateMetrics(r.Context(), metricsList)
 		if err != nil {
-			msg, statusCode := errors.GetMessageAndStatusCode(err)
-			http.Error(w, msg, statusCode)
-			return
-		}
-		err = h.MetricsStore.SaveMetrics(r.Context(), metricsList)
-		if err != nil {
-			msg, statusCode := errors.GetMessageAndStatusCode(err)
-			http.Error(w, msg, statusCode)
+			h.writeError(w, r, err)
 			return
 		}
 		data, err := json.Marshal(metricsList)
@@ -212,14 +213,19 @@ func (h *MetricsHandlers) UpdateMetricsJSONHandler() func(http.ResponseWriter, *
 // GetMetricHandler возвращает HTTP-обработчик для получения метрики по имени и типу.
 //
 // Обрабатывает запрос для получения метрики и возвращает её значение в формате текста.
+// Для гистограммы возвращает краткое описание, а с параметром quantile - оценку квантиля.
 func (h *MetricsHandlers) GetMetricHandler() func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		metricName := chi.URLParam(r, "metricName")
 		metricType := chi.URLParam(r, "metricType")
-		metric, err := h.MetricsStore.GetMetric(r.Context(), metricName, metricType)
+		labels, err := parseLabelsQuery(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		metric, err := h.MetricsStore.GetMetric(r.Context(), metricName, metricType, labels)
 		if err != nil {
-			msg, statusCode := errors.GetMessageAndStatusCode(err)
-			http.Error(w, msg, statusCode)
+			h.writeError(w, r, err)
 			return
 		}
 		var metricStr string
@@ -228,6 +234,16 @@ func (h *MetricsHandlers) GetMetricHandler() func(http.ResponseWriter, *http.Req
 			metricStr = fmt.Sprintf("%v", *metric.Delta)
 		case models.Gauge:
 			metricStr = fmt.Sprintf("%v", *metric.Value)
+		case models.Histogram:
+			metricStr = metric.Histogram.String()
+			if r.URL.Query().Has("quantile") {
+				q, err := strconv.ParseFloat(r.URL.Query().Get("quantile"), 64)
+				if err != nil || q < 0 || q > 1 {
+					http.Error(w, "quantile must be a number between 0 and 1", http.StatusBadRequest)
+					return
+				}
+				metricStr = fmt.Sprintf("%v", metric.Histogram.Quantile(q))
+			}
 		}
 		data := []byte(metricStr)
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
@@ -247,10 +263,9 @@ func (h *MetricsHandlers) GetMetricJSONHandler() func(http.ResponseWriter, *http
 			http.Error(w, "Invalid request payload", http.StatusBadRequest)
 			return
 		}
-		metric, err := h.MetricsStore.GetMetric(r.Context(), m.ID, m.MType)
+		metric, err := h.MetricsStore.GetMetric(r.Context(), m.ID, m.MType, m.Labels)
 		if err != nil {
-			msg, statusCode := errors.GetMessageAndStatusCode(err)
-			http.Error(w, msg, statusCode)
+			h.writeError(w, r, err)
 			return
 		}
 		data, err := json.Marshal(metric)
@@ -267,53 +282,73 @@ func (h *MetricsHandlers) GetMetricJSONHandler() func(http.ResponseWriter, *http
 
 // ShowMetricsSummaryHandler возвращает HTTP-обработчик для отображения HTML-страницы со списком всех метрик.
 //
-// Загружает шаблон и отображает страницу со всеми метриками из хранилища.
+// Страница показывает значения метрик, скорость роста счетчиков и графики значений за последние
+// 15 минут. Параметры запроса: type - тип метрики, name - часть имени без учета регистра,
+// label - метки ряда в виде name=value (может повторяться), sort - колонка сортировки (name, type,
+// value, rate), order=desc - обратный порядок, group=true - группировка по префиксу имени,
+// refresh - интервал автообновления в секундах (0 - без автообновления).
 func (h *MetricsHandlers) ShowMetricsSummaryHandler() func(http.ResponseWriter, *http.Request) {
-	filePath := filepath.Join("templates", "metrics_summary.html")
-	file, err := os.OpenFile(filePath, os.O_RDONLY, 0666)
-	if err != nil {
-		log.Fatalf("open template file error: %s", err.Error())
-	}
-	data, err := io.ReadAll(file)
-	if err != nil {
-		log.Fatalf("read template error: %s", err.Error())
-	}
-	tmpl, err := template.New("metrics").Parse(string(data))
-	if err != nil {
-		log.Fatalf("parse template error: %s", err.Error())
-	}
 	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		filter, err := parseSummaryFilter(query)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		metrics, err := h.MetricsStore.ListAllMetrics(r.Context())
 		if err != nil {
-			msg, statusCode := errors.GetMessageAndStatusCode(err)
-			http.Error(w, msg, statusCode)
+			h.writeError(w, r, err)
 			return
 		}
-		sort.Slice(metrics, func(i, j int) bool {
-			return metrics[i].ID < metrics[j].ID
-		})
-		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		w.WriteHeader(http.StatusOK)
-		err = tmpl.Execute(w, metrics)
+		page := h.buildSummaryPage(r.Context(), metrics, filter, query)
+		var buf bytes.Buffer
+		err = summaryTemplate.Execute(&buf, page)
 		if err != nil {
+			h.Logger.ErrorContext(r.Context(), "Rendering template error", "error", err)
 			http.Error(w, "Rendering template error", http.StatusInternalServerError)
 			return
 		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write(buf.Bytes())
 	}
 }
 
-func (h *MetricsHandlers) getOldDelta(ctx context.Context, ID string) (int64, error) {
-	var oldDelta int64
-	metric, err := h.MetricsStore.GetMetric(ctx, ID, models.Counter)
-	if err != nil {
-		_, statusCode := errors.GetMessageAndStatusCode(err)
-		if statusCode != http.StatusNotFound {
-			return 0, err
+// writeError отвечает на запрос сообщением и статусом ошибки. Ошибки сервера, например ошибки
+// хранилища, записываются в лог вместе с идентификатором запроса.
+func (h *MetricsHandlers) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	_, statusCode := errors.GetMessageAndStatusCode(err)
+	if statusCode >= http.StatusInternalServerError {
+		h.Logger.ErrorContext(r.Context(), "Request handling error", "path", r.URL.Path, "error", err)
+	}
+	errors.WriteHTTPError(w, r, err)
+}
+
+// UpdateMetrics проверяет и сохраняет пакет метрик.
+//
+// Значения счетчиков с одинаковым именем суммируются и атомарно прибавляются к сохраненным значениям.
+// Возвращает сохраненные метрики. Ошибки валидации возвращаются с кодом 400 (Bad Request).
+// Используется как HTTP-, так и gRPC-обработчиками.
+func (h *MetricsHandlers) UpdateMetrics(ctx context.Context, metricsList []models.Metric) ([]models.Metric, error) {
+	var errsList []error
+	for _, metric := range metricsList {
+		err := h.validateMetric(metric)
+		if err != nil {
+			errsList = append(errsList, err)
 		}
-	} else {
-		oldDelta = *metric.Delta
 	}
-	return oldDelta, nil
+	if len(errsList) > 0 {
+		err := stderr.Join(errsList...)
+		return nil, errors.NewErrorWithHTTPStatus(err, err.Error(), http.StatusBadRequest)
+	}
+	metricsList, err := mergeMetricsList(metricsList)
+	if err != nil {
+		return nil, err
+	}
+	if len(metricsList) == 0 {
+		return metricsList, nil
+	}
+	return h.MetricsStore.UpdateMetrics(ctx, metricsList)
 }
 
 func (h *MetricsHandlers) addSign(w http.ResponseWriter, data []byte) {
@@ -323,10 +358,29 @@ func (h *MetricsHandlers) addSign(w http.ResponseWriter, data []byte) {
 	}
 }
 
-func (h *MetricsHandlers) validateMetric(metric models.Metric) error {
-	if metric.ID == "" {
+// ValidateMetricID проверяет имя метрики. Имя не может быть пустым и не может содержать
+// фигурные скобки, так как они отделяют метки в ключе ряда.
+//
+// Используется всеми способами приема метрик, в том числе приемниками StatsD и Graphite.
+func ValidateMetricID(id string) error {
+	if id == "" {
 		return fmt.Errorf("metric name is required")
 	}
+	if strings.ContainsAny(id, "{}") {
+		return fmt.Errorf("metric %s must not contain braces in name", id)
+	}
+	return nil
+}
+
+func (h *MetricsHandlers) validateMetric(metric models.Metric) error {
+	if err := ValidateMetricID(metric.ID); err != nil {
+		return err
+	}
+	if len(metric.Labels) > 0 {
+		if err := metric.Labels.Validate(); err != nil {
+			return fmt.Errorf("metric %s: %w", metric.ID, err)
+		}
+	}
 	switch metric.MType {
 	case models.Gauge:
 		if metric.Value == nil {
@@ -336,45 +390,48 @@ func (h *MetricsHandlers) validateMetric(metric models.Metric) error {
 		if metric.Delta == nil {
 			return fmt.Errorf("metric %s with type %s must have filled delta", metric.ID, metric.MType)
 		}
+	case models.Histogram:
+		if metric.Histogram == nil {
+			return fmt.Errorf("metric %s with type %s must have filled histogram", metric.ID, metric.MType)
+		}
+		if err := metric.Histogram.Validate(); err != nil {
+			return fmt.Errorf("metric %s: %w", metric.ID, err)
+		}
 	default:
 		return fmt.Errorf("invalid metric type for %s: %s", metric.ID, metric.MType)
 	}
 	return nil
 }
 
-func (h *MetricsHandlers) mergeMetricsList(ctx context.Context, metricsList []models.Metric) ([]models.Metric, error) {
-	metricsMap := models.MetricsData{
-		Gauge:   map[string]float64{},
-		Counter: map[string]int64{},
-	}
-
-	// Обработка метрик
+// mergeMetricsList объединяет метрики пакета с одинаковой идентичностью: для gauge остается
+// последнее значение, приращения counter и histogram складываются.
+func mergeMetricsList(metricsList []models.Metric) ([]models.Metric, error) {
+	merged := make([]models.Metric, 0, len(metricsList))
+	index := make(map[string]int, len(metricsList))
 	for _, metric := range metricsList {
+		key := metric.MType + " " + metric.SeriesKey()
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(merged)
+			merged = append(merged, metric)
+			continue
+		}
 		switch metric.MType {
-		case models.Gauge:
-			metricsMap.Gauge[metric.ID] = *metric.Value
 		case models.Counter:
-			metricsMap.Counter[metric.ID] += *metric.Delta
-		}
-	}
-
-	// Формируем результирующий список
-	mergeMetricsList := make([]models.Metric, 0, len(metricsMap.Gauge)+len(metricsMap.Counter))
-
-	// Добавляем все gauge метрики
-	for ID, value := range metricsMap.Gauge {
-		mergeMetricsList = append(mergeMetricsList, models.Metric{ID: ID, MType: models.Gauge, Value: &value})
-	}
-
-	// Добавляем все counter метрики
-	for ID, value := range metricsMap.Counter {
-		oldDelta, err := h.getOldDelta(ctx, ID)
-		if err != nil {
-			return nil, err
+			delta := *merged[i].Delta + *metric.Delta
+			metric.Delta = &delta
+		case models.Histogram:
+			histogram, err := merged[i].Histogram.Merge(metric.Histogram)
+			if err != nil {
+				return nil, errors.NewErrorWithHTTPStatus(
+					err,
+					fmt.Sprintf("metric %s: %s", metric.ID, err.Error()),
+					http.StatusBadRequest,
+				)
+			}
+			metric.Histogram = histogram
 		}
-		value += oldDelta
-		mergeMetricsList = append(mergeMetricsList, models.Metric{ID: ID, MType: models.Counter, Delta: &value})
+		merged[i] = metric
 	}
-
-	return mergeMetricsList, nil
+	return merged, nil
 }
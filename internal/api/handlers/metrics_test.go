@@ -7,13 +7,19 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
 
 	"github.com/eac0de/getmetrics/internal/models"
+	"github.com/eac0de/getmetrics/internal/storage/memstore"
 	"github.com/eac0de/getmetrics/mocks"
 	"github.com/eac0de/getmetrics/pkg/errors"
+	"github.com/eac0de/getmetrics/pkg/logging"
+	"github.com/eac0de/getmetrics/pkg/middlewares"
+	"github.com/eac0de/getmetrics/pkg/requestid"
 	"github.com/go-chi/chi/v5"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
@@ -115,16 +121,15 @@ func TestUpdateMetricHandler(t *testing.T) {
 		gomock.Any(),
 		gomock.Any(),
 	).Return(nil).AnyTimes()
-	delta := int64(5)
-	metricsStore.EXPECT().GetMetric(
-		gomock.Any(),
+	metricsStore.EXPECT().IncrementCounter(
 		gomock.Any(),
 		gomock.Any(),
-	).Return(&models.Metric{
-		Delta: &delta},
-		nil,
-	).AnyTimes()
-	mh := NewMetricsHandlers(metricsStore, "")
+	).DoAndReturn(func(_ context.Context, metric models.Metric) (*models.Metric, error) {
+		delta := *metric.Delta + 5
+		metric.Delta = &delta
+		return &metric, nil
+	}).AnyTimes()
+	mh := NewMetricsHandlers(metricsStore, "", slog.Default())
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			url := "/update/{metricType}/{metricName}/{metricValue}"
@@ -191,6 +196,18 @@ func TestUpdateMetricJSONHandler(t *testing.T) {
 				text:       "metric name is required\n",
 			},
 		},
+		{
+			name: "status 400 with braces in ID",
+			reqBody: models.Metric{
+				ID:    "test_name}",
+				MType: models.Gauge,
+				Value: func(v float64) *float64 { return &v }(1),
+			},
+			want: want{
+				statusCode: http.StatusBadRequest,
+				text:       "metric test_name} must not contain braces in name\n",
+			},
+		},
 		{
 			name: "counter status 400 with invalid_type",
 			reqBody: models.Metric{
@@ -222,16 +239,15 @@ func TestUpdateMetricJSONHandler(t *testing.T) {
 		gomock.Any(),
 		gomock.Any(),
 	).Return(nil).AnyTimes()
-	delta := int64(5)
-	metricsStore.EXPECT().GetMetric(
-		gomock.Any(),
+	metricsStore.EXPECT().IncrementCounter(
 		gomock.Any(),
 		gomock.Any(),
-	).Return(
-		&models.Metric{Delta: &delta},
-		nil,
-	).AnyTimes()
-	mh := NewMetricsHandlers(metricsStore, "")
+	).DoAndReturn(func(_ context.Context, metric models.Metric) (*models.Metric, error) {
+		delta := *metric.Delta + 5
+		metric.Delta = &delta
+		return &metric, nil
+	}).AnyTimes()
+	mh := NewMetricsHandlers(metricsStore, "", slog.Default())
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			url := "/update/"
@@ -383,20 +399,20 @@ func TestUpdateMetricsJSONHandler(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	metricsStore := mocks.NewMockIMetricsStore(ctrl)
-	metricsStore.EXPECT().SaveMetrics(
-		gomock.Any(),
-		gomock.Any(),
-	).Return(nil).AnyTimes()
-	delta := int64(5)
-	metricsStore.EXPECT().GetMetric(
+	metricsStore.EXPECT().UpdateMetrics(
 		gomock.Any(),
 		gomock.Any(),
-		gomock.Any(),
-	).Return(
-		&models.Metric{Delta: &delta},
-		nil,
-	).AnyTimes()
-	mh := NewMetricsHandlers(metricsStore, "")
+	).DoAndReturn(func(_ context.Context, metricsList []models.Metric) ([]models.Metric, error) {
+		for i := range metricsList {
+			if metricsList[i].MType != models.Counter {
+				continue
+			}
+			delta := *metricsList[i].Delta + 5
+			metricsList[i].Delta = &delta
+		}
+		return metricsList, nil
+	}).AnyTimes()
+	mh := NewMetricsHandlers(metricsStore, "", slog.Default())
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			url := "/updates/"
@@ -419,8 +435,8 @@ func TestUpdateMetricsJSONHandler(t *testing.T) {
 				for _, wantMetric := range test.want.metricsList {
 					for _, metric := range metricsList {
 						if metric.ID == wantMetric.ID && metric.MType == wantMetric.MType {
-							assert.Equal(t, metric.Value, metric.Value)
-							assert.Equal(t, metric.Delta, metric.Delta)
+							assert.Equal(t, wantMetric.Value, metric.Value)
+							assert.Equal(t, wantMetric.Delta, metric.Delta)
 							break
 						}
 					}
@@ -515,9 +531,9 @@ func TestGetMetricHandler(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	metricsStore := mocks.NewMockIMetricsStore(ctrl)
-	mh := NewMetricsHandlers(metricsStore, "")
+	mh := NewMetricsHandlers(metricsStore, "", slog.Default())
 	for _, test := range tests {
-		metricsStore.EXPECT().GetMetric(gomock.Any(), gomock.Any(), gomock.Any()).Return(test.metricStore, test.errStore)
+		metricsStore.EXPECT().GetMetric(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(test.metricStore, test.errStore)
 		t.Run(test.name, func(t *testing.T) {
 			url := "/value/{metricType}/{metricName}"
 			r := httptest.NewRequest(http.MethodPost, url, nil)
@@ -601,13 +617,14 @@ func TestGetMetricJSONHandler(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	metricsStore := mocks.NewMockIMetricsStore(ctrl)
-	mh := NewMetricsHandlers(metricsStore, "")
+	mh := NewMetricsHandlers(metricsStore, "", slog.Default())
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			metricsStore.EXPECT().GetMetric(
 				gomock.Any(),
 				gomock.Any(),
 				gomock.Any(),
+				gomock.Any(),
 			).Return(
 				test.saveMetricReturn.metric,
 				test.saveMetricReturn.err,
@@ -654,10 +671,9 @@ func ExampleMetricsHandlers_UpdateMetricHandler() {
 	ctrl := gomock.NewController(nil)
 	defer ctrl.Finish()
 	metricsStore := mocks.NewMockIMetricsStore(ctrl)
-	metricsStore.EXPECT().SaveMetric(gomock.Any(), gomock.Any()).Return(nil)
-	delta := int64(5)
-	metricsStore.EXPECT().GetMetric(gomock.Any(), gomock.Any(), gomock.Any()).Return(&models.Metric{Delta: &delta}, nil)
-	mh := NewMetricsHandlers(metricsStore, "")
+	delta := int64(6)
+	metricsStore.EXPECT().IncrementCounter(gomock.Any(), gomock.Any()).Return(&models.Metric{Delta: &delta}, nil)
+	mh := NewMetricsHandlers(metricsStore, "", slog.Default())
 
 	mh.UpdateMetricHandler()(rr, req)
 
@@ -691,8 +707,8 @@ func ExampleMetricsHandlers_GetMetricHandler() {
 		MType: metricType,
 		Delta: &delta,
 	}
-	metricsStore.EXPECT().GetMetric(gomock.Any(), gomock.Any(), gomock.Any()).Return(&metric, nil)
-	mh := NewMetricsHandlers(metricsStore, "")
+	metricsStore.EXPECT().GetMetric(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(&metric, nil)
+	mh := NewMetricsHandlers(metricsStore, "", slog.Default())
 
 	mh.GetMetricHandler()(rr, req)
 
@@ -707,3 +723,59 @@ func ExampleMetricsHandlers_GetMetricHandler() {
 	// 200
 	// 5
 }
+
+func TestUpdateMetricsConcurrentCounters(t *testing.T) {
+	metricsStore := memstore.New()
+	mh := NewMetricsHandlers(metricsStore, "", slog.Default())
+	const workers, requests = 16, 50
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < requests; j++ {
+				body := `[{"id":"PollCount","type":"counter","delta":1},{"id":"PollCount","type":"counter","delta":2}]`
+				rec := httptest.NewRecorder()
+				mh.UpdateMetricsJSONHandler()(rec, httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewBufferString(body)))
+				assert.Equal(t, http.StatusOK, rec.Code)
+
+				rec = httptest.NewRecorder()
+				mh.UpdateMetricJSONHandler()(rec, httptest.NewRequest(http.MethodPost, "/update/", bytes.NewBufferString(`{"id":"PollCount","type":"counter","delta":1}`)))
+				assert.Equal(t, http.StatusOK, rec.Code)
+			}
+		}()
+	}
+	wg.Wait()
+
+	metric, err := metricsStore.GetMetric(context.Background(), "PollCount", models.Counter, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4*workers*requests), *metric.Delta)
+}
+
+func TestErrorResponseRequestID(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	metricsStore := mocks.NewMockIMetricsStore(ctrl)
+	metricsStore.EXPECT().
+		GetMetric(gomock.Any(), "Alloc", models.Gauge, gomock.Any()).
+		Return(nil, fmt.Errorf("connection refused"))
+	var logBuf bytes.Buffer
+	logger := slog.New(logging.NewContextHandler(slog.NewJSONHandler(&logBuf, nil)))
+	mh := NewMetricsHandlers(metricsStore, "", logger)
+	r := chi.NewRouter()
+	r.Use(middlewares.RequestIDMiddleware)
+	r.Get("/value/{metricType}/{metricName}", mh.GetMetricHandler())
+
+	req := httptest.NewRequest(http.MethodGet, "/value/gauge/Alloc", nil)
+	req.Header.Set(requestid.Header, "req-1")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, "req-1", rec.Header().Get(requestid.Header))
+	assert.Equal(t, "connection refused (request_id: req-1)\n", rec.Body.String())
+	var record map[string]any
+	assert.NoError(t, json.Unmarshal(logBuf.Bytes(), &record))
+	assert.Equal(t, "req-1", record["request_id"])
+	assert.Equal(t, "connection refused", record["error"])
+}
@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	stderr "errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/eac0de/getmetrics/internal/models"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	contentTypeProtobuf = "application/x-protobuf"
+	contentTypeJSON     = "application/json"
+	// maxOTLPErrors - максимальное количество ошибок в сообщении о частичном приеме.
+	maxOTLPErrors = 10
+)
+
+// otlpBatch - метрики запроса OTLP, разделенные по способу записи.
+type otlpBatch struct {
+	// cumulative - значения, заменяющие сохраненные.
+	cumulative []timedMetric
+	// delta - приращения счетчиков и гистограмм, прибавляемые к сохраненным значениям.
+	delta []models.Metric
+	// rejected - количество точек данных, которые не удалось преобразовать.
+	rejected int64
+	errs     []error
+}
+
+// OTLPMetricsHandler возвращает HTTP-обработчик для приема метрик по протоколу OTLP/HTTP.
+//
+// Тело запроса - ExportMetricsServiceRequest в формате protobuf (application/x-protobuf)
+// или JSON (application/json), ответ возвращается в том же формате.
+//
+// Точки данных Gauge сохраняются как gauge. Монотонные Sum сохраняются как счетчики, дробные
+// значения округляются до целого; немонотонные Sum с накопительной временностью - как gauge.
+// Histogram с явными границами корзин сохраняются как гистограммы. Значения с накопительной
+// временностью заменяют сохраненные, а с дельта-временностью прибавляются к ним.
+// ExponentialHistogram, Summary и немонотонные дельта-Sum не поддерживаются.
+//
+// Метками метрики становятся атрибуты ресурса и точки данных, атрибуты точки имеют приоритет.
+// Сохраняются только атрибуты со строковыми, логическими и числовыми значениями, недопустимые
+// символы в именах заменяются на подчеркивание.
+//
+// Отклоненные точки данных не мешают сохранению остальных, их количество и причины
+// возвращаются в поле partial_success ответа.
+func (h *MetricsHandlers) OTLPMetricsHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		var unmarshal func([]byte, proto.Message) error
+		var marshal func(proto.Message) ([]byte, error)
+		switch contentType {
+		case contentTypeProtobuf:
+			unmarshal, marshal = proto.Unmarshal, proto.Marshal
+		case contentTypeJSON:
+			unmarshal, marshal = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal, protojson.Marshal
+		default:
+			http.Error(w, "Content-Type must be application/x-protobuf or application/json", http.StatusUnsupportedMediaType)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Unable to read body", http.StatusBadRequest)
+			return
+		}
+		var req colmetricspb.ExportMetricsServiceRequest
+		err = unmarshal(body, &req)
+		if err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		batch := h.convertOTLP(&req)
+		if cumulative := latestMetrics(batch.cumulative); len(cumulative) > 0 {
+			err = h.MetricsStore.SaveMetrics(r.Context(), cumulative)
+			if err != nil {
+				h.writeError(w, r, err)
+				return
+			}
+		}
+		if len(batch.delta) > 0 {
+			_, err = h.UpdateMetrics(r.Context(), batch.delta)
+			if err != nil {
+				h.writeError(w, r, err)
+				return
+			}
+		}
+
+		resp := &colmetricspb.ExportMetricsServiceResponse{}
+		if len(batch.errs) > 0 {
+			h.Logger.WarnContext(r.Context(), "OTLP data points rejected", "count", batch.rejected)
+			resp.PartialSuccess = &colmetricspb.ExportMetricsPartialSuccess{
+				RejectedDataPoints: batch.rejected,
+				ErrorMessage:       stderr.Join(batch.errs...).Error(),
+			}
+		}
+		data, err := marshal(resp)
+		if err != nil {
+			http.Error(w, "Invalid server data", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(http.StatusOK)
+		w.Write(data)
+	}
+}
+
+// convertOTLP преобразует точки данных запроса в метрики.
+func (h *MetricsHandlers) convertOTLP(req *colmetricspb.ExportMetricsServiceRequest) *otlpBatch {
+	batch := &otlpBatch{}
+	for _, resourceMetrics := range req.GetResourceMetrics() {
+		resourceLabels := attributesToLabels(nil, resourceMetrics.GetResource().GetAttributes())
+		for _, scopeMetrics := range resourceMetrics.GetScopeMetrics() {
+			for _, metric := range scopeMetrics.GetMetrics() {
+				h.convertOTLPMetric(batch, metric, resourceLabels)
+			}
+		}
+	}
+	return batch
+}
+
+func (h *MetricsHandlers) convertOTLPMetric(batch *otlpBatch, metric *metricspb.Metric, resourceLabels models.Labels) {
+	name := metric.GetName()
+	switch data := metric.GetData().(type) {
+	case *metricspb.Metric_Gauge:
+		for _, point := range data.Gauge.GetDataPoints() {
+			value := numberValue(point)
+			h.addOTLPPoint(batch, models.Metric{ID: name, MType: models.Gauge, Value: &value}, point.GetFlags(), point.GetAttributes(), resourceLabels, point.GetTimeUnixNano(), false)
+		}
+	case *metricspb.Metric_Sum:
+		temporality := data.Sum.GetAggregationTemporality()
+		for _, point := range data.Sum.GetDataPoints() {
+			var m models.Metric
+			switch {
+			case data.Sum.GetIsMonotonic():
+				delta := int64(math.Round(numberValue(point)))
+				m = models.Metric{ID: name, MType: models.Counter, Delta: &delta}
+			case temporality == metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE:
+				value := numberValue(point)
+				m = models.Metric{ID: name, MType: models.Gauge, Value: &value}
+			default:
+				batch.reject(fmt.Errorf("metric %s: non-monotonic sum with %s is not supported", name, temporality), 1)
+				continue
+			}
+			h.addOTLPPoint(batch, m, point.GetFlags(), point.GetAttributes(), resourceLabels, point.GetTimeUnixNano(), isDelta(temporality))
+		}
+	case *metricspb.Metric_Histogram:
+		temporality := data.Histogram.GetAggregationTemporality()
+		for _, point := range data.Histogram.GetDataPoints() {
+			histogram := &models.HistogramValue{
+				Bounds: point.GetExplicitBounds(),
+				Counts: point.GetBucketCounts(),
+				Sum:    point.GetSum(),
+				Count:  point.GetCount(),
+			}
+			if len(histogram.Bounds) == 0 && len(histogram.Counts) == 0 {
+				histogram.Counts = []uint64{histogram.Count}
+			}
+			m := models.Metric{ID: name, MType: models.Histogram, Histogram: histogram}
+			h.addOTLPPoint(batch, m, point.GetFlags(), point.GetAttributes(), resourceLabels, point.GetTimeUnixNano(), isDelta(temporality))
+		}
+	case *metricspb.Metric_ExponentialHistogram:
+		batch.reject(fmt.Errorf("metric %s: exponential histograms are not supported", name), len(data.ExponentialHistogram.GetDataPoints()))
+	case *metricspb.Metric_Summary:
+		batch.reject(fmt.Errorf("metric %s: summaries are not supported", name), len(data.Summary.GetDataPoints()))
+	default:
+		batch.reject(fmt.Errorf("metric %s has no data", name), 0)
+	}
+}
+
+// addOTLPPoint добавляет метрику точки данных в пакет, если она проходит проверку.
+func (h *MetricsHandlers) addOTLPPoint(
+	batch *otlpBatch,
+	metric models.Metric,
+	flags uint32,
+	attributes []*commonpb.KeyValue,
+	resourceLabels models.Labels,
+	timestamp uint64,
+	delta bool,
+) {
+	if flags&uint32(metricspb.DataPointFlags_DATA_POINT_FLAGS_NO_RECORDED_VALUE_MASK) != 0 {
+		return
+	}
+	metric.Labels = attributesToLabels(resourceLabels, attributes)
+	err := h.validateMetric(metric)
+	if err != nil {
+		batch.reject(err, 1)
+		return
+	}
+	if delta {
+		batch.delta = append(batch.delta, metric)
+		return
+	}
+	batch.cumulative = append(batch.cumulative, timedMetric{metric: metric, timestamp: int64(min(timestamp, math.MaxInt64))})
+}
+
+// reject учитывает count отклоненных точек данных.
+func (b *otlpBatch) reject(err error, count int) {
+	b.rejected += int64(count)
+	if len(b.errs) < maxOTLPErrors {
+		b.errs = append(b.errs, err)
+	}
+}
+
+func isDelta(temporality metricspb.AggregationTemporality) bool {
+	return temporality == metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA
+}
+
+func numberValue(point *metricspb.NumberDataPoint) float64 {
+	if v, ok := point.GetValue().(*metricspb.NumberDataPoint_AsInt); ok {
+		return float64(v.AsInt)
+	}
+	return point.GetAsDouble()
+}
+
+// attributesToLabels дополняет метки base атрибутами. Если атрибутов нет, возвращает base.
+func attributesToLabels(base models.Labels, attributes []*commonpb.KeyValue) models.Labels {
+	if len(attributes) == 0 {
+		return base
+	}
+	labels := make(models.Labels, len(base)+len(attributes))
+	for name, value := range base {
+		labels[name] = value
+	}
+	for _, attribute := range attributes {
+		value, ok := attributeValue(attribute.GetValue())
+		if !ok || attribute.GetKey() == "" {
+			continue
+		}
+		labels[sanitizeLabelName(attribute.GetKey())] = value
+	}
+	if len(labels) == 0 {
+		return nil
+	}
+	return labels
+}
+
+// attributeValue возвращает строковое представление скалярного значения атрибута.
+func attributeValue(value *commonpb.AnyValue) (string, bool) {
+	switch v := value.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return v.StringValue, true
+	case *commonpb.AnyValue_BoolValue:
+		return strconv.FormatBool(v.BoolValue), true
+	case *commonpb.AnyValue_IntValue:
+		return strconv.FormatInt(v.IntValue, 10), true
+	case *commonpb.AnyValue_DoubleValue:
+		return strconv.FormatFloat(v.DoubleValue, 'g', -1, 64), true
+	}
+	return "", false
+}
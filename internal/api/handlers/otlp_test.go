@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/eac0de/getmetrics/internal/models"
+	"github.com/eac0de/getmetrics/internal/storage/memstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func stringAttribute(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}}
+}
+
+func TestOTLPMetricsHandlerProtobuf(t *testing.T) {
+	store := memstore.New()
+	mh := NewMetricsHandlers(store, "", slog.Default())
+	sum := 3.5
+	req := &colmetricspb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{{
+			Resource: &resourcepb.Resource{Attributes: []*commonpb.KeyValue{
+				stringAttribute("service.name", "checkout"),
+				{Key: "tags", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{}}},
+			}},
+			ScopeMetrics: []*metricspb.ScopeMetrics{{
+				Metrics: []*metricspb.Metric{
+					{
+						Name: "queue.size",
+						Data: &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: []*metricspb.NumberDataPoint{
+							{TimeUnixNano: 2, Value: &metricspb.NumberDataPoint_AsDouble{AsDouble: 7}},
+							{TimeUnixNano: 1, Value: &metricspb.NumberDataPoint_AsDouble{AsDouble: 3}},
+						}}},
+					},
+					{
+						Name: "requests",
+						Data: &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+							IsMonotonic:            true,
+							AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+							DataPoints: []*metricspb.NumberDataPoint{{
+								Attributes: []*commonpb.KeyValue{stringAttribute("service.name", "cart"), stringAttribute("code", "200")},
+								Value:      &metricspb.NumberDataPoint_AsInt{AsInt: 42},
+							}},
+						}},
+					},
+					{
+						Name: "latency",
+						Data: &metricspb.Metric_Histogram{Histogram: &metricspb.Histogram{
+							AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA,
+							DataPoints: []*metricspb.HistogramDataPoint{{
+								ExplicitBounds: []float64{1, 10},
+								BucketCounts:   []uint64{1, 2, 0},
+								Count:          3,
+								Sum:            &sum,
+							}},
+						}},
+					},
+					{
+						Name: "sizes",
+						Data: &metricspb.Metric_Summary{Summary: &metricspb.Summary{DataPoints: []*metricspb.SummaryDataPoint{{}, {}}}},
+					},
+				},
+			}},
+		}},
+	}
+	body, err := proto.Marshal(req)
+	require.NoError(t, err)
+
+	send := func() *colmetricspb.ExportMetricsServiceResponse {
+		r := httptest.NewRequest(http.MethodPost, "/v1/metrics", bytes.NewReader(body))
+		r.Header.Set("Content-Type", "application/x-protobuf")
+		rec := httptest.NewRecorder()
+		mh.OTLPMetricsHandler()(rec, r)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, "application/x-protobuf", rec.Header().Get("Content-Type"))
+		var resp colmetricspb.ExportMetricsServiceResponse
+		require.NoError(t, proto.Unmarshal(rec.Body.Bytes(), &resp))
+		return &resp
+	}
+	resp := send()
+	assert.Equal(t, int64(2), resp.GetPartialSuccess().GetRejectedDataPoints())
+	assert.Contains(t, resp.GetPartialSuccess().GetErrorMessage(), "summaries are not supported")
+
+	ctx := context.Background()
+	resourceLabels := models.Labels{"service_name": "checkout"}
+	metric, err := store.GetMetric(ctx, "queue.size", models.Gauge, resourceLabels)
+	require.NoError(t, err)
+	assert.Equal(t, 7.0, *metric.Value)
+	metric, err = store.GetMetric(ctx, "requests", models.Counter, models.Labels{"service_name": "cart", "code": "200"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), *metric.Delta)
+	metric, err = store.GetMetric(ctx, "latency", models.Histogram, resourceLabels)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{1, 2, 0}, metric.Histogram.Counts)
+
+	// Накопительные значения заменяют сохраненные, дельта-гистограммы прибавляются к ним
+	send()
+	metric, err = store.GetMetric(ctx, "requests", models.Counter, models.Labels{"service_name": "cart", "code": "200"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), *metric.Delta)
+	metric, err = store.GetMetric(ctx, "latency", models.Histogram, resourceLabels)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{2, 4, 0}, metric.Histogram.Counts)
+	assert.Equal(t, 7.0, metric.Histogram.Sum)
+}
+
+func TestOTLPMetricsHandlerJSON(t *testing.T) {
+	store := memstore.New()
+	mh := NewMetricsHandlers(store, "", slog.Default())
+	body := `{"resourceMetrics":[{"scopeMetrics":[{"metrics":[
+		{"name":"connections","sum":{"aggregationTemporality":1,"isMonotonic":true,
+			"dataPoints":[{"asInt":"5","attributes":[{"key":"peer","value":{"stringValue":"db"}}]}]}},
+		{"name":"temperature","gauge":{"dataPoints":[{"asDouble":21.5}]}}
+	]}]}]}`
+	r := httptest.NewRequest(http.MethodPost, "/v1/metrics", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	mh.OTLPMetricsHandler()(rec, r)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.JSONEq(t, `{}`, rec.Body.String())
+
+	metric, err := store.GetMetric(context.Background(), "connections", models.Counter, models.Labels{"peer": "db"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), *metric.Delta)
+	r = httptest.NewRequest(http.MethodPost, "/v1/metrics", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	mh.OTLPMetricsHandler()(httptest.NewRecorder(), r)
+	metric, err = store.GetMetric(context.Background(), "connections", models.Counter, models.Labels{"peer": "db"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), *metric.Delta)
+	metric, err = store.GetMetric(context.Background(), "temperature", models.Gauge, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 21.5, *metric.Value)
+}
+
+func TestOTLPMetricsHandlerInvalidRequest(t *testing.T) {
+	mh := NewMetricsHandlers(memstore.New(), "", slog.Default())
+	tests := []struct {
+		contentType string
+		body        string
+		statusCode  int
+	}{
+		{contentType: "text/plain", body: "", statusCode: http.StatusUnsupportedMediaType},
+		{contentType: "application/json", body: "{", statusCode: http.StatusBadRequest},
+		{contentType: "application/x-protobuf", body: "\xff\xff", statusCode: http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/v1/metrics", strings.NewReader(test.body))
+		r.Header.Set("Content-Type", test.contentType)
+		rec := httptest.NewRecorder()
+		mh.OTLPMetricsHandler()(rec, r)
+		assert.Equal(t, test.statusCode, rec.Code, test.contentType)
+	}
+}
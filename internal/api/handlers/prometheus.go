@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/eac0de/getmetrics/internal/models"
+)
+
+const (
+	// prometheusContentType - тип контента текстового формата Prometheus.
+	prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+	// openMetricsContentType - тип контента формата OpenMetrics.
+	openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+)
+
+// PrometheusMetricsHandler возвращает HTTP-обработчик, отдающий все метрики хранилища
+// в текстовом формате Prometheus.
+//
+// Метрики типа gauge отдаются как gauge, метрики типа counter - как counter,
+// метрики типа histogram - как histogram с корзинами, суммой и количеством.
+// Если клиент указывает в заголовке Accept тип application/openmetrics-text,
+// ответ формируется в формате OpenMetrics.
+func (h *MetricsHandlers) PrometheusMetricsHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		metrics, err := h.MetricsStore.ListAllMetrics(r.Context())
+		if err != nil {
+			h.writeError(w, r, err)
+			return
+		}
+		openMetrics := strings.Contains(r.Header.Get("Accept"), "application/openmetrics-text")
+		data := renderPrometheus(metrics, openMetrics)
+		if openMetrics {
+			w.Header().Set("Content-Type", openMetricsContentType)
+		} else {
+			w.Header().Set("Content-Type", prometheusContentType)
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write(data)
+	}
+}
+
+// prometheusFamily - семейство метрик Prometheus: ряды с одним именем и типом.
+type prometheusFamily struct {
+	name    string
+	mType   string
+	metrics []*models.Metric
+}
+
+// renderPrometheus формирует текстовое представление метрик в формате Prometheus или OpenMetrics.
+//
+// Ряды группируются по имени семейства после замены недопустимых символов, поэтому строка TYPE
+// выводится один раз, даже если ряды семейства получены из разных идентификаторов.
+// Если имя семейства уже занято метриками другого типа, к имени добавляется суффикс с типом,
+// например requests_gauge. Ряд, для которого и такое имя занято, не выводится.
+func renderPrometheus(metrics []*models.Metric, openMetrics bool) []byte {
+	sort.Slice(metrics, func(i, j int) bool {
+		if metrics[i].ID != metrics[j].ID {
+			return metrics[i].ID < metrics[j].ID
+		}
+		if metrics[i].MType != metrics[j].MType {
+			return metrics[i].MType < metrics[j].MType
+		}
+		return metrics[i].Labels.String() < metrics[j].Labels.String()
+	})
+	families := map[string]*prometheusFamily{}
+	for _, metric := range metrics {
+		if !hasPrometheusValue(metric) {
+			continue
+		}
+		name := prometheusFamilyName(metric, openMetrics)
+		if family, ok := families[name]; ok && family.mType != metric.MType {
+			name += "_" + metric.MType
+		}
+		family, ok := families[name]
+		if !ok {
+			family = &prometheusFamily{name: name, mType: metric.MType}
+			families[name] = family
+		}
+		if family.mType != metric.MType {
+			continue
+		}
+		family.metrics = append(family.metrics, metric)
+	}
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	for _, name := range names {
+		family := families[name]
+		fmt.Fprintf(&buf, "# TYPE %s %s\n", family.name, family.mType)
+		for _, metric := range family.metrics {
+			labels := prometheusLabels(metric.Labels)
+			switch metric.MType {
+			case models.Gauge:
+				fmt.Fprintf(&buf, "%s%s %s\n", family.name, labels, formatPrometheusValue(*metric.Value))
+			case models.Counter:
+				sampleName := family.name
+				if openMetrics {
+					// В OpenMetrics имя семейства счетчика не содержит суффикс _total, а имя значения - содержит.
+					sampleName += "_total"
+				}
+				fmt.Fprintf(&buf, "%s%s %d\n", sampleName, labels, *metric.Delta)
+			case models.Histogram:
+				writePrometheusHistogram(&buf, family.name, metric.Labels, metric.Histogram)
+			}
+		}
+	}
+	if openMetrics {
+		buf.WriteString("# EOF\n")
+	}
+	return buf.Bytes()
+}
+
+// hasPrometheusValue сообщает, заполнено ли значение метрики ее типа.
+func hasPrometheusValue(metric *models.Metric) bool {
+	switch metric.MType {
+	case models.Gauge:
+		return metric.Value != nil
+	case models.Counter:
+		return metric.Delta != nil
+	case models.Histogram:
+		return metric.Histogram != nil
+	}
+	return false
+}
+
+// prometheusFamilyName возвращает имя семейства метрики.
+func prometheusFamilyName(metric *models.Metric, openMetrics bool) string {
+	name := prometheusName(metric.ID)
+	if openMetrics && metric.MType == models.Counter {
+		name = strings.TrimSuffix(name, "_total")
+	}
+	return name
+}
+
+// writePrometheusHistogram выводит корзины гистограммы с накопленными значениями, сумму и количество.
+func writePrometheusHistogram(buf *bytes.Buffer, name string, labels models.Labels, histogram *models.HistogramValue) {
+	bucketLabels := make(models.Labels, len(labels)+1)
+	for k, v := range labels {
+		bucketLabels[k] = v
+	}
+	var cumulative uint64
+	for i, count := range histogram.Counts {
+		cumulative += count
+		le := "+Inf"
+		if i < len(histogram.Bounds) {
+			le = formatPrometheusValue(histogram.Bounds[i])
+		}
+		bucketLabels["le"] = le
+		fmt.Fprintf(buf, "%s_bucket%s %d\n", name, prometheusLabels(bucketLabels), cumulative)
+	}
+	fmt.Fprintf(buf, "%s_sum%s %s\n", name, prometheusLabels(labels), formatPrometheusValue(histogram.Sum))
+	fmt.Fprintf(buf, "%s_count%s %d\n", name, prometheusLabels(labels), histogram.Count)
+}
+
+// prometheusLabels форматирует метки в виде {name="value",...}, экранируя значения.
+// Для пустого набора возвращает пустую строку.
+func prometheusLabels(labels models.Labels) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `%s="%s"`, name, escaper.Replace(labels[name]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// prometheusName заменяет символы, недопустимые в имени метрики Prometheus, на подчеркивание.
+func prometheusName(id string) string {
+	var b strings.Builder
+	for i, c := range id {
+		valid := c == '_' || c == ':' ||
+			(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') ||
+			(i > 0 && c >= '0' && c <= '9')
+		if !valid {
+			c = '_'
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+func formatPrometheusValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
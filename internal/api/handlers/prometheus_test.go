@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eac0de/getmetrics/internal/models"
+	"github.com/eac0de/getmetrics/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrometheusMetricsHandler(t *testing.T) {
+	storeMetrics := func() []*models.Metric {
+		return []*models.Metric{
+			{ID: "PollCount", MType: models.Counter, Delta: func(v int64) *int64 { return &v }(5)},
+			{ID: "HeapAlloc", MType: models.Gauge, Value: func(v float64) *float64 { return &v }(1.5)},
+			{ID: "cpu.load-1", MType: models.Gauge, Value: func(v float64) *float64 { return &v }(2)},
+		}
+	}
+	tests := []struct {
+		name        string
+		accept      string
+		contentType string
+		body        string
+	}{
+		{
+			name:        "prometheus text",
+			contentType: prometheusContentType,
+			body: "# TYPE HeapAlloc gauge\nHeapAlloc 1.5\n" +
+				"# TYPE PollCount counter\nPollCount 5\n" +
+				"# TYPE cpu_load_1 gauge\ncpu_load_1 2\n",
+		},
+		{
+			name:        "openmetrics",
+			accept:      "application/openmetrics-text;version=1.0.0,text/plain;q=0.5",
+			contentType: openMetricsContentType,
+			body: "# TYPE HeapAlloc gauge\nHeapAlloc 1.5\n" +
+				"# TYPE PollCount counter\nPollCount_total 5\n" +
+				"# TYPE cpu_load_1 gauge\ncpu_load_1 2\n" +
+				"# EOF\n",
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	metricsStore := mocks.NewMockIMetricsStore(ctrl)
+	mh := NewMetricsHandlers(metricsStore, "", slog.Default())
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			metricsStore.EXPECT().ListAllMetrics(gomock.Any()).Return(storeMetrics(), nil)
+			r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if test.accept != "" {
+				r.Header.Set("Accept", test.accept)
+			}
+			w := httptest.NewRecorder()
+			mh.PrometheusMetricsHandler()(w, r)
+			resp := w.Result()
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, test.contentType, resp.Header.Get("Content-Type"))
+			assert.Equal(t, test.body, string(body))
+		})
+	}
+}
+
+func TestRenderPrometheusLabels(t *testing.T) {
+	metrics := []*models.Metric{
+		{ID: "HeapAlloc", MType: models.Gauge, Value: func(v float64) *float64 { return &v }(2), Labels: models.Labels{"host": "host2"}},
+		{ID: "HeapAlloc", MType: models.Gauge, Value: func(v float64) *float64 { return &v }(1), Labels: models.Labels{"host": "host1", "instance": `a"b`}},
+		{ID: "PollCount", MType: models.Counter, Delta: func(v int64) *int64 { return &v }(3), Labels: models.Labels{"host": "host1"}},
+	}
+	assert.Equal(t,
+		"# TYPE HeapAlloc gauge\n"+
+			"HeapAlloc{host=\"host1\",instance=\"a\\\"b\"} 1\n"+
+			"HeapAlloc{host=\"host2\"} 2\n"+
+			"# TYPE PollCount counter\n"+
+			"PollCount{host=\"host1\"} 3\n",
+		string(renderPrometheus(metrics, false)),
+	)
+}
+
+func TestRenderPrometheusHistogram(t *testing.T) {
+	metrics := []*models.Metric{{
+		ID:        "GCPauses",
+		MType:     models.Histogram,
+		Histogram: &models.HistogramValue{Bounds: []float64{0.001, 0.01}, Counts: []uint64{3, 2, 1}, Sum: 0.05, Count: 6},
+		Labels:    models.Labels{"host": "host1"},
+	}}
+	assert.Equal(t,
+		"# TYPE GCPauses histogram\n"+
+			"GCPauses_bucket{host=\"host1\",le=\"0.001\"} 3\n"+
+			"GCPauses_bucket{host=\"host1\",le=\"0.01\"} 5\n"+
+			"GCPauses_bucket{host=\"host1\",le=\"+Inf\"} 6\n"+
+			"GCPauses_sum{host=\"host1\"} 0.05\n"+
+			"GCPauses_count{host=\"host1\"} 6\n",
+		string(renderPrometheus(metrics, false)),
+	)
+}
+
+func TestRenderPrometheusFamilies(t *testing.T) {
+	gauge := func(v float64) *float64 { return &v }
+	delta := func(v int64) *int64 { return &v }
+	metrics := []*models.Metric{
+		{ID: "queue.size", MType: models.Gauge, Value: gauge(1), Labels: models.Labels{"host": "host1"}},
+		{ID: "queue_latency", MType: models.Gauge, Value: gauge(5)},
+		{ID: "queue_size", MType: models.Gauge, Value: gauge(2), Labels: models.Labels{"host": "host2"}},
+		{ID: "requests", MType: models.Counter, Delta: delta(3)},
+		{ID: "requests", MType: models.Gauge, Value: gauge(4)},
+	}
+	assert.Equal(t,
+		"# TYPE queue_latency gauge\n"+
+			"queue_latency 5\n"+
+			"# TYPE queue_size gauge\n"+
+			"queue_size{host=\"host1\"} 1\n"+
+			"queue_size{host=\"host2\"} 2\n"+
+			"# TYPE requests counter\n"+
+			"requests 3\n"+
+			"# TYPE requests_gauge gauge\n"+
+			"requests_gauge 4\n",
+		string(renderPrometheus(metrics, false)),
+	)
+
+	// В OpenMetrics счетчик requests_total и gauge requests относятся к одному семейству requests.
+	metrics = []*models.Metric{
+		{ID: "requests", MType: models.Gauge, Value: gauge(4)},
+		{ID: "requests_total", MType: models.Counter, Delta: delta(3)},
+	}
+	assert.Equal(t,
+		"# TYPE requests gauge\n"+
+			"requests 4\n"+
+			"# TYPE requests_counter counter\n"+
+			"requests_counter_total 3\n"+
+			"# EOF\n",
+		string(renderPrometheus(metrics, true)),
+	)
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	stderr "errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eac0de/getmetrics/internal/models"
+	"github.com/eac0de/getmetrics/pkg/errors"
+	"github.com/eac0de/getmetrics/pkg/remotewrite"
+	"github.com/golang/snappy"
+)
+
+// remoteWriteV1Proto - сообщение remote_write 1.0, единственное поддерживаемое.
+const remoteWriteV1Proto = "prometheus.WriteRequest"
+
+// PrometheusRemoteWriteHandler возвращает HTTP-обработчик для приема метрик по протоколу
+// Prometheus remote_write 1.0.
+//
+// Тело запроса - WriteRequest в формате protobuf, сжатый snappy. Метка __name__ становится
+// идентификатором метрики, остальные метки - ее метками. Сохраняется последнее по временной
+// метке значение ряда: для имен с суффиксом _total - как значение счетчика (с округлением до
+// целого), для остальных - как значение gauge. Маркеры устаревания (NaN) пропускаются.
+//
+// Корректные ряды сохраняются, даже если в запросе есть ошибочные. При ошибках возвращается
+// статус 400 со списком ошибок, иначе - статус 204 (No Content).
+func (h *MetricsHandlers) PrometheusRemoteWriteHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := checkRemoteWriteHeaders(r)
+		if err != nil {
+			h.writeError(w, r, err)
+			return
+		}
+		compressed, err := io.ReadAll(r.Body)
+		if err != nil {
+			h.writeError(w, r, errors.NewErrorWithHTTPStatus(err, "Unable to read body", http.StatusBadRequest))
+			return
+		}
+		data, err := snappy.Decode(nil, compressed)
+		if err != nil {
+			h.writeError(w, r, errors.NewErrorWithHTTPStatus(err, "Invalid snappy payload", http.StatusBadRequest))
+			return
+		}
+		req, err := remotewrite.Unmarshal(data)
+		if err != nil {
+			h.writeError(w, r, errors.NewErrorWithHTTPStatus(err, "Invalid request payload", http.StatusBadRequest))
+			return
+		}
+
+		var points []timedMetric
+		var errsList []error
+		for i := range req.Timeseries {
+			point, ok, err := h.convertTimeSeries(&req.Timeseries[i])
+			if err != nil {
+				errsList = append(errsList, fmt.Errorf("series %d: %w", i, err))
+				continue
+			}
+			if ok {
+				points = append(points, point)
+			}
+		}
+		metricsList := latestMetrics(points)
+		if len(metricsList) > 0 {
+			err = h.MetricsStore.SaveMetrics(r.Context(), metricsList)
+			if err != nil {
+				h.writeError(w, r, err)
+				return
+			}
+		}
+		if len(errsList) > 0 {
+			err = stderr.Join(errsList...)
+			h.writeError(w, r, errors.NewErrorWithHTTPStatus(err, err.Error(), http.StatusBadRequest))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// checkRemoteWriteHeaders проверяет, что запрос содержит сообщение remote_write 1.0, сжатое snappy.
+func checkRemoteWriteHeaders(r *http.Request) error {
+	contentType, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if contentType != contentTypeProtobuf {
+		err := fmt.Errorf("unsupported content type %q", contentType)
+		return errors.NewErrorWithHTTPStatus(err, "Content-Type must be application/x-protobuf", http.StatusUnsupportedMediaType)
+	}
+	if proto, ok := params["proto"]; ok && proto != remoteWriteV1Proto {
+		err := fmt.Errorf("unsupported remote write message %q", proto)
+		return errors.NewErrorWithHTTPStatus(err, "Only remote write 1.0 (prometheus.WriteRequest) is supported", http.StatusUnsupportedMediaType)
+	}
+	if encoding := r.Header.Get("Content-Encoding"); encoding != "snappy" {
+		err := fmt.Errorf("unsupported content encoding %q", encoding)
+		return errors.NewErrorWithHTTPStatus(err, "Content-Encoding must be snappy", http.StatusUnsupportedMediaType)
+	}
+	return nil
+}
+
+// convertTimeSeries возвращает метрику с последним значением ряда. Если у ряда нет значений,
+// кроме маркеров устаревания, ok равен false.
+func (h *MetricsHandlers) convertTimeSeries(ts *remotewrite.TimeSeries) (point timedMetric, ok bool, err error) {
+	name := ts.Name()
+	if name == "" {
+		return timedMetric{}, false, fmt.Errorf("label __name__ is required")
+	}
+	if len(ts.Samples) == 0 && ts.Histograms > 0 {
+		return timedMetric{}, false, fmt.Errorf("metric %s: native histograms are not supported", name)
+	}
+	var latest *remotewrite.Sample
+	for i, sample := range ts.Samples {
+		if math.IsNaN(sample.Value) {
+			continue
+		}
+		if latest == nil || sample.Timestamp >= latest.Timestamp {
+			latest = &ts.Samples[i]
+		}
+	}
+	if latest == nil {
+		return timedMetric{}, false, nil
+	}
+
+	if math.IsInf(latest.Value, 0) {
+		return timedMetric{}, false, fmt.Errorf("metric %s: value must be finite", name)
+	}
+	metric := models.Metric{ID: name}
+	if strings.HasSuffix(name, "_total") {
+		delta := int64(math.Round(latest.Value))
+		metric.MType, metric.Delta = models.Counter, &delta
+	} else {
+		value := latest.Value
+		metric.MType, metric.Value = models.Gauge, &value
+	}
+	for _, label := range ts.Labels {
+		if label.Name == "__name__" || label.Value == "" {
+			continue
+		}
+		if metric.Labels == nil {
+			metric.Labels = models.Labels{}
+		}
+		metric.Labels[label.Name] = label.Value
+	}
+	err = h.validateMetric(metric)
+	if err != nil {
+		return timedMetric{}, false, err
+	}
+	timestamp := latest.Timestamp * int64(time.Millisecond)
+	return timedMetric{metric: metric, timestamp: timestamp}, true, nil
+}
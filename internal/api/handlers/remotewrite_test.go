@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eac0de/getmetrics/internal/models"
+	"github.com/eac0de/getmetrics/internal/storage/memstore"
+	"github.com/eac0de/getmetrics/pkg/remotewrite"
+	"github.com/golang/snappy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRemoteWriteRequest(req *remotewrite.WriteRequest) *http.Request {
+	body := snappy.Encode(nil, req.Marshal())
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/write", bytes.NewReader(body))
+	r.Header.Set("Content-Type", "application/x-protobuf")
+	r.Header.Set("Content-Encoding", "snappy")
+	r.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	return r
+}
+
+func TestPrometheusRemoteWriteHandler(t *testing.T) {
+	store := memstore.New()
+	mh := NewMetricsHandlers(store, "", slog.Default())
+	r := newRemoteWriteRequest(&remotewrite.WriteRequest{Timeseries: []remotewrite.TimeSeries{
+		{
+			Labels: []remotewrite.Label{{Name: "__name__", Value: "http_requests_total"}, {Name: "code", Value: "200"}},
+			Samples: []remotewrite.Sample{
+				{Value: 15.4, Timestamp: 2000},
+				{Value: 10, Timestamp: 1000},
+			},
+		},
+		{
+			Labels:  []remotewrite.Label{{Name: "__name__", Value: "temperature"}},
+			Samples: []remotewrite.Sample{{Value: 21.5, Timestamp: 1000}, {Value: math.NaN(), Timestamp: 2000}},
+		},
+		{
+			Labels:  []remotewrite.Label{{Name: "__name__", Value: "stale"}},
+			Samples: []remotewrite.Sample{{Value: math.NaN(), Timestamp: 1000}},
+		},
+	}})
+	w := httptest.NewRecorder()
+	mh.PrometheusRemoteWriteHandler()(w, r)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	counter, err := store.GetMetric(context.Background(), "http_requests_total", models.Counter, models.Labels{"code": "200"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(15), *counter.Delta)
+	gauge, err := store.GetMetric(context.Background(), "temperature", models.Gauge, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 21.5, *gauge.Value)
+	_, err = store.GetMetric(context.Background(), "stale", models.Gauge, nil)
+	assert.Error(t, err)
+}
+
+func TestPrometheusRemoteWriteHandlerPartial(t *testing.T) {
+	store := memstore.New()
+	mh := NewMetricsHandlers(store, "", slog.Default())
+	r := newRemoteWriteRequest(&remotewrite.WriteRequest{Timeseries: []remotewrite.TimeSeries{
+		{
+			Labels:  []remotewrite.Label{{Name: "job", Value: "node"}},
+			Samples: []remotewrite.Sample{{Value: 1, Timestamp: 1000}},
+		},
+		{
+			Labels:  []remotewrite.Label{{Name: "__name__", Value: "up"}},
+			Samples: []remotewrite.Sample{{Value: 1, Timestamp: 1000}},
+		},
+	}})
+	w := httptest.NewRecorder()
+	mh.PrometheusRemoteWriteHandler()(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "series 0: label __name__ is required")
+
+	gauge, err := store.GetMetric(context.Background(), "up", models.Gauge, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, *gauge.Value)
+}
+
+func TestPrometheusRemoteWriteHandlerUnsupported(t *testing.T) {
+	mh := NewMetricsHandlers(memstore.New(), "", slog.Default())
+	tests := []struct {
+		name       string
+		modify     func(r *http.Request)
+		statusCode int
+	}{
+		{
+			name:       "content type",
+			modify:     func(r *http.Request) { r.Header.Set("Content-Type", "application/json") },
+			statusCode: http.StatusUnsupportedMediaType,
+		},
+		{
+			name: "remote write 2.0",
+			modify: func(r *http.Request) {
+				r.Header.Set("Content-Type", "application/x-protobuf;proto=io.prometheus.write.v2.Request")
+			},
+			statusCode: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:       "encoding",
+			modify:     func(r *http.Request) { r.Header.Set("Content-Encoding", "gzip") },
+			statusCode: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:       "invalid snappy",
+			modify:     func(r *http.Request) { r.Body = io.NopCloser(bytes.NewReader([]byte{0xff})) },
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid protobuf",
+			modify:     func(r *http.Request) { r.Body = io.NopCloser(bytes.NewReader(snappy.Encode(nil, []byte{0x0a, 0x05}))) },
+			statusCode: http.StatusBadRequest,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := newRemoteWriteRequest(&remotewrite.WriteRequest{})
+			test.modify(r)
+			w := httptest.NewRecorder()
+			mh.PrometheusRemoteWriteHandler()(w, r)
+			assert.Equal(t, test.statusCode, w.Code)
+		})
+	}
+}
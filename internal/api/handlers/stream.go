@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"encoding/json"
+	stderr "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/eac0de/getmetrics/internal/models"
+	"github.com/eac0de/getmetrics/pkg/errors"
+)
+
+// streamKeepAliveInterval - интервал отправки комментариев, не дающих прокси закрыть неактивный поток.
+const streamKeepAliveInterval = 15 * time.Second
+
+// IMetricsStream интерфейс источника обновлений метрик.
+type IMetricsStream interface {
+	// Subscribe подписывается на сохранение метрик, идентификатор которых подходит под шаблон
+	// в формате path.Match. Канал закрывается после вызова unsubscribe или при отключении подписчика.
+	Subscribe(pattern string) (events <-chan models.Metric, unsubscribe func(), err error)
+}
+
+// StreamHandler возвращает HTTP-обработчик, передающий обновления метрик в формате Server-Sent Events.
+//
+// Параметр match задает шаблон идентификаторов метрик в формате path.Match, например "CPU*".
+// Если он не указан, передаются обновления всех метрик. Каждое сохранение подходящей метрики
+// отправляется событием metric с метрикой в формате JSON в поле data.
+//
+// Поток завершается, если клиент не успевает читать обновления или сервер останавливается.
+// Клиент может переподключиться и получить текущие значения через /value/.
+func (h *MetricsHandlers) StreamHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.Stream == nil {
+			http.Error(w, "Metrics stream is not configured", http.StatusNotFound)
+			return
+		}
+		events, unsubscribe, err := h.Stream.Subscribe(r.URL.Query().Get("match"))
+		if stderr.Is(err, path.ErrBadPattern) {
+			h.writeError(w, r, errors.NewErrorWithHTTPStatus(err, "Invalid match pattern", http.StatusBadRequest))
+			return
+		}
+		if err != nil {
+			h.writeError(w, r, errors.NewErrorWithHTTPStatus(err, "Metrics stream is unavailable", http.StatusServiceUnavailable))
+			return
+		}
+		defer unsubscribe()
+
+		rc := http.NewResponseController(w)
+		// Общий таймаут записи сервера не должен обрывать долгоживущий поток
+		rc.SetWriteDeadline(time.Time{})
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		err = rc.Flush()
+		if err != nil {
+			h.Logger.ErrorContext(r.Context(), "Metrics stream flush error", "error", err)
+			return
+		}
+
+		keepAlive := time.NewTicker(streamKeepAliveInterval)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case metric, ok := <-events:
+				if !ok {
+					return
+				}
+				data, jsonErr := json.Marshal(metric)
+				if jsonErr != nil {
+					h.Logger.ErrorContext(r.Context(), "Metrics stream encoding error", "error", jsonErr)
+					continue
+				}
+				_, err = fmt.Fprintf(w, "event: metric\ndata: %s\n\n", data)
+				if err == nil {
+					err = rc.Flush()
+				}
+			case <-keepAlive.C:
+				_, err = io.WriteString(w, ": keep-alive\n\n")
+				if err == nil {
+					err = rc.Flush()
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/eac0de/getmetrics/internal/models"
+	"github.com/eac0de/getmetrics/internal/storage/memstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testStream - источник обновлений, отдающий заранее созданный канал.
+type testStream struct {
+	pattern string
+	events  chan models.Metric
+}
+
+func (s *testStream) Subscribe(pattern string) (<-chan models.Metric, func(), error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, nil, err
+	}
+	s.pattern = pattern
+	return s.events, func() {}, nil
+}
+
+func TestStreamHandler(t *testing.T) {
+	stream := &testStream{events: make(chan models.Metric, 1)}
+	mh := NewMetricsHandlers(memstore.New(), "", slog.Default())
+	mh.Stream = stream
+	server := httptest.NewServer(http.HandlerFunc(mh.StreamHandler()))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "?match=cpu*")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "cpu*", stream.pattern)
+
+	value := 0.75
+	stream.events <- models.Metric{ID: "cpu", MType: models.Gauge, Value: &value}
+	reader := bufio.NewReader(resp.Body)
+	line, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "event: metric\n", line)
+	line, err = reader.ReadString('\n')
+	require.NoError(t, err)
+	data, ok := strings.CutPrefix(strings.TrimSpace(line), "data: ")
+	require.True(t, ok)
+	var metric models.Metric
+	require.NoError(t, json.Unmarshal([]byte(data), &metric))
+	assert.Equal(t, "cpu", metric.ID)
+	assert.Equal(t, 0.75, *metric.Value)
+
+	// После закрытия канала поток завершается
+	close(stream.events)
+	rest, err := reader.ReadString(0)
+	assert.Equal(t, "\n", rest)
+	assert.Error(t, err)
+}
+
+func TestStreamHandlerErrors(t *testing.T) {
+	mh := NewMetricsHandlers(memstore.New(), "", slog.Default())
+	w := httptest.NewRecorder()
+	mh.StreamHandler()(w, httptest.NewRequest(http.MethodGet, "/api/v1/stream", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	mh.Stream = &testStream{}
+	w = httptest.NewRecorder()
+	mh.StreamHandler()(w, httptest.NewRequest(http.MethodGet, "/api/v1/stream?match=[", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,77 @@
+package pb
+
+import "github.com/eac0de/getmetrics/internal/models"
+
+// FromModel преобразует models.Metric в сообщение Metric.
+func FromModel(metric models.Metric) *Metric {
+	return &Metric{
+		Id:        metric.ID,
+		Type:      metric.MType,
+		Delta:     metric.Delta,
+		Value:     metric.Value,
+		Labels:    metric.Labels,
+		Histogram: fromHistogram(metric.Histogram),
+	}
+}
+
+func fromHistogram(histogram *models.HistogramValue) *Histogram {
+	if histogram == nil {
+		return nil
+	}
+	return &Histogram{
+		Bounds: histogram.Bounds,
+		Counts: histogram.Counts,
+		Sum:    histogram.Sum,
+		Count:  histogram.Count,
+	}
+}
+
+// FromModels преобразует список models.Metric в список сообщений Metric.
+func FromModels(metricsList []models.Metric) []*Metric {
+	result := make([]*Metric, 0, len(metricsList))
+	for _, metric := range metricsList {
+		result = append(result, FromModel(metric))
+	}
+	return result
+}
+
+// ToModel преобразует сообщение Metric в models.Metric.
+func (m *Metric) ToModel() models.Metric {
+	return models.Metric{
+		ID:        m.GetId(),
+		MType:     m.GetType(),
+		Delta:     m.Delta,
+		Value:     m.Value,
+		Labels:    ToLabels(m.GetLabels()),
+		Histogram: m.GetHistogram().toModel(),
+	}
+}
+
+func (h *Histogram) toModel() *models.HistogramValue {
+	if h == nil {
+		return nil
+	}
+	return &models.HistogramValue{
+		Bounds: h.GetBounds(),
+		Counts: h.GetCounts(),
+		Sum:    h.GetSum(),
+		Count:  h.GetCount(),
+	}
+}
+
+// ToLabels преобразует метки сообщения в models.Labels. Пустой набор меток преобразуется в nil.
+func ToLabels(labels map[string]string) models.Labels {
+	if len(labels) == 0 {
+		return nil
+	}
+	return labels
+}
+
+// ToModels преобразует список сообщений Metric в список models.Metric.
+func ToModels(metricsList []*Metric) []models.Metric {
+	result := make([]models.Metric, 0, len(metricsList))
+	for _, metric := range metricsList {
+		result = append(result, metric.ToModel())
+	}
+	return result
+}
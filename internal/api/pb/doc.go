@@ -0,0 +1,4 @@
+// Package pb содержит код gRPC-сервиса метрик, сгенерированный из proto/metrics.proto.
+package pb
+
+//go:generate protoc -I ../../../proto --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative metrics.proto
@@ -1,30 +1,76 @@
 package server
 
 import (
-	"log"
+	"context"
+	"errors"
+	"net"
 	"net/http"
 
-	"github.com/go-chi/chi/v5"
+	"google.golang.org/grpc"
 )
 
 type Server struct {
-	Addr string
+	Addr       string
+	httpServer *http.Server
 }
 
-func New(addr string) *Server {
-	return &Server{Addr: addr}
+func New(addr string, handler http.Handler) *Server {
+	return &Server{
+		Addr:       addr,
+		httpServer: &http.Server{Addr: addr, Handler: handler},
+	}
 }
 
-func (s *Server) Run(router chi.Router) {
-	err := http.ListenAndServe(s.Addr, router)
-	if err != nil {
-		log.Fatal(err.Error())
+// Run принимает запросы до вызова Shutdown. После штатной остановки возвращает nil.
+func (s *Server) Run() error {
+	err := s.httpServer.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
 	}
+	return err
+}
+
+// Shutdown перестает принимать новые соединения и ждет завершения начатых запросов.
+// Если ctx истекает раньше, возвращает ошибку контекста.
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.httpServer.Shutdown(ctx)
+}
+
+type GRPCServer struct {
+	Addr       string
+	grpcServer *grpc.Server
 }
 
-func (s *Server) RunTLS(router chi.Router, privateKeyPath string) {
-	err := http.ListenAndServeTLS(s.Addr, "server.crt", privateKeyPath, router)
+func NewGRPC(addr string, grpcServer *grpc.Server) *GRPCServer {
+	return &GRPCServer{Addr: addr, grpcServer: grpcServer}
+}
+
+// Run принимает вызовы до вызова Shutdown. После штатной остановки возвращает nil.
+func (s *GRPCServer) Run() error {
+	listener, err := net.Listen("tcp", s.Addr)
 	if err != nil {
-		log.Fatal(err.Error())
+		return err
+	}
+	err = s.grpcServer.Serve(listener)
+	if errors.Is(err, grpc.ErrServerStopped) {
+		return nil
+	}
+	return err
+}
+
+// Shutdown ждет завершения начатых вызовов. Если ctx истекает раньше,
+// оставшиеся вызовы прерываются и возвращается ошибка контекста.
+func (s *GRPCServer) Shutdown(ctx context.Context) error {
+	stopped := make(chan struct{})
+	go func() {
+		s.grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		s.grpcServer.Stop()
+		return ctx.Err()
 	}
 }
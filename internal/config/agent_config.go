@@ -12,12 +12,23 @@ import (
 
 type (
 	AgentConfig struct {
-		ServerURL      string        `env:"ADDRESS" yaml:"addr"`
-		PollInterval   time.Duration `yaml:"poll_interval"`
-		ReportInterval time.Duration `yaml:"report_interval"`
-		SecretKey      string        `env:"KEY"`
-		RateLimit      int           `yaml:"rate_limit"`
-		PublicKeyPath  string        `env:"CRYPTO_KEY"`
+		ServerURL        string        `env:"ADDRESS" yaml:"addr"`
+		GRPCAddr         string        `env:"GRPC_ADDRESS" yaml:"grpc_addr"`
+		Transport        string        `env:"TRANSPORT" yaml:"transport"`
+		LogLevel         string        `env:"LOG_LEVEL" yaml:"log_level"`
+		LogFormat        string        `env:"LOG_FORMAT" yaml:"log_format"`
+		PollInterval     time.Duration `yaml:"poll_interval"`
+		ReportInterval   time.Duration `yaml:"report_interval"`
+		SecretKey        string        `env:"KEY"`
+		RateLimit        int           `env:"RATE_LIMIT" yaml:"rate_limit"`
+		QueueSize        int           `env:"REPORT_QUEUE_SIZE" yaml:"report_queue_size"`
+		QueuePolicy      string        `env:"REPORT_QUEUE_POLICY" yaml:"report_queue_policy"`
+		PublicKeyPath    string        `env:"CRYPTO_KEY"`
+		SpoolDir         string        `env:"SPOOL_DIR" yaml:"spool_dir"`
+		SpoolMaxSize     int64         `env:"SPOOL_MAX_SIZE" yaml:"spool_max_size"`
+		Retry            RetryConfig   `envPrefix:"RETRY_" yaml:"retry"`
+		HistogramBuckets []float64     `env:"HISTOGRAM_BUCKETS" envSeparator:"," yaml:"histogram_buckets"`
+		ShutdownTimeout  time.Duration `env:"SHUTDOWN_TIMEOUT" yaml:"shutdown_timeout"`
 	}
 
 	EnvAgentConfig struct {
@@ -77,12 +88,21 @@ func (c *AgentConfig) ReadJSON(filename string) error {
 func (c *AgentConfig) ReadServerFlags() {
 	pollInterval := int(c.PollInterval) / int(time.Second)
 	reportInterval := int(c.ReportInterval) / int(time.Second)
+	declareConfigFlags()
 	flag.StringVar(&c.ServerURL, "a", c.ServerURL, "server address")
+	flag.StringVar(&c.GRPCAddr, "grpc-addr", c.GRPCAddr, "server gRPC address")
+	flag.StringVar(&c.Transport, "transport", c.Transport, "report transport (http or grpc)")
+	flag.StringVar(&c.LogLevel, "ll", c.LogLevel, "agent log level")
+	flag.StringVar(&c.LogFormat, "lf", c.LogFormat, "agent log format (json or text)")
 	flag.IntVar(&pollInterval, "p", pollInterval, "report interval in seconds")
 	flag.IntVar(&reportInterval, "r", reportInterval, "poll interval in seconds")
 	flag.StringVar(&c.SecretKey, "k", c.SecretKey, "secret key")
-	flag.StringVar(&c.PublicKeyPath, "crypto-key", c.PublicKeyPath, "crypto key")
+	flag.StringVar(&c.PublicKeyPath, "crypto-key", c.PublicKeyPath, "path to RSA public key for payload encryption")
 	flag.IntVar(&c.RateLimit, "l", c.RateLimit, "rate limit")
+	flag.StringVar(&c.SpoolDir, "spool-dir", c.SpoolDir, "directory for undelivered reports")
+	flag.Int64Var(&c.SpoolMaxSize, "spool-max-size", c.SpoolMaxSize, "max spool size in bytes")
+	flag.IntVar(&c.QueueSize, "q", c.QueueSize, "report queue size")
+	flag.StringVar(&c.QueuePolicy, "qp", c.QueuePolicy, "report queue overflow policy (drop or coalesce)")
 	flag.Parse()
 	c.PollInterval = time.Duration(pollInterval) * time.Second
 	c.ReportInterval = time.Duration(reportInterval) * time.Second
@@ -103,8 +123,21 @@ func (c *AgentConfig) ReadEnvConfig() error {
 		return err
 	}
 	c.ServerURL = envConfig.ServerURL
+	c.GRPCAddr = envConfig.GRPCAddr
+	c.Transport = envConfig.Transport
+	c.LogLevel = envConfig.LogLevel
+	c.LogFormat = envConfig.LogFormat
 	c.PollInterval = time.Duration(envConfig.PollInterval) * time.Second
 	c.ReportInterval = time.Duration(envConfig.ReportInterval) * time.Second
 	c.SecretKey = envConfig.SecretKey
+	c.RateLimit = envConfig.RateLimit
+	c.QueueSize = envConfig.QueueSize
+	c.QueuePolicy = envConfig.QueuePolicy
+	c.PublicKeyPath = envConfig.PublicKeyPath
+	c.Retry = envConfig.Retry
+	c.HistogramBuckets = envConfig.HistogramBuckets
+	c.SpoolDir = envConfig.SpoolDir
+	c.SpoolMaxSize = envConfig.SpoolMaxSize
+	c.ShutdownTimeout = envConfig.ShutdownTimeout
 	return nil
 }
@@ -0,0 +1,9 @@
+package config
+
+import "time"
+
+type AlertingConfig struct {
+	RulesPath    string        `env:"RULES_PATH" yaml:"rules_path"`
+	EvalInterval time.Duration `env:"EVAL_INTERVAL" yaml:"eval_interval"`
+	Webhooks     []string      `env:"WEBHOOKS" envSeparator:"," yaml:"webhooks"`
+}
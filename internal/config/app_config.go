@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"flag"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/caarlos0/env/v6"
@@ -11,14 +12,22 @@ import (
 )
 
 type AppConfig struct {
-	Addr            string        `env:"ADDRESS" yaml:"addr"`
-	LogLevel        string        `env:"LOG_LEVEL" yaml:"log_level"`
-	StoreInterval   time.Duration `yaml:"store_interval"`
-	FileStoragePath string        `env:"FILE_STORAGE_PATH" yaml:"file_storage_path"`
-	Restore         bool          `env:"RESTORE" yaml:"restore"`
-	DatabaseDSN     string        `env:"DATABASE_DSN" yaml:"database_dsn"`
-	SecretKey       string        `env:"KEY"`
-	PrivateKeyPath  string        `env:"CRYPTO_KEY"`
+	Addr            string         `env:"ADDRESS" yaml:"addr"`
+	GRPCAddr        string         `env:"GRPC_ADDRESS" yaml:"grpc_addr"`
+	LogLevel        string         `env:"LOG_LEVEL" yaml:"log_level"`
+	LogFormat       string         `env:"LOG_FORMAT" yaml:"log_format"`
+	StoreInterval   time.Duration  `yaml:"store_interval"`
+	FileStoragePath string         `env:"FILE_STORAGE_PATH" yaml:"file_storage_path"`
+	Restore         bool           `env:"RESTORE" yaml:"restore"`
+	DatabaseDSN     string         `env:"DATABASE_DSN" yaml:"database_dsn"`
+	SecretKey       string         `env:"KEY"`
+	PrivateKeyPath  string         `env:"CRYPTO_KEY"`
+	TrustedSubnet   string         `env:"TRUSTED_SUBNET" yaml:"trusted_subnet"`
+	Retry           RetryConfig    `envPrefix:"RETRY_" yaml:"retry"`
+	Alerting        AlertingConfig `envPrefix:"ALERT_" yaml:"alerting"`
+	StatsD          StatsDConfig   `envPrefix:"STATSD_" yaml:"statsd"`
+	Graphite        GraphiteConfig `envPrefix:"GRAPHITE_" yaml:"graphite"`
+	ShutdownTimeout time.Duration  `env:"SHUTDOWN_TIMEOUT" yaml:"shutdown_timeout"`
 }
 
 type EnvAppConfig struct {
@@ -48,13 +57,41 @@ func LoadAppConfig() (*AppConfig, error) {
 }
 
 func getConfigPath() string {
-	// Читаем путь к файлу конфигурации через флаг -c или переменную окружения CONFIG
-	var configPath string
-	flag.StringVar(&configPath, "config", os.Getenv("CONFIG"), "path to config file (JSON)")
-	flag.Parse()
+	// Читаем путь к файлу конфигурации через флаг -c/-config или переменную окружения CONFIG.
+	// Остальные флаги объявляются позже, поэтому flag.Parse здесь вызывать нельзя:
+	// он завершит программу на первом еще не объявленном флаге.
+	return parseConfigPath(os.Args[1:], os.Getenv("CONFIG"))
+}
+
+func parseConfigPath(args []string, configPath string) string {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if name != "c" && name != "config" {
+			continue
+		}
+		if hasValue {
+			configPath = value
+		} else if i+1 < len(args) {
+			configPath = args[i+1]
+		}
+	}
 	return configPath
 }
 
+// declareConfigFlags объявляет флаги пути к файлу конфигурации, чтобы flag.Parse их принимал.
+// Значение флагов читается раньше в getConfigPath.
+func declareConfigFlags() {
+	var configPath string
+	flag.StringVar(&configPath, "c", "", "path to config file (JSON)")
+	flag.StringVar(&configPath, "config", "", "path to config file (JSON)")
+}
+
 func (c *AppConfig) ReadYAML(filename string) error {
 	file, err := os.OpenFile(filename, os.O_RDONLY, 0666)
 	if err != nil {
@@ -83,14 +120,21 @@ func (c *AppConfig) ReadJSON(filename string) error {
 
 func (c *AppConfig) ReadServerFlags() {
 	storeInterval := int(c.StoreInterval) / int(time.Second)
+	declareConfigFlags()
 	flag.StringVar(&c.Addr, "a", c.Addr, "server address")
+	flag.StringVar(&c.GRPCAddr, "grpc-addr", c.GRPCAddr, "server gRPC address")
 	flag.StringVar(&c.LogLevel, "ll", c.LogLevel, "server log level")
-	flag.IntVar(&storeInterval, "i", storeInterval, "server store interval")
+	flag.StringVar(&c.LogFormat, "lf", c.LogFormat, "server log format (json or text)")
+	flag.IntVar(&storeInterval, "i", storeInterval, "server store interval in seconds, 0 - sync every update to disk")
 	flag.StringVar(&c.FileStoragePath, "f", c.FileStoragePath, "server file restore path")
 	flag.BoolVar(&c.Restore, "r", c.Restore, "server restore")
 	flag.StringVar(&c.DatabaseDSN, "d", c.DatabaseDSN, "db address")
 	flag.StringVar(&c.SecretKey, "k", c.SecretKey, "secret key")
-	flag.StringVar(&c.PrivateKeyPath, "crypto-key", c.PrivateKeyPath, "crypto key")
+	flag.StringVar(&c.TrustedSubnet, "t", c.TrustedSubnet, "trusted subnet in CIDR notation")
+	flag.StringVar(&c.PrivateKeyPath, "crypto-key", c.PrivateKeyPath, "path to RSA private key for payload decryption")
+	flag.StringVar(&c.Alerting.RulesPath, "alert-rules", c.Alerting.RulesPath, "path to alerting rules file (YAML)")
+	flag.StringVar(&c.StatsD.Addr, "statsd-addr", c.StatsD.Addr, "StatsD UDP listener address, disabled if empty")
+	flag.StringVar(&c.Graphite.Addr, "graphite-addr", c.Graphite.Addr, "Graphite plaintext TCP listener address, disabled if empty")
 	flag.Parse()
 	c.StoreInterval = time.Duration(storeInterval) * time.Second
 
@@ -109,11 +153,20 @@ func (c *AppConfig) ReadEnvConfig() error {
 		return err
 	}
 	c.Addr = envConfig.Addr
+	c.GRPCAddr = envConfig.GRPCAddr
 	c.LogLevel = envConfig.LogLevel
+	c.LogFormat = envConfig.LogFormat
 	c.FileStoragePath = envConfig.FileStoragePath
 	c.Restore = envConfig.Restore
 	c.StoreInterval = time.Duration(envConfig.StoreInterval) * time.Second
 	c.DatabaseDSN = envConfig.DatabaseDSN
 	c.SecretKey = envConfig.SecretKey
+	c.PrivateKeyPath = envConfig.PrivateKeyPath
+	c.TrustedSubnet = envConfig.TrustedSubnet
+	c.Retry = envConfig.Retry
+	c.Alerting = envConfig.Alerting
+	c.StatsD = envConfig.StatsD
+	c.Graphite = envConfig.Graphite
+	c.ShutdownTimeout = envConfig.ShutdownTimeout
 	return nil
 }
@@ -48,3 +48,22 @@ func TestAppReadEnvConfig(t *testing.T) {
 		assert.NoError(t, err)
 	})
 }
+
+func TestParseConfigPath(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  string
+		want string
+	}{
+		{name: "no flag", args: []string{"-a", "localhost:8080"}, env: "env.json", want: "env.json"},
+		{name: "separate value", args: []string{"-a", "localhost:8080", "-c", "config.json"}, want: "config.json"},
+		{name: "inline value", args: []string{"--config=config.json", "-t", "10.0.0.0/8"}, want: "config.json"},
+		{name: "after terminator", args: []string{"--", "-c", "config.json"}, want: ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, parseConfigPath(test.args, test.env))
+		})
+	}
+}
@@ -0,0 +1,8 @@
+package config
+
+import "time"
+
+type GraphiteConfig struct {
+	Addr        string        `env:"ADDRESS" yaml:"addr"`
+	IdleTimeout time.Duration `env:"IDLE_TIMEOUT" yaml:"idle_timeout"`
+}
@@ -0,0 +1,23 @@
+package config
+
+import (
+	"time"
+
+	"github.com/eac0de/getmetrics/pkg/retry"
+)
+
+type RetryConfig struct {
+	Attempts  int           `env:"ATTEMPTS" yaml:"attempts"`
+	BaseDelay time.Duration `env:"BASE_DELAY" yaml:"base_delay"`
+	MaxDelay  time.Duration `env:"MAX_DELAY" yaml:"max_delay"`
+	Jitter    float64       `env:"JITTER" yaml:"jitter"`
+}
+
+func (c RetryConfig) Policy() retry.Policy {
+	return retry.Policy{
+		Attempts:  c.Attempts,
+		BaseDelay: c.BaseDelay,
+		MaxDelay:  c.MaxDelay,
+		Jitter:    c.Jitter,
+	}
+}
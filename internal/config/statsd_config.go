@@ -0,0 +1,9 @@
+package config
+
+import "time"
+
+type StatsDConfig struct {
+	Addr             string        `env:"ADDRESS" yaml:"addr"`
+	FlushInterval    time.Duration `env:"FLUSH_INTERVAL" yaml:"flush_interval"`
+	HistogramBuckets []float64     `env:"HISTOGRAM_BUCKETS" envSeparator:"," yaml:"histogram_buckets"`
+}
@@ -0,0 +1,239 @@
+// Package graphite реализует прием метрик в текстовом формате Graphite (plaintext protocol) через TCP.
+//
+// Каждая строка имеет вид path value [timestamp]. Путь становится идентификатором метрики,
+// а значение сохраняется как значение gauge.
+package graphite
+
+import (
+	"bufio"
+	"context"
+	stderr "errors"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eac0de/getmetrics/internal/api/handlers"
+	"github.com/eac0de/getmetrics/internal/models"
+)
+
+const (
+	// maxLineSize - максимальная длина строки, более длинные строки пропускаются.
+	maxLineSize = 64 * 1024
+	// maxBatchSize - количество метрик, после которого значения записываются в хранилище,
+	// даже если в соединении есть еще непрочитанные строки.
+	maxBatchSize = 1000
+	// saveTimeout - время на запись последних значений соединения при остановке.
+	saveTimeout = 5 * time.Second
+	// acceptRetryDelay - пауза после ошибки приема соединения.
+	acceptRetryDelay = 100 * time.Millisecond
+)
+
+var errLineTooLong = stderr.New("line is too long")
+
+// Listener принимает TCP-соединения и записывает полученные значения в хранилище.
+//
+// Значения соединения записываются, когда прочитаны все полученные на данный момент строки,
+// поэтому соединение может передавать много строк и оставаться открытым между отправками.
+// Из нескольких значений одной метрики в записи сохраняется значение с наибольшей временной меткой.
+type Listener struct {
+	Addr string
+	// TrustedSubnet - подсеть, из которой принимаются соединения. nil - соединения принимаются с любого адреса.
+	TrustedSubnet *net.IPNet
+	// IdleTimeout - время, после которого неактивное соединение закрывается. 0 - без ограничения.
+	IdleTimeout  time.Duration
+	metricsStore handlers.IMetricsStore
+	logger       *slog.Logger
+	listener     net.Listener
+	wg           sync.WaitGroup
+
+	mu sync.Mutex
+	// conns - открытые соединения, nil после остановки.
+	conns map[net.Conn]struct{}
+}
+
+// New создает Listener.
+func New(addr string, metricsStore handlers.IMetricsStore, idleTimeout time.Duration, logger *slog.Logger) *Listener {
+	return &Listener{
+		Addr:         addr,
+		IdleTimeout:  idleTimeout,
+		metricsStore: metricsStore,
+		logger:       logger,
+		conns:        make(map[net.Conn]struct{}),
+	}
+}
+
+// Listen открывает TCP-порт.
+func (l *Listener) Listen() error {
+	listener, err := net.Listen("tcp", l.Addr)
+	if err != nil {
+		return err
+	}
+	l.listener = listener
+	l.Addr = listener.Addr().String()
+	return nil
+}
+
+// Start принимает соединения до отмены ctx. При остановке порт и открытые соединения
+// закрываются, а прочитанные значения записываются.
+func (l *Listener) Start(ctx context.Context) {
+	go func() {
+		<-ctx.Done()
+		l.listener.Close()
+		l.closeConns()
+	}()
+	for {
+		conn, err := l.listener.Accept()
+		if err != nil {
+			if stderr.Is(err, net.ErrClosed) {
+				break
+			}
+			l.logger.Error("Graphite accept error", "error", err)
+			time.Sleep(acceptRetryDelay)
+			continue
+		}
+		if !l.trusted(conn.RemoteAddr()) {
+			l.logger.Warn("Graphite connection from untrusted address", "peer", conn.RemoteAddr().String())
+			conn.Close()
+			continue
+		}
+		if !l.track(conn) {
+			conn.Close()
+			continue
+		}
+		l.wg.Add(1)
+		go l.handleConn(ctx, conn)
+	}
+	l.wg.Wait()
+	l.logger.Info("Graphite listener is shutting down")
+}
+
+// handleConn читает строки соединения до его закрытия.
+func (l *Listener) handleConn(ctx context.Context, conn net.Conn) {
+	defer l.wg.Done()
+	defer l.untrack(conn)
+	peer := conn.RemoteAddr().String()
+	reader := bufio.NewReaderSize(conn, maxLineSize)
+	pending := make(map[string]sample)
+	defer func() {
+		saveCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), saveTimeout)
+		defer cancel()
+		l.save(saveCtx, peer, pending)
+	}()
+	for {
+		if l.IdleTimeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(l.IdleTimeout))
+		}
+		line, err := readLine(reader)
+		if stderr.Is(err, errLineTooLong) {
+			l.logger.Warn("Invalid Graphite line", "peer", peer, "error", err)
+			continue
+		}
+		if len(line) > 0 {
+			l.add(pending, peer, string(line))
+		}
+		if reader.Buffered() == 0 || len(pending) >= maxBatchSize {
+			l.save(ctx, peer, pending)
+		}
+		if err != nil {
+			if !stderr.Is(err, io.EOF) && !stderr.Is(err, net.ErrClosed) && !stderr.Is(err, os.ErrDeadlineExceeded) {
+				l.logger.Warn("Graphite read error", "peer", peer, "error", err)
+			}
+			return
+		}
+	}
+}
+
+// add разбирает строку и добавляет значение к еще не записанным значениям соединения.
+func (l *Listener) add(pending map[string]sample, peer, line string) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+	s, err := parseLine(line, time.Now().Unix())
+	if err == nil {
+		err = handlers.ValidateMetricID(s.path)
+	}
+	if err != nil {
+		l.logger.Warn("Invalid Graphite line", "peer", peer, "line", line, "error", err)
+		return
+	}
+	if prev, ok := pending[s.path]; ok && prev.timestamp > s.timestamp {
+		return
+	}
+	pending[s.path] = s
+}
+
+// save записывает значения соединения в хранилище и очищает pending.
+func (l *Listener) save(ctx context.Context, peer string, pending map[string]sample) {
+	if len(pending) == 0 {
+		return
+	}
+	metricsList := make([]models.Metric, 0, len(pending))
+	for path, s := range pending {
+		value := s.value
+		metricsList = append(metricsList, models.Metric{ID: path, MType: models.Gauge, Value: &value})
+	}
+	clear(pending)
+	err := l.metricsStore.SaveMetrics(ctx, metricsList)
+	if err != nil {
+		l.logger.ErrorContext(ctx, "Graphite metrics saving error", "peer", peer, "count", len(metricsList), "error", err)
+	}
+}
+
+// trusted проверяет, что адрес клиента входит в доверенную подсеть, если она задана.
+func (l *Listener) trusted(peer net.Addr) bool {
+	if l.TrustedSubnet == nil {
+		return true
+	}
+	addr, ok := peer.(*net.TCPAddr)
+	return ok && l.TrustedSubnet.Contains(addr.IP)
+}
+
+// track регистрирует соединение. Возвращает false, если Listener уже остановлен.
+func (l *Listener) track(conn net.Conn) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conns == nil {
+		return false
+	}
+	l.conns[conn] = struct{}{}
+	return true
+}
+
+// untrack закрывает соединение и удаляет его из открытых.
+func (l *Listener) untrack(conn net.Conn) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	conn.Close()
+	delete(l.conns, conn)
+}
+
+// closeConns закрывает открытые соединения, новые соединения больше не принимаются.
+func (l *Listener) closeConns() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for conn := range l.conns {
+		conn.Close()
+	}
+	l.conns = nil
+}
+
+// readLine читает строку вместе с переводом строки. Для строк длиннее буфера возвращает
+// errLineTooLong, пропустив строку целиком.
+func readLine(reader *bufio.Reader) ([]byte, error) {
+	line, err := reader.ReadSlice('\n')
+	if !stderr.Is(err, bufio.ErrBufferFull) {
+		return line, err
+	}
+	for stderr.Is(err, bufio.ErrBufferFull) {
+		_, err = reader.ReadSlice('\n')
+	}
+	if err == nil {
+		err = errLineTooLong
+	}
+	return nil, err
+}
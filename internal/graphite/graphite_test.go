@@ -0,0 +1,117 @@
+package graphite
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eac0de/getmetrics/internal/models"
+	"github.com/eac0de/getmetrics/internal/storage/memstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func startListener(t *testing.T, store *memstore.MemoryStore, trustedSubnet *net.IPNet) (*Listener, func()) {
+	listener := New("127.0.0.1:0", store, time.Minute, slog.Default())
+	listener.TrustedSubnet = trustedSubnet
+	require.NoError(t, listener.Listen())
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		listener.Start(ctx)
+	}()
+	return listener, func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+func gaugeValue(store *memstore.MemoryStore, id string) (float64, bool) {
+	metric, err := store.GetMetric(context.Background(), id, models.Gauge, nil)
+	if err != nil {
+		return 0, false
+	}
+	return *metric.Value, true
+}
+
+func TestListener(t *testing.T) {
+	store := memstore.New()
+	listener, stop := startListener(t, store, nil)
+	defer stop()
+
+	conn, err := net.Dial("tcp", listener.Addr)
+	require.NoError(t, err)
+	var lines strings.Builder
+	for i := 0; i < 3*maxBatchSize; i++ {
+		fmt.Fprintf(&lines, "hosts.h%d.load %d 1600000000\n", i, i)
+	}
+	lines.WriteString("bad line\n\nservers{a}.cpu 1 1600000000\n")
+	lines.WriteString("jobs.backup.duration 30 1600000100\njobs.backup.duration 20 1600000000\n")
+	_, err = conn.Write([]byte(lines.String()))
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+
+	assert.Eventually(t, func() bool {
+		value, ok := gaugeValue(store, "jobs.backup.duration")
+		return ok && value == 30
+	}, time.Second, 10*time.Millisecond)
+	value, ok := gaugeValue(store, fmt.Sprintf("hosts.h%d.load", 3*maxBatchSize-1))
+	assert.True(t, ok)
+	assert.Equal(t, float64(3*maxBatchSize-1), value)
+	_, ok = gaugeValue(store, "servers{a}.cpu")
+	assert.False(t, ok)
+}
+
+func TestListenerOpenConnection(t *testing.T) {
+	store := memstore.New()
+	listener, stop := startListener(t, store, nil)
+
+	conn, err := net.Dial("tcp", listener.Addr)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// Значения записываются, не дожидаясь закрытия соединения
+	_, err = conn.Write([]byte("queue.size 5 -1\n"))
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		value, ok := gaugeValue(store, "queue.size")
+		return ok && value == 5
+	}, time.Second, 10*time.Millisecond)
+
+	// Строка без перевода строки записывается при остановке
+	_, err = conn.Write([]byte("queue.size 7"))
+	require.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+	stop()
+	value, _ := gaugeValue(store, "queue.size")
+	assert.Equal(t, 7.0, value)
+}
+
+func TestListenerTrustedSubnet(t *testing.T) {
+	store := memstore.New()
+	_, trustedSubnet, err := net.ParseCIDR("192.168.1.0/24")
+	require.NoError(t, err)
+	listener, stop := startListener(t, store, trustedSubnet)
+	defer stop()
+
+	conn, err := net.Dial("tcp", listener.Addr)
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.Write([]byte("queue.size 5 -1\n"))
+
+	// Соединение с адреса вне подсети закрывается сервером без чтения строк
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = conn.Read(make([]byte, 1))
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, os.ErrDeadlineExceeded)
+	_, ok := gaugeValue(store, "queue.size")
+	assert.False(t, ok)
+}
@@ -0,0 +1,39 @@
+package graphite
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// sample - одно значение из строки Graphite.
+type sample struct {
+	path  string
+	value float64
+	// timestamp - время значения в секундах Unix.
+	timestamp int64
+}
+
+// parseLine разбирает строку формата path value [timestamp]. Если временная метка
+// не указана или равна -1, используется now.
+func parseLine(line string, now int64) (sample, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 || len(fields) > 3 {
+		return sample{}, fmt.Errorf("expected path value timestamp separated by spaces")
+	}
+	s := sample{path: fields[0], timestamp: now}
+	value, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return sample{}, fmt.Errorf("invalid value %q", fields[1])
+	}
+	s.value = value
+	if len(fields) == 3 && fields[2] != "-1" {
+		timestamp, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil || !(timestamp >= 0 && timestamp < math.MaxInt64) {
+			return sample{}, fmt.Errorf("invalid timestamp %q", fields[2])
+		}
+		s.timestamp = int64(timestamp)
+	}
+	return s, nil
+}
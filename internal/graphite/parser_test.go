@@ -0,0 +1,37 @@
+package graphite
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLine(t *testing.T) {
+	const now = 1700000000
+	tests := []struct {
+		line string
+		want sample
+	}{
+		{line: "servers.web1.cpu 42.5 1600000000", want: sample{path: "servers.web1.cpu", value: 42.5, timestamp: 1600000000}},
+		{line: "servers.web1.cpu\t-1\t1600000000.5", want: sample{path: "servers.web1.cpu", value: -1, timestamp: 1600000000}},
+		{line: "backup.duration 120 -1", want: sample{path: "backup.duration", value: 120, timestamp: now}},
+		{line: "backup.duration 120", want: sample{path: "backup.duration", value: 120, timestamp: now}},
+	}
+	for _, test := range tests {
+		got, err := parseLine(test.line, now)
+		assert.NoError(t, err, test.line)
+		assert.Equal(t, test.want, got, test.line)
+	}
+
+	for _, line := range []string{
+		"servers.web1.cpu",
+		"servers.web1.cpu 1 2 3",
+		"servers.web1.cpu x 1600000000",
+		"servers.web1.cpu nan 1600000000",
+		"servers.web1.cpu 1 yesterday",
+		"servers.web1.cpu 1 -5",
+	} {
+		_, err := parseLine(line, now)
+		assert.Error(t, err, line)
+	}
+}
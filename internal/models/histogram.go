@@ -0,0 +1,148 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+)
+
+// HistogramValue - значение метрики типа "гистограмма".
+//
+// Bounds задает верхние границы корзин по возрастанию, последняя корзина (+Inf) подразумевается.
+// Counts содержит количество наблюдений в каждой корзине (не накопленное), его длина на единицу
+// больше длины Bounds. Sum и Count - сумма и количество всех наблюдений.
+type HistogramValue struct {
+	Bounds []float64 `json:"bounds"`
+	Counts []uint64  `json:"counts"`
+	Sum    float64   `json:"sum"`
+	Count  uint64    `json:"count"`
+}
+
+// Validate проверяет согласованность границ и счетчиков корзин.
+func (h *HistogramValue) Validate() error {
+	if len(h.Counts) != len(h.Bounds)+1 {
+		return fmt.Errorf("histogram must have %d counts for %d bounds, got %d", len(h.Bounds)+1, len(h.Bounds), len(h.Counts))
+	}
+	for i, bound := range h.Bounds {
+		if math.IsNaN(bound) || math.IsInf(bound, 0) {
+			return fmt.Errorf("histogram bound must be finite")
+		}
+		if i > 0 && bound <= h.Bounds[i-1] {
+			return fmt.Errorf("histogram bounds must be strictly increasing")
+		}
+	}
+	var count uint64
+	for _, c := range h.Counts {
+		count += c
+	}
+	if count != h.Count {
+		return fmt.Errorf("histogram count %d does not match bucket counts %d", h.Count, count)
+	}
+	return nil
+}
+
+// SameBounds сообщает, совпадают ли границы корзин двух гистограмм.
+func (h *HistogramValue) SameBounds(other *HistogramValue) bool {
+	return slices.Equal(h.Bounds, other.Bounds)
+}
+
+// Merge возвращает гистограмму, в которой счетчики корзин, сумма и количество сложены.
+// Границы корзин должны совпадать.
+func (h *HistogramValue) Merge(other *HistogramValue) (*HistogramValue, error) {
+	if !h.SameBounds(other) {
+		return nil, fmt.Errorf("histogram bounds do not match")
+	}
+	merged := &HistogramValue{
+		Bounds: slices.Clone(h.Bounds),
+		Counts: make([]uint64, len(h.Counts)),
+		Sum:    h.Sum + other.Sum,
+		Count:  h.Count + other.Count,
+	}
+	for i := range merged.Counts {
+		merged.Counts[i] = h.Counts[i] + other.Counts[i]
+	}
+	return merged, nil
+}
+
+// Accumulate возвращает сохраненную гистограмму h, к которой прибавлена other.
+// Если сохраненной гистограммы нет или границы корзин изменились, возвращается other.
+func (h *HistogramValue) Accumulate(other *HistogramValue) *HistogramValue {
+	if h == nil || !h.SameBounds(other) {
+		return other
+	}
+	merged, _ := h.Merge(other)
+	return merged
+}
+
+// Quantile оценивает квантиль q (от 0 до 1) линейной интерполяцией внутри корзины,
+// как это делает histogram_quantile в Prometheus. Нижней границей первой корзины считается 0,
+// если ее верхняя граница положительна. Если квантиль попадает в корзину +Inf, возвращается
+// наибольшая конечная граница. Для пустой гистограммы и гистограммы без границ возвращает NaN.
+func (h *HistogramValue) Quantile(q float64) float64 {
+	if h.Count == 0 || len(h.Bounds) == 0 || math.IsNaN(q) {
+		return math.NaN()
+	}
+	if q < 0 {
+		return math.Inf(-1)
+	}
+	if q > 1 {
+		return math.Inf(1)
+	}
+	rank := q * float64(h.Count)
+	var cumulative uint64
+	for i, count := range h.Counts {
+		prev := cumulative
+		cumulative += count
+		if float64(cumulative) < rank || count == 0 {
+			continue
+		}
+		if i == len(h.Bounds) {
+			return h.Bounds[len(h.Bounds)-1]
+		}
+		upper := h.Bounds[i]
+		var lower float64
+		if i > 0 {
+			lower = h.Bounds[i-1]
+		} else if upper <= 0 {
+			return upper
+		}
+		return lower + (upper-lower)*(rank-float64(prev))/float64(count)
+	}
+	return h.Bounds[len(h.Bounds)-1]
+}
+
+// String возвращает краткое описание гистограммы для отображения.
+func (h *HistogramValue) String() string {
+	format := func(v float64) string {
+		return strconv.FormatFloat(v, 'g', 4, 64)
+	}
+	return fmt.Sprintf("count=%d sum=%s p50=%s p90=%s p99=%s",
+		h.Count, format(h.Sum), format(h.Quantile(0.5)), format(h.Quantile(0.9)), format(h.Quantile(0.99)))
+}
+
+// Value реализует driver.Valuer. Гистограмма хранится в базе данных как JSON-объект.
+func (h *HistogramValue) Value() (driver.Value, error) {
+	if h == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(h)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan реализует sql.Scanner.
+func (h *HistogramValue) Scan(src any) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, h)
+	case string:
+		return json.Unmarshal([]byte(v), h)
+	default:
+		return fmt.Errorf("unsupported histogram type: %T", src)
+	}
+}
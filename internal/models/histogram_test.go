@@ -0,0 +1,66 @@
+package models
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistogramValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		histogram HistogramValue
+		wantErr   bool
+	}{
+		{name: "valid", histogram: HistogramValue{Bounds: []float64{1, 2}, Counts: []uint64{1, 2, 3}, Count: 6}},
+		{name: "counts length", histogram: HistogramValue{Bounds: []float64{1, 2}, Counts: []uint64{1, 2}, Count: 3}, wantErr: true},
+		{name: "unsorted bounds", histogram: HistogramValue{Bounds: []float64{2, 1}, Counts: []uint64{0, 0, 0}}, wantErr: true},
+		{name: "infinite bound", histogram: HistogramValue{Bounds: []float64{math.Inf(1)}, Counts: []uint64{0, 0}}, wantErr: true},
+		{name: "count mismatch", histogram: HistogramValue{Bounds: []float64{1}, Counts: []uint64{1, 1}, Count: 3}, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.histogram.Validate()
+			if test.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestHistogramMerge(t *testing.T) {
+	a := &HistogramValue{Bounds: []float64{1, 2}, Counts: []uint64{1, 2, 3}, Sum: 10, Count: 6}
+	b := &HistogramValue{Bounds: []float64{1, 2}, Counts: []uint64{4, 0, 1}, Sum: 5, Count: 5}
+	merged, err := a.Merge(b)
+	assert.NoError(t, err)
+	assert.Equal(t, &HistogramValue{Bounds: []float64{1, 2}, Counts: []uint64{5, 2, 4}, Sum: 15, Count: 11}, merged)
+	assert.Equal(t, []uint64{1, 2, 3}, a.Counts)
+
+	_, err = a.Merge(&HistogramValue{Bounds: []float64{1}, Counts: []uint64{0, 0}})
+	assert.Error(t, err)
+}
+
+func TestHistogramQuantile(t *testing.T) {
+	h := &HistogramValue{Bounds: []float64{1, 2, 4}, Counts: []uint64{2, 4, 2, 2}, Count: 10}
+	assert.InDelta(t, 0.5, h.Quantile(0.1), 1e-9)
+	assert.InDelta(t, 1.75, h.Quantile(0.5), 1e-9)
+	assert.InDelta(t, 3, h.Quantile(0.7), 1e-9)
+	assert.Equal(t, float64(4), h.Quantile(0.99))
+	assert.True(t, math.IsNaN((&HistogramValue{Bounds: []float64{1}, Counts: []uint64{0, 0}}).Quantile(0.5)))
+}
+
+func TestHistogramValueScan(t *testing.T) {
+	h := &HistogramValue{Bounds: []float64{1}, Counts: []uint64{1, 2}, Sum: 3.5, Count: 3}
+	value, err := h.Value()
+	assert.NoError(t, err)
+	var scanned HistogramValue
+	assert.NoError(t, scanned.Scan(value))
+	assert.Equal(t, *h, scanned)
+
+	value, err = (*HistogramValue)(nil).Value()
+	assert.NoError(t, err)
+	assert.Nil(t, value)
+}
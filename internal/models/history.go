@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// Sample представляет одно сохраненное значение метрики в момент времени.
+type Sample struct {
+	// Timestamp - время, когда значение было принято сервером.
+	Timestamp time.Time `json:"timestamp" db:"ts"`
+	// Value - значение метрики. Для счетчика - накопленное значение после обновления.
+	Value float64 `json:"value" db:"value"`
+}
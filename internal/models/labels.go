@@ -0,0 +1,146 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// labelNameRegexp - допустимый формат имени метки.
+var labelNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Labels - набор меток метрики. Метки входят в идентичность метрики вместе с именем и типом.
+type Labels map[string]string
+
+// Validate проверяет имена меток.
+func (l Labels) Validate() error {
+	for name := range l {
+		if !labelNameRegexp.MatchString(name) {
+			return fmt.Errorf("invalid label name: %q", name)
+		}
+	}
+	return nil
+}
+
+// Matches сообщает, содержит ли набор все метки фильтра с теми же значениями.
+func (l Labels) Matches(filter Labels) bool {
+	for name, value := range filter {
+		if v, ok := l[name]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+// String возвращает метки в каноническом виде {name="value",...}, отсортированными по имени.
+// Для пустого набора возвращает пустую строку.
+func (l Labels) String() string {
+	if len(l) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(l))
+	for name := range l {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(l[name]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// Value реализует driver.Valuer. Метки хранятся в базе данных как JSON-объект.
+func (l Labels) Value() (driver.Value, error) {
+	if l == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(map[string]string(l))
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan реализует sql.Scanner.
+func (l *Labels) Scan(src any) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("unsupported labels type: %T", src)
+	}
+	var labels map[string]string
+	if err := json.Unmarshal(data, &labels); err != nil {
+		return err
+	}
+	if len(labels) == 0 {
+		*l = nil
+		return nil
+	}
+	*l = labels
+	return nil
+}
+
+// SeriesKey возвращает ключ ряда метрики: имя метрики и метки в каноническом виде.
+// Для метрики без меток ключ совпадает с ее именем.
+func SeriesKey(id string, labels Labels) string {
+	return id + labels.String()
+}
+
+// ParseSeriesKey разбирает ключ ряда, построенный SeriesKey.
+// Если ключ не содержит корректного набора меток, он целиком считается именем метрики.
+func ParseSeriesKey(key string) (string, Labels) {
+	start := strings.IndexByte(key, '{')
+	if start < 0 || !strings.HasSuffix(key, "}") {
+		return key, nil
+	}
+	labels, err := parseLabels(key[start+1 : len(key)-1])
+	if err != nil {
+		return key, nil
+	}
+	return key[:start], labels
+}
+
+func parseLabels(s string) (Labels, error) {
+	labels := Labels{}
+	for s != "" {
+		name, rest, ok := strings.Cut(s, "=")
+		if !ok || !labelNameRegexp.MatchString(name) {
+			return nil, fmt.Errorf("invalid labels")
+		}
+		value, err := strconv.QuotedPrefix(rest)
+		if err != nil {
+			return nil, err
+		}
+		labels[name], err = strconv.Unquote(value)
+		if err != nil {
+			return nil, err
+		}
+		s = strings.TrimPrefix(rest[len(value):], ",")
+		if len(rest) > len(value) && rest[len(value)] != ',' {
+			return nil, fmt.Errorf("invalid labels")
+		}
+	}
+	if len(labels) == 0 {
+		return nil, fmt.Errorf("empty labels")
+	}
+	return labels, nil
+}
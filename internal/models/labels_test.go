@@ -0,0 +1,73 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeriesKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		id     string
+		labels Labels
+		key    string
+	}{
+		{name: "no labels", id: "HeapAlloc", key: "HeapAlloc"},
+		{
+			name:   "sorted labels",
+			id:     "HeapAlloc",
+			labels: Labels{"instance": "10.0.0.1", "host": "host1"},
+			key:    `HeapAlloc{host="host1",instance="10.0.0.1"}`,
+		},
+		{
+			name:   "escaped value",
+			id:     "HeapAlloc",
+			labels: Labels{"host": `a"b,c=d}`},
+			key:    `HeapAlloc{host="a\"b,c=d}"}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			key := SeriesKey(test.id, test.labels)
+			assert.Equal(t, test.key, key)
+			id, labels := ParseSeriesKey(key)
+			assert.Equal(t, test.id, id)
+			assert.Equal(t, test.labels, labels)
+		})
+	}
+	t.Run("name with braces", func(t *testing.T) {
+		id, labels := ParseSeriesKey("weird{name}")
+		assert.Equal(t, "weird{name}", id)
+		assert.Nil(t, labels)
+	})
+}
+
+func TestLabelsValueScan(t *testing.T) {
+	labels := Labels{"host": "host1"}
+	value, err := labels.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, `{"host":"host1"}`, value)
+
+	var scanned Labels
+	assert.NoError(t, scanned.Scan([]byte(`{"host":"host1"}`)))
+	assert.Equal(t, labels, scanned)
+	assert.NoError(t, scanned.Scan("{}"))
+	assert.Nil(t, scanned)
+
+	value, err = Labels(nil).Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "{}", value)
+}
+
+func TestLabelsMatchesAndValidate(t *testing.T) {
+	labels := Labels{"host": "host1", "instance": "10.0.0.1"}
+	assert.True(t, labels.Matches(nil))
+	assert.True(t, labels.Matches(Labels{"host": "host1"}))
+	assert.False(t, labels.Matches(Labels{"host": "host2"}))
+	assert.False(t, Labels(nil).Matches(Labels{"host": "host1"}))
+
+	assert.NoError(t, labels.Validate())
+	assert.Error(t, Labels{"1host": "x"}.Validate())
+	assert.Error(t, Labels{"host-name": "x"}.Validate())
+}
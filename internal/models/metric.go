@@ -5,24 +5,38 @@ const (
 	Gauge = "gauge"
 	// Counter обозначает тип метрики для значения типа "счетчик".
 	Counter = "counter"
+	// Histogram обозначает тип метрики для значения типа "гистограмма".
+	Histogram = "histogram"
 )
 
 // Metric представляет метрику с ее параметрами.
 type Metric struct {
 	// ID - имя метрики.
 	ID string `json:"id" db:"id"`
-	// MType - тип метрики, который может быть "gauge" или "counter".
+	// MType - тип метрики, который может быть "gauge", "counter" или "histogram".
 	MType string `json:"type" db:"type"`
 	// Delta - значение метрики в случае передачи счетчика (counter).
 	Delta *int64 `json:"delta,omitempty" db:"delta"`
 	// Value - значение метрики в случае передачи гейджа (gauge).
 	Value *float64 `json:"value,omitempty" db:"value"`
+	// Histogram - значение метрики в случае передачи гистограммы (histogram).
+	Histogram *HistogramValue `json:"histogram,omitempty" db:"histogram"`
+	// Labels - необязательные метки метрики, например host и instance.
+	Labels Labels `json:"labels,omitempty" db:"labels"`
+}
+
+// SeriesKey возвращает ключ ряда метрики с учетом меток.
+func (m Metric) SeriesKey() string {
+	return SeriesKey(m.ID, m.Labels)
 }
 
 // MetricsData хранит данные о метриках.
+// Ключами карт являются ключи рядов метрик (см. SeriesKey).
 type MetricsData struct {
 	// Counter - карта для хранения счетчиков.
 	Counter map[string]int64 `json:"counter"`
 	// Gauge - карта для хранения гейджа.
 	Gauge map[string]float64 `json:"gauge"`
+	// Histogram - карта для хранения гистограмм.
+	Histogram map[string]HistogramValue `json:"histogram,omitempty"`
 }
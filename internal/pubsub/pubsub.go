@@ -0,0 +1,160 @@
+// Package pubsub реализует публикацию обновлений метрик внутри процесса.
+//
+// Hub оборачивает хранилище метрик и после каждого успешного сохранения рассылает сохраненные
+// значения подписчикам, поэтому подписчики видят обновления, пришедшие любым путем: через HTTP,
+// gRPC, StatsD, Graphite и другие обработчики, использующие хранилище.
+package pubsub
+
+import (
+	"context"
+	stderr "errors"
+	"log/slog"
+	"path"
+	"sync"
+
+	"github.com/eac0de/getmetrics/internal/api/handlers"
+	"github.com/eac0de/getmetrics/internal/models"
+)
+
+// subscriberBufferSize - количество неотправленных обновлений, после которого подписчик отключается.
+const subscriberBufferSize = 256
+
+// ErrClosed возвращается при подписке на остановленный Hub.
+var ErrClosed = stderr.New("metrics hub is closed")
+
+// Hub - хранилище метрик, публикующее сохраненные значения подписчикам.
+//
+// Обновления отправляются подписчикам без ожидания. Подписчик, который не успевает читать
+// обновления и накопил subscriberBufferSize неотправленных, отключается: его канал закрывается.
+type Hub struct {
+	handlers.IMetricsStore
+	logger *slog.Logger
+
+	mu sync.Mutex
+	// subscribers - активные подписчики, nil после остановки.
+	subscribers map[*subscriber]struct{}
+}
+
+type subscriber struct {
+	pattern string
+	events  chan models.Metric
+}
+
+// NewHub создает Hub, сохраняющий метрики в metricsStore.
+func NewHub(metricsStore handlers.IMetricsStore, logger *slog.Logger) *Hub {
+	return &Hub{
+		IMetricsStore: metricsStore,
+		logger:        logger,
+		subscribers:   make(map[*subscriber]struct{}),
+	}
+}
+
+// Subscribe подписывается на сохранение метрик, идентификатор которых подходит под шаблон
+// в формате path.Match. Пустой шаблон подходит под любую метрику.
+//
+// Канал закрывается после вызова unsubscribe, остановки Hub или отключения медленного подписчика.
+func (h *Hub) Subscribe(pattern string) (<-chan models.Metric, func(), error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, nil, err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subscribers == nil {
+		return nil, nil, ErrClosed
+	}
+	s := &subscriber{pattern: pattern, events: make(chan models.Metric, subscriberBufferSize)}
+	h.subscribers[s] = struct{}{}
+	unsubscribe := func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.remove(s)
+	}
+	return s.events, unsubscribe, nil
+}
+
+// Close отключает всех подписчиков. Сохранение метрик продолжает работать.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subscribers {
+		close(s.events)
+	}
+	h.subscribers = nil
+}
+
+func (h *Hub) SaveMetric(ctx context.Context, metric models.Metric) error {
+	err := h.IMetricsStore.SaveMetric(ctx, metric)
+	if err != nil {
+		return err
+	}
+	h.publish(ctx, metric)
+	return nil
+}
+
+func (h *Hub) SaveMetrics(ctx context.Context, metricsList []models.Metric) error {
+	err := h.IMetricsStore.SaveMetrics(ctx, metricsList)
+	if err != nil {
+		return err
+	}
+	h.publish(ctx, metricsList...)
+	return nil
+}
+
+func (h *Hub) IncrementCounter(ctx context.Context, metric models.Metric) (*models.Metric, error) {
+	counter, err := h.IMetricsStore.IncrementCounter(ctx, metric)
+	if err != nil {
+		return nil, err
+	}
+	h.publish(ctx, *counter)
+	return counter, nil
+}
+
+func (h *Hub) IncrementCounters(ctx context.Context, metricsList []models.Metric) ([]models.Metric, error) {
+	counters, err := h.IMetricsStore.IncrementCounters(ctx, metricsList)
+	if err != nil {
+		return nil, err
+	}
+	h.publish(ctx, counters...)
+	return counters, nil
+}
+
+func (h *Hub) UpdateMetrics(ctx context.Context, metricsList []models.Metric) ([]models.Metric, error) {
+	metricsList, err := h.IMetricsStore.UpdateMetrics(ctx, metricsList)
+	if err != nil {
+		return nil, err
+	}
+	h.publish(ctx, metricsList...)
+	return metricsList, nil
+}
+
+// publish отправляет метрики подходящим подписчикам.
+func (h *Hub) publish(ctx context.Context, metricsList ...models.Metric) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subscribers {
+		for _, metric := range metricsList {
+			if s.pattern != "" {
+				if ok, _ := path.Match(s.pattern, metric.ID); !ok {
+					continue
+				}
+			}
+			select {
+			case s.events <- metric:
+				continue
+			default:
+			}
+			h.logger.WarnContext(ctx, "Slow metrics subscriber disconnected", "pattern", s.pattern)
+			h.remove(s)
+			break
+		}
+	}
+}
+
+// remove закрывает канал подписчика, если он еще подписан. Вызывается под h.mu.
+func (h *Hub) remove(s *subscriber) {
+	if _, ok := h.subscribers[s]; !ok {
+		return
+	}
+	delete(h.subscribers, s)
+	close(s.events)
+}
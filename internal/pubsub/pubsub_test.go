@@ -0,0 +1,82 @@
+package pubsub
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/eac0de/getmetrics/internal/models"
+	"github.com/eac0de/getmetrics/internal/storage/memstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHubPublish(t *testing.T) {
+	ctx := context.Background()
+	hub := NewHub(memstore.New(), slog.Default())
+	cpu, unsubscribeCPU, err := hub.Subscribe("cpu.*")
+	require.NoError(t, err)
+	defer unsubscribeCPU()
+	all, unsubscribeAll, err := hub.Subscribe("")
+	require.NoError(t, err)
+
+	value, delta := 0.5, int64(2)
+	require.NoError(t, hub.SaveMetrics(ctx, []models.Metric{
+		{ID: "cpu.user", MType: models.Gauge, Value: &value},
+		{ID: "memory", MType: models.Gauge, Value: &value},
+	}))
+	_, err = hub.IncrementCounters(ctx, []models.Metric{{ID: "cpu.ticks", MType: models.Counter, Delta: &delta}})
+	require.NoError(t, err)
+	_, err = hub.IncrementCounter(ctx, models.Metric{ID: "cpu.ticks", MType: models.Counter, Delta: &delta})
+	require.NoError(t, err)
+
+	assert.Equal(t, "cpu.user", (<-cpu).ID)
+	assert.Equal(t, int64(2), *(<-cpu).Delta)
+	// Подписчики получают накопленное значение счетчика, а не приращение
+	assert.Equal(t, int64(4), *(<-cpu).Delta)
+	assert.Len(t, cpu, 0)
+	assert.Len(t, all, 4)
+
+	unsubscribeAll()
+	unsubscribeAll()
+	require.NoError(t, hub.SaveMetric(ctx, models.Metric{ID: "cpu.user", MType: models.Gauge, Value: &value}))
+	assert.Len(t, all, 4)
+	assert.Len(t, cpu, 1)
+}
+
+func TestHubSlowSubscriber(t *testing.T) {
+	ctx := context.Background()
+	hub := NewHub(memstore.New(), slog.Default())
+	slow, unsubscribeSlow, err := hub.Subscribe("")
+	require.NoError(t, err)
+	defer unsubscribeSlow()
+
+	value := 1.0
+	for i := 0; i <= subscriberBufferSize; i++ {
+		require.NoError(t, hub.SaveMetric(ctx, models.Metric{ID: "load", MType: models.Gauge, Value: &value}))
+	}
+	// Подписчик, не успевающий читать, получает накопленные обновления и отключается
+	received := 0
+	for range slow {
+		received++
+	}
+	assert.Equal(t, subscriberBufferSize, received)
+}
+
+func TestHubClose(t *testing.T) {
+	hub := NewHub(memstore.New(), slog.Default())
+	_, _, err := hub.Subscribe("[")
+	assert.Error(t, err)
+
+	events, unsubscribe, err := hub.Subscribe("")
+	require.NoError(t, err)
+	hub.Close()
+	_, ok := <-events
+	assert.False(t, ok)
+	unsubscribe()
+
+	_, _, err = hub.Subscribe("")
+	assert.ErrorIs(t, err, ErrClosed)
+	value := 1.0
+	assert.NoError(t, hub.SaveMetric(context.Background(), models.Metric{ID: "load", MType: models.Gauge, Value: &value}))
+}
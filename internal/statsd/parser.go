@@ -0,0 +1,64 @@
+package statsd
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Типы метрик StatsD.
+const (
+	typeCounter = "c"
+	typeGauge   = "g"
+	typeTimer   = "ms"
+)
+
+// sample - одно значение из строки StatsD.
+type sample struct {
+	name  string
+	mType string
+	value float64
+	// relative - значение gauge указано со знаком и изменяет текущее значение, а не заменяет его.
+	relative bool
+	// rate - частота выборки от 0 (не включая) до 1.
+	rate float64
+}
+
+// parseLine разбирает строку формата name:value|type[|@rate].
+func parseLine(line string) (sample, error) {
+	name, rest, ok := strings.Cut(line, ":")
+	if !ok || name == "" {
+		return sample{}, fmt.Errorf("expected name:value|type")
+	}
+	sections := strings.Split(rest, "|")
+	if len(sections) < 2 || len(sections) > 3 {
+		return sample{}, fmt.Errorf("expected name:value|type[|@rate]")
+	}
+	s := sample{name: name, mType: sections[1], rate: 1}
+	rawValue := sections[0]
+	switch s.mType {
+	case typeCounter, typeTimer:
+	case typeGauge:
+		s.relative = strings.HasPrefix(rawValue, "+") || strings.HasPrefix(rawValue, "-")
+	default:
+		return sample{}, fmt.Errorf("unsupported metric type %q", s.mType)
+	}
+	value, err := strconv.ParseFloat(rawValue, 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return sample{}, fmt.Errorf("invalid value %q", rawValue)
+	}
+	s.value = value
+	if len(sections) == 3 {
+		rawRate, ok := strings.CutPrefix(sections[2], "@")
+		if !ok {
+			return sample{}, fmt.Errorf("invalid sample rate %q", sections[2])
+		}
+		rate, err := strconv.ParseFloat(rawRate, 64)
+		if err != nil || !(rate > 0 && rate <= 1) {
+			return sample{}, fmt.Errorf("invalid sample rate %q", sections[2])
+		}
+		s.rate = rate
+	}
+	return s, nil
+}
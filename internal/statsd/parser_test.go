@@ -0,0 +1,41 @@
+package statsd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want sample
+	}{
+		{line: "requests:1|c", want: sample{name: "requests", mType: typeCounter, value: 1, rate: 1}},
+		{line: "requests:2|c|@0.5", want: sample{name: "requests", mType: typeCounter, value: 2, rate: 0.5}},
+		{line: "queue.size:42|g", want: sample{name: "queue.size", mType: typeGauge, value: 42, rate: 1}},
+		{line: "queue.size:-3|g", want: sample{name: "queue.size", mType: typeGauge, value: -3, relative: true, rate: 1}},
+		{line: "queue.size:+3|g", want: sample{name: "queue.size", mType: typeGauge, value: 3, relative: true, rate: 1}},
+		{line: "latency:12.5|ms|@0.1", want: sample{name: "latency", mType: typeTimer, value: 12.5, rate: 0.1}},
+	}
+	for _, test := range tests {
+		got, err := parseLine(test.line)
+		assert.NoError(t, err, test.line)
+		assert.Equal(t, test.want, got, test.line)
+	}
+
+	for _, line := range []string{
+		"requests",
+		":1|c",
+		"requests:1",
+		"requests:x|c",
+		"requests:1|s",
+		"requests:1|c|0.5",
+		"requests:1|c|@0",
+		"requests:1|c|@2",
+		"requests:NaN|g",
+	} {
+		_, err := parseLine(line)
+		assert.Error(t, err, line)
+	}
+}
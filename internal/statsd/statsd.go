@@ -0,0 +1,253 @@
+// Package statsd реализует прием метрик по протоколу StatsD через UDP.
+//
+// Значения агрегируются в памяти и с заданным интервалом записываются в хранилище
+// так же, как пакет метрик, полученный HTTP-обработчиком /updates/: приращения счетчиков
+// прибавляются к сохраненным значениям, а гистограммы таймеров объединяются с сохраненными.
+package statsd
+
+import (
+	"bytes"
+	"context"
+	stderr "errors"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/eac0de/getmetrics/internal/api/handlers"
+	"github.com/eac0de/getmetrics/internal/models"
+	"github.com/eac0de/getmetrics/pkg/errors"
+)
+
+const (
+	// maxPacketSize - максимальный размер UDP-пакета.
+	maxPacketSize = 65535
+	// flushTimeout - время на запись последних значений при остановке.
+	flushTimeout = 5 * time.Second
+)
+
+// DefaultHistogramBuckets - границы корзин гистограмм таймеров по умолчанию, в миллисекундах.
+var DefaultHistogramBuckets = []float64{1, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// Listener принимает пакеты StatsD и периодически записывает агрегированные значения в хранилище.
+//
+// Поддерживаются счетчики (c), gauge (g), в том числе изменения со знаком +/-, и таймеры (ms),
+// которые сохраняются как гистограммы. Частота выборки @rate учитывается для счетчиков и таймеров.
+type Listener struct {
+	Addr string
+	// TrustedSubnet - подсеть, из которой принимаются пакеты. nil - пакеты принимаются с любого адреса.
+	TrustedSubnet   *net.IPNet
+	metricsHandlers *handlers.MetricsHandlers
+	buckets         []float64
+	logger          *slog.Logger
+	conn            net.PacketConn
+
+	mu       sync.Mutex
+	counters map[string]float64
+	gauges   map[string]*gauge
+	timers   map[string]*models.HistogramValue
+}
+
+// gauge - агрегированное значение gauge за интервал.
+type gauge struct {
+	value float64
+	// set - значение задано абсолютно. Иначе value - сумма изменений к текущему значению.
+	set bool
+}
+
+// New создает Listener. Если buckets пуст, используются DefaultHistogramBuckets.
+func New(addr string, metricsStore handlers.IMetricsStore, buckets []float64, logger *slog.Logger) (*Listener, error) {
+	if len(buckets) == 0 {
+		buckets = DefaultHistogramBuckets
+	}
+	histogram := models.HistogramValue{Bounds: buckets, Counts: make([]uint64, len(buckets)+1)}
+	if err := histogram.Validate(); err != nil {
+		return nil, err
+	}
+	l := &Listener{
+		Addr:            addr,
+		metricsHandlers: handlers.NewMetricsHandlers(metricsStore, "", logger),
+		buckets:         buckets,
+		logger:          logger,
+	}
+	l.reset()
+	return l, nil
+}
+
+// Listen открывает UDP-порт.
+func (l *Listener) Listen() error {
+	conn, err := net.ListenPacket("udp", l.Addr)
+	if err != nil {
+		return err
+	}
+	l.conn = conn
+	l.Addr = conn.LocalAddr().String()
+	return nil
+}
+
+// Start принимает пакеты и записывает значения в хранилище каждые interval до отмены ctx.
+// При остановке порт закрывается, а значения, накопленные с последней записи, записываются.
+func (l *Listener) Start(ctx context.Context, interval time.Duration) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		l.serve()
+	}()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			l.conn.Close()
+			wg.Wait()
+			flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), flushTimeout)
+			l.Flush(flushCtx)
+			cancel()
+			l.logger.Info("StatsD listener is shutting down")
+			return
+		case <-ticker.C:
+			l.Flush(ctx)
+		}
+	}
+}
+
+func (l *Listener) serve() {
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, peer, err := l.conn.ReadFrom(buf)
+		if err != nil {
+			if !stderr.Is(err, net.ErrClosed) {
+				l.logger.Error("StatsD read error", "error", err)
+			}
+			return
+		}
+		l.handlePacket(buf[:n], peer)
+	}
+}
+
+// handlePacket разбирает пакет из одной или нескольких строк, разделенных переводом строки.
+func (l *Listener) handlePacket(packet []byte, peer net.Addr) {
+	if !l.trusted(peer) {
+		// Пакеты UDP легко отправить в большом количестве, поэтому отказ не пишется в лог на уровне Warn
+		l.logger.Debug("StatsD packet from untrusted address", "peer", peer.String())
+		return
+	}
+	var samples []sample
+	for _, line := range bytes.Split(packet, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		s, err := parseLine(string(line))
+		if err == nil {
+			err = handlers.ValidateMetricID(s.name)
+		}
+		if err != nil {
+			l.logger.Warn("Invalid StatsD line", "peer", peer.String(), "line", string(line), "error", err)
+			continue
+		}
+		samples = append(samples, s)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, s := range samples {
+		l.add(s)
+	}
+}
+
+// trusted проверяет, что адрес отправителя входит в доверенную подсеть, если она задана.
+func (l *Listener) trusted(peer net.Addr) bool {
+	if l.TrustedSubnet == nil {
+		return true
+	}
+	addr, ok := peer.(*net.UDPAddr)
+	return ok && l.TrustedSubnet.Contains(addr.IP)
+}
+
+// add учитывает значение в агрегатах текущего интервала. Вызывается под l.mu.
+func (l *Listener) add(s sample) {
+	switch s.mType {
+	case typeCounter:
+		l.counters[s.name] += s.value / s.rate
+	case typeGauge:
+		g, ok := l.gauges[s.name]
+		if !ok {
+			g = &gauge{}
+			l.gauges[s.name] = g
+		}
+		if s.relative {
+			g.value += s.value
+		} else {
+			g.value, g.set = s.value, true
+		}
+	case typeTimer:
+		h, ok := l.timers[s.name]
+		if !ok {
+			h = &models.HistogramValue{Bounds: l.buckets, Counts: make([]uint64, len(l.buckets)+1)}
+			l.timers[s.name] = h
+		}
+		weight := uint64(math.Max(1, math.Round(1/s.rate)))
+		h.Counts[sort.SearchFloat64s(l.buckets, s.value)] += weight
+		h.Sum += s.value * float64(weight)
+		h.Count += weight
+	}
+}
+
+// Flush записывает значения, накопленные с последней записи, и начинает новый интервал.
+func (l *Listener) Flush(ctx context.Context) {
+	l.mu.Lock()
+	counters, gauges, timers := l.counters, l.gauges, l.timers
+	l.reset()
+	l.mu.Unlock()
+
+	metricsList := make([]models.Metric, 0, len(counters)+len(gauges)+len(timers))
+	for name, value := range counters {
+		delta := int64(math.Round(value))
+		metricsList = append(metricsList, models.Metric{ID: name, MType: models.Counter, Delta: &delta})
+	}
+	for name, g := range gauges {
+		value := g.value
+		if !g.set {
+			current, err := l.currentGauge(ctx, name)
+			if err != nil {
+				l.logger.ErrorContext(ctx, "StatsD gauge read error", "name", name, "error", err)
+				continue
+			}
+			value += current
+		}
+		metricsList = append(metricsList, models.Metric{ID: name, MType: models.Gauge, Value: &value})
+	}
+	for name, h := range timers {
+		metricsList = append(metricsList, models.Metric{ID: name, MType: models.Histogram, Histogram: h})
+	}
+	if len(metricsList) == 0 {
+		return
+	}
+	_, err := l.metricsHandlers.UpdateMetrics(ctx, metricsList)
+	if err != nil {
+		l.logger.ErrorContext(ctx, "StatsD metrics saving error", "count", len(metricsList), "error", err)
+	}
+}
+
+// currentGauge возвращает сохраненное значение gauge или 0, если метрики еще нет.
+func (l *Listener) currentGauge(ctx context.Context, name string) (float64, error) {
+	metric, err := l.metricsHandlers.MetricsStore.GetMetric(ctx, name, models.Gauge, nil)
+	if err != nil {
+		if _, statusCode := errors.GetMessageAndStatusCode(err); statusCode == http.StatusNotFound {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return *metric.Value, nil
+}
+
+// reset начинает новый интервал агрегации. Вызывается под l.mu или до запуска.
+func (l *Listener) reset() {
+	l.counters = make(map[string]float64)
+	l.gauges = make(map[string]*gauge)
+	l.timers = make(map[string]*models.HistogramValue)
+}
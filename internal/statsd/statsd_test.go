@@ -0,0 +1,115 @@
+package statsd
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eac0de/getmetrics/internal/models"
+	"github.com/eac0de/getmetrics/internal/storage/memstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListenerFlush(t *testing.T) {
+	store := memstore.New()
+	ctx := context.Background()
+	delta, value := int64(10), 5.0
+	require.NoError(t, store.SaveMetrics(ctx, []models.Metric{
+		{ID: "requests", MType: models.Counter, Delta: &delta},
+		{ID: "workers", MType: models.Gauge, Value: &value},
+	}))
+	listener, err := New("", store, []float64{10, 100}, slog.Default())
+	require.NoError(t, err)
+
+	peer := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1}
+	listener.handlePacket([]byte("requests:1|c\nrequests:1|c|@0.5\nbad line\nrequests{a}:1|c\n"), peer)
+	listener.handlePacket([]byte("workers:+2|g\nworkers:-1|g\nqueue:7|g\nqueue:+1|g"), peer)
+	listener.handlePacket([]byte("latency:5|ms\nlatency:50|ms|@0.5\nlatency:500|ms"), peer)
+	listener.Flush(ctx)
+
+	metric, err := store.GetMetric(ctx, "requests", models.Counter, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(13), *metric.Delta)
+	metric, err = store.GetMetric(ctx, "workers", models.Gauge, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 6.0, *metric.Value)
+	metric, err = store.GetMetric(ctx, "queue", models.Gauge, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 8.0, *metric.Value)
+	metric, err = store.GetMetric(ctx, "latency", models.Histogram, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{1, 2, 1}, metric.Histogram.Counts)
+	assert.Equal(t, uint64(4), metric.Histogram.Count)
+	assert.Equal(t, 605.0, metric.Histogram.Sum)
+	_, err = store.GetMetric(ctx, "requests{a}", models.Counter, nil)
+	assert.Error(t, err)
+
+	// Следующий интервал прибавляет приращения и объединяет гистограммы с сохраненными
+	listener.handlePacket([]byte("requests:2|c\nlatency:5|ms"), peer)
+	listener.Flush(ctx)
+	metric, err = store.GetMetric(ctx, "requests", models.Counter, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(15), *metric.Delta)
+	metric, err = store.GetMetric(ctx, "latency", models.Histogram, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{2, 2, 1}, metric.Histogram.Counts)
+}
+
+func TestListenerUDP(t *testing.T) {
+	store := memstore.New()
+	listener, err := New("127.0.0.1:0", store, nil, slog.Default())
+	require.NoError(t, err)
+	require.NoError(t, listener.Listen())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		listener.Start(ctx, time.Hour)
+	}()
+
+	conn, err := net.Dial("udp", listener.Addr)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("jobs:3|c"))
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		listener.mu.Lock()
+		defer listener.mu.Unlock()
+		return listener.counters["jobs"] == 3
+	}, time.Second, 10*time.Millisecond)
+
+	// Значения, накопленные до остановки, записываются в хранилище
+	cancel()
+	wg.Wait()
+	metric, err := store.GetMetric(context.Background(), "jobs", models.Counter, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), *metric.Delta)
+}
+
+func TestListenerTrustedSubnet(t *testing.T) {
+	store := memstore.New()
+	listener, err := New("", store, nil, slog.Default())
+	require.NoError(t, err)
+	_, listener.TrustedSubnet, err = net.ParseCIDR("192.168.1.0/24")
+	require.NoError(t, err)
+
+	listener.handlePacket([]byte("trusted:1|c"), &net.UDPAddr{IP: net.IPv4(192, 168, 1, 10), Port: 1})
+	listener.handlePacket([]byte("untrusted:1|c"), &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 1})
+	listener.Flush(context.Background())
+
+	_, err = store.GetMetric(context.Background(), "trusted", models.Counter, nil)
+	assert.NoError(t, err)
+	_, err = store.GetMetric(context.Background(), "untrusted", models.Counter, nil)
+	assert.Error(t, err)
+}
+
+func TestNewInvalidBuckets(t *testing.T) {
+	_, err := New("", memstore.New(), []float64{10, 5}, slog.Default())
+	assert.Error(t, err)
+}
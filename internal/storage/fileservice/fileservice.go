@@ -4,68 +4,232 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"sync"
 	"time"
 
+	"github.com/eac0de/getmetrics/internal/models"
 	"github.com/eac0de/getmetrics/internal/storage/memstore"
 )
 
+const (
+	// walSuffix - суффикс файла журнала обновлений, который ведется рядом со снимком.
+	walSuffix = ".wal"
+	// maxWALSize - размер журнала, при превышении которого записывается новый снимок.
+	maxWALSize = 64 << 20
+)
+
+// FileService хранит метрики в памяти и сохраняет их на диск.
+//
+// Периодически записывается снимок всех метрик, а обновления между снимками
+// дописываются в журнал (WAL). При запуске восстанавливается снимок, затем журнал.
+//
+// Если интервал сохранения равен нулю, запись синхронная: журнал сбрасывается на диск
+// после каждого обновления, и обновление считается успешным только после этого.
+// Иначе журнал сбрасывается на диск при записи каждого снимка и при Close: после сбоя
+// процесса записи журнала сохраняются, а после сбоя ОС или питания могут быть потеряны
+// обновления не более чем за последний StoreInterval.
 type FileService struct {
-	MemoryStorage *memstore.MemoryStore
+	*memstore.MemoryStore
 	FilePath      string
+	StoreInterval time.Duration
+	Logger        *slog.Logger
+
+	// mu упорядочивает обновления с записями в журнал и записью снимка.
+	mu      sync.Mutex
+	wal     *os.File
+	walSize int64
 }
 
-func New(memoryStorage *memstore.MemoryStore, filePath string) (*FileService, error) {
+// New создает FileService. Если restore равен false, сохраненные метрики не загружаются,
+// а снимок и журнал сразу перезаписываются текущими значениями memoryStorage.
+func New(memoryStorage *memstore.MemoryStore, filePath string, storeInterval time.Duration, restore bool, logger *slog.Logger) (*FileService, error) {
 	if filePath == "" {
 		return nil, fmt.Errorf("filePath cannot be an empty string")
 	}
-	f, err := os.OpenFile(filePath, os.O_CREATE|os.O_RDONLY, 0666)
+	fs := &FileService{
+		MemoryStore:   memoryStorage,
+		FilePath:      filePath,
+		StoreInterval: storeInterval,
+		Logger:        logger,
+	}
+	if restore {
+		err := fs.loadSnapshot()
+		if err != nil {
+			return nil, err
+		}
+		err = fs.replayWAL()
+		if err != nil {
+			return nil, err
+		}
+	}
+	flag := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if !restore {
+		flag |= os.O_TRUNC
+	}
+	wal, err := os.OpenFile(fs.walPath(), flag, 0666)
+	if err != nil {
+		return nil, err
+	}
+	info, err := wal.Stat()
 	if err != nil {
+		wal.Close()
 		return nil, err
 	}
-	defer f.Close()
+	fs.wal = wal
+	fs.walSize = info.Size()
+	if !restore {
+		// Снимок прошлого запуска заменяется пустым, иначе он восстановится при следующем запуске
+		err = fs.saveSnapshot()
+		if err != nil {
+			wal.Close()
+			return nil, err
+		}
+	}
+	return fs, nil
+}
 
-	decoder := json.NewDecoder(f)
-	decoder.Decode(&memoryStorage.MetricsData)
+// SyncWrites сообщает, сохраняется ли каждое обновление на диск синхронно.
+func (fs *FileService) SyncWrites() bool {
+	return fs.StoreInterval <= 0
+}
 
-	return &FileService{
-		MemoryStorage: memoryStorage,
-		FilePath:      filePath,
-	}, nil
+// Close сбрасывает журнал на диск и закрывает его. Снимок при этом не записывается.
+func (fs *FileService) Close() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	err := fs.wal.Sync()
+	if err != nil {
+		fs.wal.Close()
+		return fmt.Errorf("sync metrics log %s: %w", fs.walPath(), err)
+	}
+	return fs.wal.Close()
 }
 
-func (fs *FileService) SaveMetrics() error {
-	f, err := os.OpenFile(fs.FilePath, os.O_CREATE|os.O_WRONLY, 0666)
+// SaveSnapshot записывает снимок всех метрик и очищает журнал.
+//
+// Снимок пишется во временный файл, который после fsync переименовывается в FilePath,
+// поэтому при сбое на диске остается либо предыдущий, либо новый снимок целиком.
+//
+// Если снимок записать не удалось, журнал сбрасывается на диск, чтобы обновления
+// с предыдущего снимка сохранились хотя бы в нем.
+func (fs *FileService) SaveSnapshot() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	err := fs.saveSnapshot()
 	if err != nil {
+		if syncErr := fs.wal.Sync(); syncErr != nil {
+			fs.Logger.Error("Metrics log sync error", "path", fs.walPath(), "error", syncErr)
+		}
 		return err
 	}
-	defer f.Close()
-	data, err := json.MarshalIndent(fs.MemoryStorage.MetricsData, "", "    ")
+	return nil
+}
+
+// saveSnapshot записывает снимок. Вызывается под fs.mu.
+func (fs *FileService) saveSnapshot() error {
+	data, err := json.MarshalIndent(fs.MemoryStore.Snapshot(), "", "    ")
 	if err != nil {
 		return err
 	}
-	_, err = f.Write(data)
+	err = writeFileAtomic(fs.FilePath, data)
 	if err != nil {
 		return err
 	}
-	log.Println("Metric are saved to file")
+	// Все записи журнала вошли в снимок
+	err = fs.wal.Truncate(0)
+	if err != nil {
+		return err
+	}
+	fs.walSize = 0
+	err = fs.wal.Sync()
+	if err != nil {
+		return fmt.Errorf("sync metrics log %s: %w", fs.walPath(), err)
+	}
+	fs.Logger.Debug("Metrics are saved to file", "path", fs.FilePath)
 	return nil
 }
 
-func (fs *FileService) StartSavingMetrics(ctx context.Context, interval time.Duration) {
-	ticker := time.NewTicker(interval)
+// StartSavingMetrics записывает снимок каждые StoreInterval до отмены ctx.
+// При синхронной записи снимки по таймеру не нужны, и функция сразу завершается.
+func (fs *FileService) StartSavingMetrics(ctx context.Context) {
+	if fs.SyncWrites() {
+		return
+	}
+	ticker := time.NewTicker(fs.StoreInterval)
+	defer ticker.Stop()
 	for {
 		select {
 		case <-ctx.Done():
-			log.Println("StartSavingMetrics goroutine is shutting down...")
+			fs.Logger.Info("StartSavingMetrics goroutine is shutting down")
 			return
 		case <-ticker.C:
-			err := fs.SaveMetrics()
+			err := fs.SaveSnapshot()
 			if err != nil {
-				log.Printf("Metric saving to file error: %s", err.Error())
+				fs.Logger.Error("Metrics saving to file error", "path", fs.FilePath, "error", err)
 			}
 		}
 
 	}
 }
+
+func (fs *FileService) loadSnapshot() error {
+	data, err := os.ReadFile(fs.FilePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	var metricsData models.MetricsData
+	err = json.Unmarshal(data, &metricsData)
+	if err != nil {
+		return fmt.Errorf("metrics snapshot %s is corrupted: %w", fs.FilePath, err)
+	}
+	fs.MemoryStore.Restore(metricsData)
+	return nil
+}
+
+func (fs *FileService) walPath() string {
+	return fs.FilePath + walSuffix
+}
+
+// writeFileAtomic записывает данные во временный файл в том же каталоге и переименовывает его в path.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	err = tmp.Sync()
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return err
+	}
+	// Синхронизируем каталог, чтобы переименование пережило сбой питания
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
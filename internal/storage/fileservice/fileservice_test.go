@@ -0,0 +1,210 @@
+package fileservice
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/eac0de/getmetrics/internal/models"
+	"github.com/eac0de/getmetrics/internal/storage/memstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func gauge(id string, value float64) models.Metric {
+	return models.Metric{ID: id, MType: models.Gauge, Value: &value}
+}
+
+func counter(id string, delta int64) models.Metric {
+	return models.Metric{ID: id, MType: models.Counter, Delta: &delta}
+}
+
+func TestSnapshotAndWALRestore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	fs, err := New(memstore.New(), path, time.Minute, true, slog.Default())
+	require.NoError(t, err)
+
+	require.NoError(t, fs.SaveMetric(ctx, gauge("Alloc", 1)))
+	_, err = fs.IncrementCounter(ctx, counter("PollCount", 2))
+	require.NoError(t, err)
+	require.NoError(t, fs.SaveSnapshot())
+
+	// Обновления после снимка попадают только в журнал
+	require.NoError(t, fs.SaveMetric(ctx, gauge("Alloc", 3)))
+	_, err = fs.IncrementCounters(ctx, []models.Metric{counter("PollCount", 5)})
+	require.NoError(t, err)
+	require.NoError(t, fs.Close())
+
+	restored, err := New(memstore.New(), path, time.Minute, true, slog.Default())
+	require.NoError(t, err)
+	defer restored.Close()
+	metric, err := restored.GetMetric(ctx, "Alloc", models.Gauge, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 3.0, *metric.Value)
+	metric, err = restored.GetMetric(ctx, "PollCount", models.Counter, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), *metric.Delta)
+}
+
+func TestSaveSnapshotTruncatesWAL(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	fs, err := New(memstore.New(), path, time.Minute, true, slog.Default())
+	require.NoError(t, err)
+	defer fs.Close()
+
+	// Длинный снимок, затем короткий: старое содержимое не должно остаться в файле
+	for _, id := range []string{"Alloc", "BuckHashSys", "Frees", "GCSys", "HeapAlloc"} {
+		require.NoError(t, fs.SaveMetric(ctx, gauge(id, 1)))
+	}
+	require.NoError(t, fs.SaveSnapshot())
+	fs.MemoryStore.Restore(models.MetricsData{})
+	require.NoError(t, fs.SaveMetric(ctx, gauge("Alloc", 2)))
+	require.NoError(t, fs.SaveSnapshot())
+
+	info, err := os.Stat(path + walSuffix)
+	require.NoError(t, err)
+	assert.Zero(t, info.Size())
+
+	restored, err := New(memstore.New(), path, time.Minute, true, slog.Default())
+	require.NoError(t, err)
+	defer restored.Close()
+	metrics, err := restored.ListAllMetrics(ctx)
+	require.NoError(t, err)
+	assert.Len(t, metrics, 1)
+}
+
+func TestRestoreIncompleteWALRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	wal := `{"id":"Alloc","type":"gauge","value":1}` + "\n" + `{"id":"Alloc","type":"gau`
+	require.NoError(t, os.WriteFile(path+walSuffix, []byte(wal), 0666))
+
+	fs, err := New(memstore.New(), path, time.Minute, true, slog.Default())
+	require.NoError(t, err)
+	defer fs.Close()
+	metric, err := fs.GetMetric(context.Background(), "Alloc", models.Gauge, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, *metric.Value)
+
+	// Незавершенная запись отброшена, новые записи дописываются после последней целой
+	require.NoError(t, fs.SaveMetric(context.Background(), gauge("Alloc", 2)))
+	data, err := os.ReadFile(path + walSuffix)
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":"Alloc","type":"gauge","value":1}`+"\n"+`{"id":"Alloc","type":"gauge","value":2}`+"\n", string(data))
+}
+
+func TestRestoreCorruptedFiles(t *testing.T) {
+	t.Run("snapshot", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "metrics.json")
+		require.NoError(t, os.WriteFile(path, []byte(`{"gauge":{"Alloc":1}}garbage`), 0666))
+		_, err := New(memstore.New(), path, time.Minute, true, slog.Default())
+		assert.ErrorContains(t, err, "metrics snapshot "+path+" is corrupted")
+	})
+	t.Run("wal", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "metrics.json")
+		wal := "not json\n" + `{"id":"Alloc","type":"gauge","value":1}` + "\n"
+		require.NoError(t, os.WriteFile(path+walSuffix, []byte(wal), 0666))
+		_, err := New(memstore.New(), path, time.Minute, true, slog.Default())
+		assert.ErrorContains(t, err, "is corrupted at line 1")
+	})
+}
+
+func TestNewWithoutRestore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	fs, err := New(memstore.New(), path, time.Minute, true, slog.Default())
+	require.NoError(t, err)
+	require.NoError(t, fs.SaveMetric(ctx, gauge("Alloc", 1)))
+	require.NoError(t, fs.SaveSnapshot())
+	require.NoError(t, fs.SaveMetric(ctx, gauge("HeapAlloc", 1)))
+	require.NoError(t, fs.Close())
+
+	fs, err = New(memstore.New(), path, time.Minute, false, slog.Default())
+	require.NoError(t, err)
+	metrics, err := fs.ListAllMetrics(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, metrics)
+
+	// Журнал прошлого запуска отброшен и не применится при следующем восстановлении
+	info, err := os.Stat(path + walSuffix)
+	require.NoError(t, err)
+	assert.Zero(t, info.Size())
+	require.NoError(t, fs.Close())
+
+	// Снимок прошлого запуска тоже заменен пустым
+	fs, err = New(memstore.New(), path, time.Minute, true, slog.Default())
+	require.NoError(t, err)
+	defer fs.Close()
+	metrics, err = fs.ListAllMetrics(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, metrics)
+}
+
+func TestSyncWrites(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	fs, err := New(memstore.New(), path, 0, true, slog.Default())
+	require.NoError(t, err)
+	defer fs.Close()
+	assert.True(t, fs.SyncWrites())
+
+	// При синхронной записи таймер снимков не запускается
+	done := make(chan struct{})
+	go func() {
+		fs.StartSavingMetrics(ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("StartSavingMetrics did not return in synchronous mode")
+	}
+
+	_, err = fs.IncrementCounter(ctx, counter("PollCount", 3))
+	require.NoError(t, err)
+
+	// Обновление уже на диске, хотя снимок не записывался
+	restored, err := New(memstore.New(), path, 0, true, slog.Default())
+	require.NoError(t, err)
+	defer restored.Close()
+	metric, err := restored.GetMetric(ctx, "PollCount", models.Counter, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), *metric.Delta)
+}
+
+func TestWALWriteErrorDoesNotApplyUpdate(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	fs, err := New(memstore.New(), path, 0, true, slog.Default())
+	require.NoError(t, err)
+	_, err = fs.IncrementCounter(ctx, counter("PollCount", 1))
+	require.NoError(t, err)
+
+	// Журнал, открытый только на чтение, не принимает записи
+	wal := fs.wal
+	fs.wal, err = os.Open(fs.walPath())
+	require.NoError(t, err)
+	_, err = fs.IncrementCounter(ctx, counter("PollCount", 2))
+	assert.Error(t, err)
+	fs.wal.Close()
+	fs.wal = wal
+
+	// Неудачное обновление не применено, и повтор учитывает приращение один раз
+	metric, err := fs.GetMetric(ctx, "PollCount", models.Counter, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), *metric.Delta)
+	_, err = fs.IncrementCounter(ctx, counter("PollCount", 2))
+	require.NoError(t, err)
+	require.NoError(t, fs.Close())
+
+	restored, err := New(memstore.New(), path, 0, true, slog.Default())
+	require.NoError(t, err)
+	defer restored.Close()
+	metric, err = restored.GetMetric(ctx, "PollCount", models.Counter, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), *metric.Delta)
+}
@@ -0,0 +1,164 @@
+package fileservice
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/eac0de/getmetrics/internal/models"
+)
+
+// Журнал хранит по одной метрике в формате JSON на строку. Записываются итоговые значения
+// метрик после обновления, а не приращения, поэтому повторное применение записи безопасно.
+
+func (fs *FileService) SaveMetric(ctx context.Context, metric models.Metric) error {
+	return fs.SaveMetrics(ctx, []models.Metric{metric})
+}
+
+func (fs *FileService) SaveMetrics(ctx context.Context, metricsList []models.Metric) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.update(ctx, metricsList)
+}
+
+func (fs *FileService) IncrementCounter(ctx context.Context, metric models.Metric) (*models.Metric, error) {
+	metricsList, err := fs.IncrementCounters(ctx, []models.Metric{metric})
+	if err != nil {
+		return nil, err
+	}
+	return &metricsList[0], nil
+}
+
+func (fs *FileService) IncrementCounters(ctx context.Context, metricsList []models.Metric) ([]models.Metric, error) {
+	return fs.UpdateMetrics(ctx, metricsList)
+}
+
+func (fs *FileService) UpdateMetrics(ctx context.Context, metricsList []models.Metric) ([]models.Metric, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	metricsList = fs.MemoryStore.UpdatedMetrics(ctx, metricsList)
+	err := fs.update(ctx, metricsList)
+	if err != nil {
+		return nil, err
+	}
+	return metricsList, nil
+}
+
+// update записывает итоговые значения метрик в журнал и только после этого в память.
+// Если записать журнал не удалось, обновление не применяется, и повтор запроса клиентом
+// не учтет приращения дважды. Вызывается под fs.mu.
+func (fs *FileService) update(ctx context.Context, metricsList []models.Metric) error {
+	err := fs.appendWAL(metricsList)
+	if err != nil {
+		return err
+	}
+	err = fs.MemoryStore.SaveMetrics(ctx, metricsList)
+	if err != nil {
+		return err
+	}
+	// Обновление уже в журнале, поэтому ошибка записи снимка его не отменяет
+	if fs.walSize > maxWALSize {
+		err = fs.saveSnapshot()
+		if err != nil {
+			fs.Logger.Error("Metrics saving to file error", "path", fs.FilePath, "error", err)
+		}
+	}
+	return nil
+}
+
+// appendWAL дописывает метрики в журнал одной записью. Вызывается под fs.mu.
+//
+// При ошибке журнал обрезается до предыдущей записи, чтобы неполная строка не осталась
+// в середине журнала и не помешала его восстановлению.
+func (fs *FileService) appendWAL(metricsList []models.Metric) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, metric := range metricsList {
+		err := encoder.Encode(metric)
+		if err != nil {
+			return err
+		}
+	}
+	_, err := fs.wal.Write(buf.Bytes())
+	if err != nil {
+		fs.truncateWAL()
+		return fmt.Errorf("write metrics log %s: %w", fs.walPath(), err)
+	}
+	if fs.SyncWrites() {
+		err = fs.wal.Sync()
+		if err != nil {
+			fs.truncateWAL()
+			return fmt.Errorf("sync metrics log %s: %w", fs.walPath(), err)
+		}
+	}
+	fs.walSize += int64(buf.Len())
+	return nil
+}
+
+// truncateWAL отбрасывает из журнала данные после последней успешной записи. Вызывается под fs.mu.
+func (fs *FileService) truncateWAL() {
+	err := fs.wal.Truncate(fs.walSize)
+	if err != nil {
+		fs.Logger.Error("Metrics log truncate error", "path", fs.walPath(), "error", err)
+	}
+}
+
+// replayWAL применяет записи журнала поверх восстановленного снимка.
+//
+// Незавершенная последняя строка остается после сбоя во время записи: она отбрасывается
+// с предупреждением, а журнал обрезается до последней целой записи.
+// Поврежденная запись в середине журнала считается ошибкой.
+func (fs *FileService) replayWAL() error {
+	f, err := os.OpenFile(fs.walPath(), os.O_RDWR, 0666)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+	reader := bufio.NewReader(f)
+	var offset int64
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) == 0 {
+				return nil
+			}
+			fs.Logger.Warn("Metrics log ends with an incomplete record, it is discarded", "path", fs.walPath())
+			return f.Truncate(offset)
+		}
+		if err != nil {
+			return err
+		}
+		var metric models.Metric
+		err = json.Unmarshal(line, &metric)
+		if err == nil && !hasValue(metric) {
+			err = fmt.Errorf("metric %s of type %s has no value", metric.ID, metric.MType)
+		}
+		if err != nil {
+			return fmt.Errorf("metrics log %s is corrupted at line %d: %w", fs.walPath(), lineNumber, err)
+		}
+		err = fs.MemoryStore.SaveMetric(context.Background(), metric)
+		if err != nil {
+			return err
+		}
+		offset += int64(len(line))
+	}
+}
+
+func hasValue(metric models.Metric) bool {
+	switch metric.MType {
+	case models.Gauge:
+		return metric.Value != nil
+	case models.Counter:
+		return metric.Delta != nil
+	case models.Histogram:
+		return metric.Histogram != nil
+	}
+	return false
+}
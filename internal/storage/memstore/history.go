@@ -0,0 +1,65 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/eac0de/getmetrics/internal/models"
+)
+
+func (store *MemoryStore) QueryRange(ctx context.Context, metricName string, metricType string, labels models.Labels, from time.Time, to time.Time) ([]models.Sample, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	return store.queryRange(historyKey(models.SeriesKey(metricName, labels), metricType), from, to), nil
+}
+
+func (store *MemoryStore) QueryRanges(ctx context.Context, series []models.Metric, from time.Time, to time.Time) ([][]models.Sample, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	result := make([][]models.Sample, len(series))
+	for i, metric := range series {
+		result[i] = store.queryRange(historyKey(metric.SeriesKey(), metric.MType), from, to)
+	}
+	return result, nil
+}
+
+// queryRange возвращает значения истории ряда в интервале [from, to]. Вызывается под store.mu.
+func (store *MemoryStore) queryRange(key string, from time.Time, to time.Time) []models.Sample {
+	samples := store.history[key]
+	start := sort.Search(len(samples), func(i int) bool {
+		return !samples[i].Timestamp.Before(from)
+	})
+	end := sort.Search(len(samples), func(i int) bool {
+		return samples[i].Timestamp.After(to)
+	})
+	if start >= end {
+		return []models.Sample{}
+	}
+	result := make([]models.Sample, end-start)
+	copy(result, samples[start:end])
+	return result
+}
+
+// appendSample добавляет значение метрики в историю. Вызывается под store.mu.
+func (store *MemoryStore) appendSample(metric models.Metric, ts time.Time) {
+	var value float64
+	switch metric.MType {
+	case models.Gauge:
+		value = *metric.Value
+	case models.Counter:
+		value = float64(*metric.Delta)
+	default:
+		return
+	}
+	key := historyKey(metric.SeriesKey(), metric.MType)
+	samples := append(store.history[key], models.Sample{Timestamp: ts, Value: value})
+	if len(samples) > maxHistorySamples {
+		samples = samples[len(samples)-maxHistorySamples:]
+	}
+	store.history[key] = samples
+}
+
+func historyKey(seriesKey string, metricType string) string {
+	return metricType + ":" + seriesKey
+}
@@ -0,0 +1,66 @@
+package memstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/eac0de/getmetrics/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryRange(t *testing.T) {
+	store := New()
+	from := time.Now()
+	for _, v := range []float64{1, 2, 3} {
+		err := store.SaveMetric(context.Background(), models.Metric{
+			ID:    "test_gauge",
+			MType: models.Gauge,
+			Value: &v,
+		})
+		assert.NoError(t, err)
+	}
+	to := time.Now()
+
+	t.Run("all samples", func(t *testing.T) {
+		samples, err := store.QueryRange(context.Background(), "test_gauge", models.Gauge, nil, from, to)
+		assert.NoError(t, err)
+		values := make([]float64, 0, len(samples))
+		for _, sample := range samples {
+			values = append(values, sample.Value)
+		}
+		assert.Equal(t, []float64{1, 2, 3}, values)
+	})
+	t.Run("empty range", func(t *testing.T) {
+		samples, err := store.QueryRange(context.Background(), "test_gauge", models.Gauge, nil, to.Add(time.Second), to.Add(time.Hour))
+		assert.NoError(t, err)
+		assert.Empty(t, samples)
+	})
+	t.Run("unknown metric", func(t *testing.T) {
+		samples, err := store.QueryRange(context.Background(), "test_gauge", models.Counter, nil, from, to)
+		assert.NoError(t, err)
+		assert.Empty(t, samples)
+	})
+	t.Run("several series", func(t *testing.T) {
+		history, err := store.QueryRanges(context.Background(), []models.Metric{
+			{ID: "test_gauge", MType: models.Counter},
+			{ID: "test_gauge", MType: models.Gauge},
+		}, from, to)
+		assert.NoError(t, err)
+		assert.Len(t, history, 2)
+		assert.Empty(t, history[0])
+		assert.Len(t, history[1], 3)
+	})
+}
+
+func TestHistoryLimit(t *testing.T) {
+	store := New()
+	for i := 0; i < maxHistorySamples+10; i++ {
+		delta := int64(i)
+		err := store.SaveMetric(context.Background(), models.Metric{ID: "test_counter", MType: models.Counter, Delta: &delta})
+		assert.NoError(t, err)
+	}
+	samples := store.history[historyKey("test_counter", models.Counter)]
+	assert.Len(t, samples, maxHistorySamples)
+	assert.Equal(t, float64(10), samples[0].Value)
+}
@@ -6,17 +6,61 @@ import (
 	"github.com/eac0de/getmetrics/internal/models"
 )
 
+// maxHistorySamples - максимальное количество значений, хранимых в истории одной метрики.
+// При превышении отбрасываются самые старые значения.
+const maxHistorySamples = 10000
+
 type MemoryStore struct {
 	mu          sync.Mutex
 	MetricsData models.MetricsData
+	history     map[string][]models.Sample
 }
 
 func New() *MemoryStore {
 	store := MemoryStore{
 		MetricsData: models.MetricsData{
-			Counter: make(map[string]int64),
-			Gauge:   make(map[string]float64),
+			Counter:   make(map[string]int64),
+			Gauge:     make(map[string]float64),
+			Histogram: make(map[string]models.HistogramValue),
 		},
+		history: make(map[string][]models.Sample),
 	}
 	return &store
 }
+
+// Snapshot возвращает копию текущих значений метрик.
+func (store *MemoryStore) Snapshot() models.MetricsData {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	data := models.MetricsData{
+		Counter:   make(map[string]int64, len(store.MetricsData.Counter)),
+		Gauge:     make(map[string]float64, len(store.MetricsData.Gauge)),
+		Histogram: make(map[string]models.HistogramValue, len(store.MetricsData.Histogram)),
+	}
+	for key, delta := range store.MetricsData.Counter {
+		data.Counter[key] = delta
+	}
+	for key, value := range store.MetricsData.Gauge {
+		data.Gauge[key] = value
+	}
+	for key, histogram := range store.MetricsData.Histogram {
+		data.Histogram[key] = histogram
+	}
+	return data
+}
+
+// Restore заменяет текущие значения метрик переданными. История значений не меняется.
+func (store *MemoryStore) Restore(data models.MetricsData) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if data.Counter == nil {
+		data.Counter = make(map[string]int64)
+	}
+	if data.Gauge == nil {
+		data.Gauge = make(map[string]float64)
+	}
+	if data.Histogram == nil {
+		data.Histogram = make(map[string]models.HistogramValue)
+	}
+	store.MetricsData = data
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	stderr "errors"
 	"net/http"
+	"time"
 
 	"github.com/eac0de/getmetrics/internal/models"
 	"github.com/eac0de/getmetrics/pkg/errors"
@@ -12,13 +13,24 @@ import (
 func (store *MemoryStore) SaveMetric(ctx context.Context, metric models.Metric) error {
 	store.mu.Lock()
 	defer store.mu.Unlock()
+	store.saveMetric(metric)
+	return nil
+}
+
+// saveMetric записывает значение метрики. Вызывается под store.mu.
+func (store *MemoryStore) saveMetric(metric models.Metric) {
 	switch metric.MType {
 	case models.Gauge:
-		store.MetricsData.Gauge[metric.ID] = *metric.Value
+		store.MetricsData.Gauge[metric.SeriesKey()] = *metric.Value
 	case models.Counter:
-		store.MetricsData.Counter[metric.ID] = *metric.Delta
+		store.MetricsData.Counter[metric.SeriesKey()] = *metric.Delta
+	case models.Histogram:
+		if store.MetricsData.Histogram == nil {
+			store.MetricsData.Histogram = make(map[string]models.HistogramValue)
+		}
+		store.MetricsData.Histogram[metric.SeriesKey()] = *metric.Histogram
 	}
-	return nil
+	store.appendSample(metric, time.Now())
 }
 
 func (store *MemoryStore) SaveMetrics(ctx context.Context, metricsList []models.Metric) error {
@@ -36,14 +48,94 @@ func (store *MemoryStore) SaveMetrics(ctx context.Context, metricsList []models.
 	return nil
 }
 
-func (store *MemoryStore) GetMetric(ctx context.Context, metricName string, metricType string) (*models.Metric, error) {
+func (store *MemoryStore) IncrementCounter(ctx context.Context, metric models.Metric) (*models.Metric, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	return store.incrementCounter(metric), nil
+}
+
+func (store *MemoryStore) IncrementCounters(ctx context.Context, metricsList []models.Metric) ([]models.Metric, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	result := make([]models.Metric, 0, len(metricsList))
+	for _, metric := range metricsList {
+		result = append(result, *store.incrementCounter(metric))
+	}
+	return result, nil
+}
+
+// UpdateMetrics применяет пакет метрик под одной блокировкой: значения gauge записываются,
+// приращения counter и histogram прибавляются к сохраненным значениям.
+func (store *MemoryStore) UpdateMetrics(ctx context.Context, metricsList []models.Metric) ([]models.Metric, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	result := store.updatedMetrics(metricsList)
+	for _, metric := range result {
+		store.saveMetric(metric)
+	}
+	return result, nil
+}
+
+// UpdatedMetrics возвращает значения метрик, которые записал бы UpdateMetrics, не изменяя хранилище.
+func (store *MemoryStore) UpdatedMetrics(ctx context.Context, metricsList []models.Metric) []models.Metric {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	return store.updatedMetrics(metricsList)
+}
+
+// updatedMetrics вычисляет значения метрик после применения пакета с учетом повторов
+// одной метрики в пакете. Вызывается под store.mu.
+func (store *MemoryStore) updatedMetrics(metricsList []models.Metric) []models.Metric {
+	counters := make(map[string]int64)
+	histograms := make(map[string]*models.HistogramValue)
+	result := make([]models.Metric, 0, len(metricsList))
+	for _, metric := range metricsList {
+		key := metric.SeriesKey()
+		switch metric.MType {
+		case models.Counter:
+			delta, ok := counters[key]
+			if !ok {
+				delta = store.MetricsData.Counter[key]
+			}
+			delta += *metric.Delta
+			counters[key] = delta
+			metric.Delta = &delta
+		case models.Histogram:
+			old, ok := histograms[key]
+			if !ok {
+				if histogram, ok := store.MetricsData.Histogram[key]; ok {
+					old = &histogram
+				}
+			}
+			metric.Histogram = old.Accumulate(metric.Histogram)
+			histograms[key] = metric.Histogram
+		}
+		result = append(result, metric)
+	}
+	return result
+}
+
+// incrementCounter прибавляет приращение к счетчику и возвращает накопленное значение. Вызывается под store.mu.
+func (store *MemoryStore) incrementCounter(metric models.Metric) *models.Metric {
+	key := metric.SeriesKey()
+	delta := store.MetricsData.Counter[key] + *metric.Delta
+	store.MetricsData.Counter[key] = delta
+	metric.Delta = &delta
+	store.appendSample(metric, time.Now())
+	return &metric
+}
+
+func (store *MemoryStore) GetMetric(ctx context.Context, metricName string, metricType string, labels models.Labels) (*models.Metric, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
 	metric := models.Metric{
-		ID:    metricName,
-		MType: metricType,
+		ID:     metricName,
+		MType:  metricType,
+		Labels: labels,
 	}
 	switch metricType {
 	case models.Gauge:
-		value, ok := store.MetricsData.Gauge[metric.ID]
+		value, ok := store.MetricsData.Gauge[metric.SeriesKey()]
 		if !ok {
 			return nil, errors.NewErrorWithHTTPStatus(
 				nil,
@@ -53,7 +145,7 @@ func (store *MemoryStore) GetMetric(ctx context.Context, metricName string, metr
 		}
 		metric.Value = &value
 	case models.Counter:
-		delta, ok := store.MetricsData.Counter[metric.ID]
+		delta, ok := store.MetricsData.Counter[metric.SeriesKey()]
 		if !ok {
 			return nil, errors.NewErrorWithHTTPStatus(
 				nil,
@@ -62,25 +154,51 @@ func (store *MemoryStore) GetMetric(ctx context.Context, metricName string, metr
 			)
 		}
 		metric.Delta = &delta
+	case models.Histogram:
+		histogram, ok := store.MetricsData.Histogram[metric.SeriesKey()]
+		if !ok {
+			return nil, errors.NewErrorWithHTTPStatus(
+				nil,
+				"Metric not found",
+				http.StatusNotFound,
+			)
+		}
+		metric.Histogram = &histogram
 	}
 	return &metric, nil
 }
 
 func (store *MemoryStore) ListAllMetrics(ctx context.Context) ([]*models.Metric, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
 	var metrics []*models.Metric
-	for name, value := range store.MetricsData.Gauge {
+	for key, value := range store.MetricsData.Gauge {
+		name, labels := models.ParseSeriesKey(key)
+		metric := models.Metric{
+			ID:     name,
+			MType:  models.Gauge,
+			Value:  &value,
+			Labels: labels,
+		}
+		metrics = append(metrics, &metric)
+	}
+	for key, delta := range store.MetricsData.Counter {
+		name, labels := models.ParseSeriesKey(key)
 		metric := models.Metric{
-			ID:    name,
-			MType: models.Gauge,
-			Value: &value,
+			ID:     name,
+			MType:  models.Counter,
+			Delta:  &delta,
+			Labels: labels,
 		}
 		metrics = append(metrics, &metric)
 	}
-	for name, delta := range store.MetricsData.Counter {
+	for key, histogram := range store.MetricsData.Histogram {
+		name, labels := models.ParseSeriesKey(key)
 		metric := models.Metric{
-			ID:    name,
-			MType: models.Counter,
-			Delta: &delta,
+			ID:        name,
+			MType:     models.Histogram,
+			Histogram: &histogram,
+			Labels:    labels,
 		}
 		metrics = append(metrics, &metric)
 	}
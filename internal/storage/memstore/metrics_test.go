@@ -2,6 +2,7 @@ package memstore
 
 import (
 	"context"
+	"sync"
 	"testing"
 
 	"github.com/eac0de/getmetrics/internal/models"
@@ -121,6 +122,7 @@ func TestGetMetric(t *testing.T) {
 				context.Background(),
 				test.metricName,
 				test.metricType,
+				nil,
 			)
 			if metric != nil {
 				assert.Equal(t, metric.ID, test.metric.ID)
@@ -151,3 +153,139 @@ func TestListAllMetrics(t *testing.T) {
 	})
 
 }
+
+func TestMetricLabels(t *testing.T) {
+	store := New()
+	save := func(labels models.Labels, value float64) {
+		err := store.SaveMetric(context.Background(), models.Metric{
+			ID:     "HeapAlloc",
+			MType:  models.Gauge,
+			Value:  &value,
+			Labels: labels,
+		})
+		assert.NoError(t, err)
+	}
+	save(nil, 1)
+	save(models.Labels{"host": "host1"}, 2)
+	save(models.Labels{"host": "host2"}, 3)
+
+	metric, err := store.GetMetric(context.Background(), "HeapAlloc", models.Gauge, models.Labels{"host": "host1"})
+	assert.NoError(t, err)
+	assert.Equal(t, float64(2), *metric.Value)
+	metric, err = store.GetMetric(context.Background(), "HeapAlloc", models.Gauge, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(1), *metric.Value)
+	_, err = store.GetMetric(context.Background(), "HeapAlloc", models.Gauge, models.Labels{"host": "host3"})
+	assert.Error(t, err)
+
+	metrics, err := store.ListAllMetrics(context.Background())
+	assert.NoError(t, err)
+	values := map[string]float64{}
+	for _, metric := range metrics {
+		values[metric.SeriesKey()] = *metric.Value
+	}
+	assert.Equal(t, map[string]float64{
+		"HeapAlloc":               1,
+		`HeapAlloc{host="host1"}`: 2,
+		`HeapAlloc{host="host2"}`: 3,
+	}, values)
+}
+
+func TestHistogramMetric(t *testing.T) {
+	store := New()
+	histogram := &models.HistogramValue{Bounds: []float64{1}, Counts: []uint64{1, 2}, Sum: 4, Count: 3}
+	err := store.SaveMetric(context.Background(), models.Metric{ID: "Latency", MType: models.Histogram, Histogram: histogram})
+	assert.NoError(t, err)
+
+	metric, err := store.GetMetric(context.Background(), "Latency", models.Histogram, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, histogram, metric.Histogram)
+
+	metrics, err := store.ListAllMetrics(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 1)
+	assert.Equal(t, models.Histogram, metrics[0].MType)
+}
+
+func TestIncrementCountersConcurrent(t *testing.T) {
+	store := New()
+	const workers, increments = 16, 100
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < increments; j++ {
+				delta := int64(1)
+				_, err := store.IncrementCounters(context.Background(), []models.Metric{
+					{ID: "PollCount", MType: models.Counter, Delta: &delta},
+				})
+				assert.NoError(t, err)
+				_, err = store.IncrementCounter(context.Background(), models.Metric{
+					ID: "PollCount", MType: models.Counter, Delta: &delta,
+				})
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	metric, err := store.GetMetric(context.Background(), "PollCount", models.Counter, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2*workers*increments), *metric.Delta)
+}
+
+func TestUpdateMetrics(t *testing.T) {
+	store := New()
+	ctx := context.Background()
+	value, delta := 1.5, int64(2)
+	histogram := func(bounds []float64, counts ...uint64) *models.HistogramValue {
+		h := &models.HistogramValue{Bounds: bounds, Counts: counts}
+		for _, count := range counts {
+			h.Count += count
+		}
+		return h
+	}
+	batch := []models.Metric{
+		{ID: "Alloc", MType: models.Gauge, Value: &value},
+		{ID: "PollCount", MType: models.Counter, Delta: &delta},
+		{ID: "Latency", MType: models.Histogram, Histogram: histogram([]float64{1}, 1, 2)},
+	}
+	_, err := store.UpdateMetrics(ctx, batch)
+	assert.NoError(t, err)
+	result, err := store.UpdateMetrics(ctx, batch)
+	assert.NoError(t, err)
+	assert.Equal(t, 1.5, *result[0].Value)
+	assert.Equal(t, int64(4), *result[1].Delta)
+	assert.Equal(t, histogram([]float64{1}, 2, 4), result[2].Histogram)
+
+	// При изменении границ корзин сохраненная гистограмма заменяется
+	changed := histogram([]float64{2}, 1, 0)
+	result, err = store.UpdateMetrics(ctx, []models.Metric{{ID: "Latency", MType: models.Histogram, Histogram: changed}})
+	assert.NoError(t, err)
+	assert.Equal(t, changed, result[0].Histogram)
+}
+
+func TestUpdateMetricsConcurrentHistograms(t *testing.T) {
+	store := New()
+	const workers, updates = 16, 100
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < updates; j++ {
+				histogram := &models.HistogramValue{Bounds: []float64{1}, Counts: []uint64{1, 0}, Sum: 0.5, Count: 1}
+				_, err := store.UpdateMetrics(context.Background(), []models.Metric{
+					{ID: "Latency", MType: models.Histogram, Histogram: histogram},
+				})
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	metric, err := store.GetMetric(context.Background(), "Latency", models.Histogram, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(workers*updates), metric.Histogram.Count)
+}
@@ -5,39 +5,58 @@ import (
 	"database/sql"
 	stderr "errors"
 	"net/http"
+	"sort"
+	"time"
 
 	"github.com/eac0de/getmetrics/internal/models"
 	"github.com/eac0de/getmetrics/pkg/errors"
+	"github.com/eac0de/getmetrics/pkg/retry"
+)
+
+const (
+	// historyRetention - время хранения значений в metrics_history.
+	historyRetention = 7 * 24 * time.Hour
+	// historyPruneInterval - период удаления устаревших значений истории.
+	historyPruneInterval = time.Hour
 )
 
 func (store *PostgresqlStore) SaveMetric(ctx context.Context, metric models.Metric) error {
-	query := `
-	INSERT INTO metrics (id, type, delta, value)
-	VALUES ($1, $2, $3, $4)
-	ON CONFLICT (id, type)
-	DO UPDATE SET delta = $3, value = $4
-	`
-	_, err := store.ExecContext(ctx, query, metric.ID, metric.MType, metric.Delta, metric.Value)
-	if err != nil {
-		return err
-	}
-	return nil
+	return store.SaveMetrics(ctx, []models.Metric{metric})
 }
 
 func (store *PostgresqlStore) SaveMetrics(ctx context.Context, metricsList []models.Metric) error {
+	return store.retryPolicy.Do(ctx, func() error {
+		return store.saveMetrics(ctx, metricsList)
+	}, retry.IsRetriablePg)
+}
+
+func (store *PostgresqlStore) saveMetrics(ctx context.Context, metricsList []models.Metric) error {
 	tx, err := store.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	var errsList []error
 	query := `
-	INSERT INTO metrics (id, type, delta, value)
-	VALUES ($1, $2, $3, $4)
-	ON CONFLICT (id, type)
-	DO UPDATE SET delta = $3, value = $4
+	INSERT INTO metrics (id, type, delta, value, labels, histogram)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	ON CONFLICT (id, type, labels)
+	DO UPDATE SET delta = $3, value = $4, histogram = $6
+	`
+	historyQuery := `
+	INSERT INTO metrics_history (id, type, value, labels)
+	VALUES ($1, $2, COALESCE($4::DOUBLE PRECISION, $3::BIGINT::DOUBLE PRECISION), $5)
 	`
 	for _, metric := range metricsList {
-		_, err = tx.ExecContext(ctx, query, metric.ID, metric.MType, metric.Delta, metric.Value)
+		_, err = tx.ExecContext(ctx, query, metric.ID, metric.MType, metric.Delta, metric.Value, metric.Labels, metric.Histogram)
+		if err != nil {
+			errsList = append(errsList, err)
+			continue
+		}
+		// История хранится только для метрик с одним числовым значением
+		if metric.MType == models.Histogram {
+			continue
+		}
+		_, err = tx.ExecContext(ctx, historyQuery, metric.ID, metric.MType, metric.Delta, metric.Value, metric.Labels)
 		if err != nil {
 			errsList = append(errsList, err)
 		}
@@ -49,14 +68,193 @@ func (store *PostgresqlStore) SaveMetrics(ctx context.Context, metricsList []mod
 			return err
 		}
 	}
-	tx.Commit()
-	return nil
+	return tx.Commit()
+}
+
+func (store *PostgresqlStore) IncrementCounter(ctx context.Context, metric models.Metric) (*models.Metric, error) {
+	metricsList, err := store.IncrementCounters(ctx, []models.Metric{metric})
+	if err != nil {
+		return nil, err
+	}
+	return &metricsList[0], nil
+}
+
+func (store *PostgresqlStore) IncrementCounters(ctx context.Context, metricsList []models.Metric) ([]models.Metric, error) {
+	var result []models.Metric
+	err := store.retryPolicy.Do(ctx, func() error {
+		var err error
+		result, err = store.incrementCounters(ctx, metricsList)
+		return err
+	}, retry.IsRetriablePg)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (store *PostgresqlStore) incrementCounters(ctx context.Context, metricsList []models.Metric) ([]models.Metric, error) {
+	tx, err := store.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	// Приращение выполняется на стороне базы, поэтому параллельные обновления не теряются
+	query := `
+	INSERT INTO metrics (id, type, delta, labels)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (id, type, labels)
+	DO UPDATE SET delta = metrics.delta + EXCLUDED.delta
+	RETURNING delta
+	`
+	historyQuery := `
+	INSERT INTO metrics_history (id, type, value, labels)
+	VALUES ($1, $2, $3, $4)
+	`
+	// Строки блокируются в одном порядке, чтобы параллельные пакеты не взаимоблокировались
+	sorted := make([]models.Metric, len(metricsList))
+	copy(sorted, metricsList)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].SeriesKey() < sorted[j].SeriesKey()
+	})
+	result := make([]models.Metric, 0, len(sorted))
+	for _, metric := range sorted {
+		var delta int64
+		err = tx.QueryRowContext(ctx, query, metric.ID, models.Counter, *metric.Delta, metric.Labels).Scan(&delta)
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.ExecContext(ctx, historyQuery, metric.ID, models.Counter, float64(delta), metric.Labels)
+		if err != nil {
+			return nil, err
+		}
+		metric.Delta = &delta
+		result = append(result, metric)
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// UpdateMetrics применяет пакет метрик в одной транзакции: значения gauge записываются,
+// приращения counter и histogram прибавляются к сохраненным значениям.
+func (store *PostgresqlStore) UpdateMetrics(ctx context.Context, metricsList []models.Metric) ([]models.Metric, error) {
+	var result []models.Metric
+	err := store.retryPolicy.Do(ctx, func() error {
+		var err error
+		result, err = store.updateMetrics(ctx, metricsList)
+		return err
+	}, retry.IsRetriablePg)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (store *PostgresqlStore) updateMetrics(ctx context.Context, metricsList []models.Metric) ([]models.Metric, error) {
+	tx, err := store.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	gaugeQuery := `
+	INSERT INTO metrics (id, type, value, labels)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (id, type, labels)
+	DO UPDATE SET value = $3
+	`
+	counterQuery := `
+	INSERT INTO metrics (id, type, delta, labels)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (id, type, labels)
+	DO UPDATE SET delta = metrics.delta + EXCLUDED.delta
+	RETURNING delta
+	`
+	historyQuery := `
+	INSERT INTO metrics_history (id, type, value, labels)
+	VALUES ($1, $2, $3, $4)
+	`
+	// Строки блокируются в одном порядке, чтобы параллельные пакеты не взаимоблокировались
+	sorted := make([]models.Metric, len(metricsList))
+	copy(sorted, metricsList)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].SeriesKey() != sorted[j].SeriesKey() {
+			return sorted[i].SeriesKey() < sorted[j].SeriesKey()
+		}
+		return sorted[i].MType < sorted[j].MType
+	})
+	result := make([]models.Metric, 0, len(sorted))
+	for _, metric := range sorted {
+		switch metric.MType {
+		case models.Gauge:
+			_, err = tx.ExecContext(ctx, gaugeQuery, metric.ID, metric.MType, *metric.Value, metric.Labels)
+			if err != nil {
+				return nil, err
+			}
+			_, err = tx.ExecContext(ctx, historyQuery, metric.ID, metric.MType, *metric.Value, metric.Labels)
+		case models.Counter:
+			var delta int64
+			err = tx.QueryRowContext(ctx, counterQuery, metric.ID, metric.MType, *metric.Delta, metric.Labels).Scan(&delta)
+			if err != nil {
+				return nil, err
+			}
+			metric.Delta = &delta
+			_, err = tx.ExecContext(ctx, historyQuery, metric.ID, metric.MType, float64(delta), metric.Labels)
+		case models.Histogram:
+			metric.Histogram, err = updateHistogram(ctx, tx, metric)
+		}
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, metric)
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// updateHistogram прибавляет гистограмму метрики к сохраненной и возвращает результат.
+func updateHistogram(ctx context.Context, tx *sql.Tx, metric models.Metric) (*models.HistogramValue, error) {
+	// Новая гистограмма вставляется, только если ряда еще нет. Иначе сохраненная
+	// гистограмма блокируется до конца транзакции и складывается с новой
+	insertQuery := `
+	INSERT INTO metrics (id, type, labels, histogram)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (id, type, labels) DO NOTHING
+	`
+	selectQuery := "SELECT histogram FROM metrics WHERE type=$1 AND id=$2 AND labels=$3 FOR UPDATE"
+	updateQuery := "UPDATE metrics SET histogram=$4 WHERE type=$1 AND id=$2 AND labels=$3"
+	res, err := tx.ExecContext(ctx, insertQuery, metric.ID, metric.MType, metric.Labels, metric.Histogram)
+	if err != nil {
+		return nil, err
+	}
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if inserted > 0 {
+		return metric.Histogram, nil
+	}
+	var old models.HistogramValue
+	err = tx.QueryRowContext(ctx, selectQuery, metric.MType, metric.ID, metric.Labels).Scan(&old)
+	if err != nil {
+		return nil, err
+	}
+	histogram := old.Accumulate(metric.Histogram)
+	_, err = tx.ExecContext(ctx, updateQuery, metric.MType, metric.ID, metric.Labels, histogram)
+	if err != nil {
+		return nil, err
+	}
+	return histogram, nil
 }
 
-func (store *PostgresqlStore) GetMetric(ctx context.Context, metricName string, metricType string) (*models.Metric, error) {
-	query := "SELECT id, type, delta, value FROM metrics WHERE type=$1 AND id=$2"
-	var metric *models.Metric
-	err := store.GetContext(ctx, metric, query)
+func (store *PostgresqlStore) GetMetric(ctx context.Context, metricName string, metricType string, labels models.Labels) (*models.Metric, error) {
+	query := "SELECT id, type, delta, value, labels, histogram FROM metrics WHERE type=$1 AND id=$2 AND labels=$3"
+	var metric models.Metric
+	err := store.GetContext(ctx, &metric, query, metricType, metricName, labels)
 	if err != nil {
 		if stderr.Is(err, sql.ErrNoRows) {
 			return nil, errors.NewErrorWithHTTPStatus(
@@ -67,15 +265,103 @@ func (store *PostgresqlStore) GetMetric(ctx context.Context, metricName string,
 		}
 		return nil, err
 	}
-	return metric, nil
+	return &metric, nil
 }
 
 func (store *PostgresqlStore) ListAllMetrics(ctx context.Context) ([]*models.Metric, error) {
 	var metricsList []*models.Metric
-	query := "SELECT id, type, delta, value FROM metrics"
+	query := "SELECT id, type, delta, value, labels, histogram FROM metrics"
 	err := store.SelectContext(ctx, &metricsList, query)
 	if err != nil {
 		return nil, err
 	}
 	return metricsList, nil
 }
+
+func (store *PostgresqlStore) QueryRange(ctx context.Context, metricName string, metricType string, labels models.Labels, from time.Time, to time.Time) ([]models.Sample, error) {
+	samples := []models.Sample{}
+	query := `
+	SELECT ts, value FROM metrics_history
+	WHERE id=$1 AND type=$2 AND labels=$3 AND ts BETWEEN $4 AND $5
+	ORDER BY ts
+	`
+	err := store.SelectContext(ctx, &samples, query, metricName, metricType, labels, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return samples, nil
+}
+
+func (store *PostgresqlStore) QueryRanges(ctx context.Context, series []models.Metric, from time.Time, to time.Time) ([][]models.Sample, error) {
+	result := make([][]models.Sample, len(series))
+	if len(series) == 0 {
+		return result, nil
+	}
+	ids := make([]string, len(series))
+	types := make([]string, len(series))
+	labels := make([]string, len(series))
+	index := make(map[string]int, len(series))
+	for i, metric := range series {
+		value, err := metric.Labels.Value()
+		if err != nil {
+			return nil, err
+		}
+		ids[i], types[i], labels[i] = metric.ID, metric.MType, value.(string)
+		index[metric.MType+":"+metric.SeriesKey()] = i
+		result[i] = []models.Sample{}
+	}
+	query := `
+	SELECT h.id, h.type, h.labels, h.ts, h.value FROM metrics_history h
+	JOIN unnest($1::TEXT[], $2::TEXT[], $3::TEXT[]) AS s(id, type, labels)
+	ON h.id = s.id AND h.type = s.type AND h.labels = s.labels::JSONB
+	WHERE h.ts BETWEEN $4 AND $5
+	ORDER BY h.ts
+	`
+	var rows []struct {
+		ID     string        `db:"id"`
+		MType  string        `db:"type"`
+		Labels models.Labels `db:"labels"`
+		models.Sample
+	}
+	err := store.SelectContext(ctx, &rows, query, ids, types, labels, from, to)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		i, ok := index[row.MType+":"+models.SeriesKey(row.ID, row.Labels)]
+		if ok {
+			result[i] = append(result[i], row.Sample)
+		}
+	}
+	return result, nil
+}
+
+// PruneHistory удаляет из истории значения, записанные раньше before, и возвращает их количество.
+func (store *PostgresqlStore) PruneHistory(ctx context.Context, before time.Time) (int64, error) {
+	res, err := store.ExecContext(ctx, "DELETE FROM metrics_history WHERE ts < $1", before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// StartPruningHistory удаляет значения истории старше historyRetention при запуске
+// и затем каждые historyPruneInterval до отмены ctx.
+func (store *PostgresqlStore) StartPruningHistory(ctx context.Context) {
+	ticker := time.NewTicker(historyPruneInterval)
+	defer ticker.Stop()
+	for {
+		deleted, err := store.PruneHistory(ctx, time.Now().Add(-historyRetention))
+		if err != nil {
+			store.logger.Error("Metrics history pruning error", "error", err)
+		} else if deleted > 0 {
+			store.logger.Debug("Metrics history is pruned", "deleted", deleted)
+		}
+		select {
+		case <-ctx.Done():
+			store.logger.Info("StartPruningHistory goroutine is shutting down")
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -1 +1,121 @@
 package pgstore
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eac0de/getmetrics/internal/models"
+	"github.com/eac0de/getmetrics/pkg/retry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestStore подключается к базе из TEST_DATABASE_DSN, без нее тест пропускается.
+func newTestStore(t *testing.T) *PostgresqlStore {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+	// Миграции ищутся относительно корня репозитория
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir("../../.."))
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	store, err := New(context.Background(), dsn, retry.Policy{}, slog.Default())
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestIncrementCountersConcurrent(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	labels := models.Labels{"test": t.Name()}
+	_, err := store.ExecContext(ctx, "DELETE FROM metrics WHERE labels=$1", labels)
+	require.NoError(t, err)
+
+	const workers, increments = 8, 25
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < increments; j++ {
+				delta := int64(1)
+				_, err := store.IncrementCounters(ctx, []models.Metric{
+					{ID: "PollCount", MType: models.Counter, Delta: &delta, Labels: labels},
+					{ID: "RetryCount", MType: models.Counter, Delta: &delta, Labels: labels},
+				})
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, name := range []string{"PollCount", "RetryCount"} {
+		metric, err := store.GetMetric(ctx, name, models.Counter, labels)
+		require.NoError(t, err)
+		assert.Equal(t, int64(workers*increments), *metric.Delta)
+	}
+}
+
+func TestUpdateMetricsConcurrent(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	labels := models.Labels{"test": t.Name()}
+	_, err := store.ExecContext(ctx, "DELETE FROM metrics WHERE labels=$1", labels)
+	require.NoError(t, err)
+
+	const workers, updates = 8, 25
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < updates; j++ {
+				delta, value := int64(1), float64(j)
+				histogram := &models.HistogramValue{Bounds: []float64{1}, Counts: []uint64{1, 0}, Sum: 0.5, Count: 1}
+				_, err := store.UpdateMetrics(ctx, []models.Metric{
+					{ID: "Alloc", MType: models.Gauge, Value: &value, Labels: labels},
+					{ID: "Latency", MType: models.Histogram, Histogram: histogram, Labels: labels},
+					{ID: "PollCount", MType: models.Counter, Delta: &delta, Labels: labels},
+				})
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	metric, err := store.GetMetric(ctx, "PollCount", models.Counter, labels)
+	require.NoError(t, err)
+	assert.Equal(t, int64(workers*updates), *metric.Delta)
+	metric, err = store.GetMetric(ctx, "Latency", models.Histogram, labels)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(workers*updates), metric.Histogram.Count)
+}
+
+func TestPruneHistory(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	labels := models.Labels{"test": t.Name()}
+	_, err := store.ExecContext(ctx, "DELETE FROM metrics_history WHERE labels=$1", labels)
+	require.NoError(t, err)
+	now := time.Now()
+	for _, ts := range []time.Time{now.Add(-2 * historyRetention), now} {
+		_, err = store.ExecContext(ctx,
+			"INSERT INTO metrics_history (id, type, ts, value, labels) VALUES ($1, $2, $3, $4, $5)",
+			"Alloc", models.Gauge, ts, 1, labels)
+		require.NoError(t, err)
+	}
+
+	_, err = store.PruneHistory(ctx, now.Add(-historyRetention))
+	require.NoError(t, err)
+	samples, err := store.QueryRange(ctx, "Alloc", models.Gauge, labels, now.Add(-3*historyRetention), now.Add(time.Second))
+	require.NoError(t, err)
+	assert.Len(t, samples, 1)
+}
@@ -2,8 +2,11 @@ package pgstore
 
 import (
 	"context"
+	"log/slog"
 	"time"
 
+	"github.com/eac0de/getmetrics/pkg/logging"
+	"github.com/eac0de/getmetrics/pkg/retry"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/jmoiron/sqlx"
 
@@ -12,14 +15,16 @@ import (
 
 type PostgresqlStore struct {
 	*sqlx.DB
+	retryPolicy retry.Policy
+	logger      *slog.Logger
 }
 
-func New(ctx context.Context, dataSourceName string) (*PostgresqlStore, error) {
+func New(ctx context.Context, dataSourceName string, retryPolicy retry.Policy, logger *slog.Logger) (*PostgresqlStore, error) {
 	db, err := sqlx.ConnectContext(ctx, "pgx", dataSourceName)
 	if err != nil {
 		return nil, err
 	}
-	store := &PostgresqlStore{db}
+	store := &PostgresqlStore{DB: db, retryPolicy: retryPolicy, logger: logger}
 
 	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
@@ -40,6 +45,7 @@ func (store *PostgresqlStore) Migrate(ctx context.Context) error {
 	// if err := goose.ResetContext(ctx, store.DB.DB, migrationsDir); err != nil {
 	// 	return err
 	// }
+	goose.SetLogger(logging.PrintfLogger{Logger: store.logger, Level: slog.LevelInfo})
 	if err := goose.UpContext(ctx, store.DB.DB, migrationsDir); err != nil {
 		return err
 	}
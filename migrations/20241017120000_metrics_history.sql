@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE
+    metrics_history (
+        id TEXT NOT NULL,
+        type TEXT NOT NULL,
+        ts TIMESTAMPTZ NOT NULL DEFAULT now(),
+        value DOUBLE PRECISION NOT NULL
+    );
+
+CREATE INDEX metrics_history_id_type_ts_idx ON metrics_history (id, type, ts);
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE metrics_history;
+
+-- +goose StatementEnd
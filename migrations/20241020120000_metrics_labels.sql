@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE metrics ADD COLUMN labels JSONB NOT NULL DEFAULT '{}';
+
+DROP INDEX metrics_unique_idx;
+
+CREATE UNIQUE INDEX metrics_unique_idx ON metrics (id, type, labels);
+
+ALTER TABLE metrics_history ADD COLUMN labels JSONB NOT NULL DEFAULT '{}';
+
+DROP INDEX metrics_history_id_type_ts_idx;
+
+CREATE INDEX metrics_history_id_type_labels_ts_idx ON metrics_history (id, type, labels, ts);
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX metrics_history_id_type_labels_ts_idx;
+
+ALTER TABLE metrics_history DROP COLUMN labels;
+
+CREATE INDEX metrics_history_id_type_ts_idx ON metrics_history (id, type, ts);
+
+DELETE FROM metrics WHERE labels <> '{}';
+
+DROP INDEX metrics_unique_idx;
+
+ALTER TABLE metrics DROP COLUMN labels;
+
+CREATE UNIQUE INDEX metrics_unique_idx ON metrics (id, type);
+
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE metrics ADD COLUMN histogram JSONB;
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM metrics WHERE type = 'histogram';
+
+ALTER TABLE metrics DROP COLUMN histogram;
+
+-- +goose StatementEnd
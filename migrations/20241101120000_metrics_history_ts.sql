@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE INDEX metrics_history_ts_idx ON metrics_history (ts);
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX metrics_history_ts_idx;
+
+-- +goose StatementEnd
@@ -7,6 +7,7 @@ package mocks
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	models "github.com/eac0de/getmetrics/internal/models"
 	gomock "github.com/golang/mock/gomock"
@@ -73,18 +74,48 @@ func (m *MockIMetricsStore) EXPECT() *MockIMetricsStoreMockRecorder {
 }
 
 // GetMetric mocks base method.
-func (m *MockIMetricsStore) GetMetric(arg0 context.Context, arg1, arg2 string) (*models.Metric, error) {
+func (m *MockIMetricsStore) GetMetric(arg0 context.Context, arg1, arg2 string, arg3 models.Labels) (*models.Metric, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetMetric", arg0, arg1, arg2)
+	ret := m.ctrl.Call(m, "GetMetric", arg0, arg1, arg2, arg3)
 	ret0, _ := ret[0].(*models.Metric)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetMetric indicates an expected call of GetMetric.
-func (mr *MockIMetricsStoreMockRecorder) GetMetric(arg0, arg1, arg2 interface{}) *gomock.Call {
+func (mr *MockIMetricsStoreMockRecorder) GetMetric(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMetric", reflect.TypeOf((*MockIMetricsStore)(nil).GetMetric), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMetric", reflect.TypeOf((*MockIMetricsStore)(nil).GetMetric), arg0, arg1, arg2, arg3)
+}
+
+// IncrementCounter mocks base method.
+func (m *MockIMetricsStore) IncrementCounter(arg0 context.Context, arg1 models.Metric) (*models.Metric, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IncrementCounter", arg0, arg1)
+	ret0, _ := ret[0].(*models.Metric)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IncrementCounter indicates an expected call of IncrementCounter.
+func (mr *MockIMetricsStoreMockRecorder) IncrementCounter(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementCounter", reflect.TypeOf((*MockIMetricsStore)(nil).IncrementCounter), arg0, arg1)
+}
+
+// IncrementCounters mocks base method.
+func (m *MockIMetricsStore) IncrementCounters(arg0 context.Context, arg1 []models.Metric) ([]models.Metric, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IncrementCounters", arg0, arg1)
+	ret0, _ := ret[0].([]models.Metric)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IncrementCounters indicates an expected call of IncrementCounters.
+func (mr *MockIMetricsStoreMockRecorder) IncrementCounters(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementCounters", reflect.TypeOf((*MockIMetricsStore)(nil).IncrementCounters), arg0, arg1)
 }
 
 // ListAllMetrics mocks base method.
@@ -102,6 +133,36 @@ func (mr *MockIMetricsStoreMockRecorder) ListAllMetrics(arg0 interface{}) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAllMetrics", reflect.TypeOf((*MockIMetricsStore)(nil).ListAllMetrics), arg0)
 }
 
+// QueryRange mocks base method.
+func (m *MockIMetricsStore) QueryRange(arg0 context.Context, arg1, arg2 string, arg3 models.Labels, arg4, arg5 time.Time) ([]models.Sample, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "QueryRange", arg0, arg1, arg2, arg3, arg4, arg5)
+	ret0, _ := ret[0].([]models.Sample)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// QueryRange indicates an expected call of QueryRange.
+func (mr *MockIMetricsStoreMockRecorder) QueryRange(arg0, arg1, arg2, arg3, arg4, arg5 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryRange", reflect.TypeOf((*MockIMetricsStore)(nil).QueryRange), arg0, arg1, arg2, arg3, arg4, arg5)
+}
+
+// QueryRanges mocks base method.
+func (m *MockIMetricsStore) QueryRanges(arg0 context.Context, arg1 []models.Metric, arg2, arg3 time.Time) ([][]models.Sample, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "QueryRanges", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].([][]models.Sample)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// QueryRanges indicates an expected call of QueryRanges.
+func (mr *MockIMetricsStoreMockRecorder) QueryRanges(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryRanges", reflect.TypeOf((*MockIMetricsStore)(nil).QueryRanges), arg0, arg1, arg2, arg3)
+}
+
 // SaveMetric mocks base method.
 func (m *MockIMetricsStore) SaveMetric(arg0 context.Context, arg1 models.Metric) error {
 	m.ctrl.T.Helper()
@@ -129,3 +190,18 @@ func (mr *MockIMetricsStoreMockRecorder) SaveMetrics(arg0, arg1 interface{}) *go
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveMetrics", reflect.TypeOf((*MockIMetricsStore)(nil).SaveMetrics), arg0, arg1)
 }
+
+// UpdateMetrics mocks base method.
+func (m *MockIMetricsStore) UpdateMetrics(arg0 context.Context, arg1 []models.Metric) ([]models.Metric, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateMetrics", arg0, arg1)
+	ret0, _ := ret[0].([]models.Metric)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateMetrics indicates an expected call of UpdateMetrics.
+func (mr *MockIMetricsStoreMockRecorder) UpdateMetrics(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateMetrics", reflect.TypeOf((*MockIMetricsStore)(nil).UpdateMetrics), arg0, arg1)
+}
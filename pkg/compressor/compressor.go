@@ -74,6 +74,19 @@ func (cw *compressWriter) WriteHeader(statusCode int) {
 	cw.w.WriteHeader(statusCode)
 }
 
+// Flush отправляет клиенту данные, накопленные в gzip.Writer и исходном http.ResponseWriter.
+func (cw *compressWriter) Flush() {
+	if cw.gzipEnabled {
+		cw.zw.Flush()
+	}
+	http.NewResponseController(cw.w).Flush()
+}
+
+// Unwrap возвращает исходный http.ResponseWriter для http.ResponseController.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.w
+}
+
 // Close закрывает gzip.Writer, если сжатие было включено.
 func (cw *compressWriter) Close() error {
 	if cw.gzipEnabled {
@@ -97,3 +97,25 @@ func TestNewCompressReaderError(t *testing.T) {
 		t.Error("Expected error for invalid gzip data, got nil")
 	}
 }
+
+// TestFlush проверяет, что Flush отправляет сжатые данные, не дожидаясь Close.
+func TestFlush(t *testing.T) {
+	rec := httptest.NewRecorder()
+	cw := NewCompressWriter(rec, "text/plain")
+	cw.Header().Set("Content-Type", "text/plain")
+	cw.Write([]byte("partial"))
+	if err := http.NewResponseController(cw).Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if !rec.Flushed {
+		t.Fatal("expected underlying writer to be flushed")
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(rec.Body.Bytes()))
+	if err != nil {
+		t.Fatalf("gzip.NewReader: %v", err)
+	}
+	data := make([]byte, len("partial"))
+	if _, err := io.ReadFull(zr, data); err != nil || string(data) != "partial" {
+		t.Fatalf("expected flushed data %q, got %q (%v)", "partial", data, err)
+	}
+}
@@ -0,0 +1,171 @@
+// Package encryptor предоставляет функции для гибридного шифрования данных с использованием RSA и AES.
+//
+// Данные шифруются симметричным ключом AES-256-GCM, который генерируется для каждого сообщения.
+// Сам симметричный ключ шифруется открытым ключом RSA (RSA-OAEP с SHA-256) и передается вместе с данными.
+// Основные функции пакета включают:
+// - Загрузку открытого и закрытого ключей RSA из PEM-файлов.
+// - Шифрование и расшифровку данных.
+// - Генерацию пары ключей в формате PEM.
+package encryptor
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+)
+
+// EncryptedKeyHeader - HTTP-заголовок, в котором передается зашифрованный симметричный ключ.
+const EncryptedKeyHeader = "X-Encrypted-Key"
+
+// aesKeySize - размер симметричного ключа в байтах (AES-256).
+const aesKeySize = 32
+
+// LoadPublicKey читает открытый ключ RSA из PEM-файла.
+//
+// Поддерживаются форматы PKIX ("PUBLIC KEY") и PKCS#1 ("RSA PUBLIC KEY").
+func LoadPublicKey(path string) (*rsa.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse public key %s: %w", path, err)
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key %s is not an RSA key", path)
+	}
+	return rsaKey, nil
+}
+
+// LoadPrivateKey читает закрытый ключ RSA из PEM-файла.
+//
+// Поддерживаются форматы PKCS#1 ("RSA PRIVATE KEY") и PKCS#8 ("PRIVATE KEY").
+func LoadPrivateKey(path string) (*rsa.PrivateKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse private key %s: %w", path, err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key %s is not an RSA key", path)
+	}
+	return rsaKey, nil
+}
+
+// Encrypt шифрует данные новым симметричным ключом и шифрует этот ключ открытым ключом RSA.
+//
+// Возвращает зашифрованные данные (nonce и шифротекст) и зашифрованный ключ в кодировке base64,
+// предназначенный для передачи в заголовке EncryptedKeyHeader.
+func Encrypt(publicKey *rsa.PublicKey, data []byte) ([]byte, string, error) {
+	key := make([]byte, aesKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, "", fmt.Errorf("generate symmetric key: %w", err)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, "", fmt.Errorf("generate nonce: %w", err)
+	}
+	encryptedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, publicKey, key, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("encrypt symmetric key: %w", err)
+	}
+	ciphertext := gcm.Seal(nonce, nonce, data, nil)
+	return ciphertext, base64.StdEncoding.EncodeToString(encryptedKey), nil
+}
+
+// Decrypt расшифровывает симметричный ключ закрытым ключом RSA и расшифровывает им данные.
+//
+// Принимает зашифрованные данные в формате Encrypt и зашифрованный ключ в кодировке base64.
+func Decrypt(privateKey *rsa.PrivateKey, data []byte, encryptedKey string) ([]byte, error) {
+	wrappedKey, err := base64.StdEncoding.DecodeString(encryptedKey)
+	if err != nil {
+		return nil, fmt.Errorf("decode symmetric key: %w", err)
+	}
+	key, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, privateKey, wrappedKey, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt symmetric key: %w", err)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext is too short")
+	}
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt data: %w", err)
+	}
+	return plaintext, nil
+}
+
+// GenerateKeyPair генерирует пару ключей RSA указанного размера.
+//
+// Возвращает закрытый ключ в формате PKCS#1 и открытый ключ в формате PKIX, закодированные в PEM.
+func GenerateKeyPair(bits int) ([]byte, []byte, error) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		return nil, nil, err
+	}
+	publicKeyBytes, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	privatePEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+	})
+	publicPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: publicKeyBytes,
+	})
+	return privatePEM, publicPEM, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("create cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("create gcm: %w", err)
+	}
+	return gcm, nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in %s", path)
+	}
+	return block, nil
+}
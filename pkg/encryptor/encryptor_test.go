@@ -0,0 +1,58 @@
+package encryptor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeKeyPair(t *testing.T) (string, string) {
+	privatePEM, publicPEM, err := GenerateKeyPair(2048)
+	require.NoError(t, err)
+	dir := t.TempDir()
+	privatePath := filepath.Join(dir, "private.pem")
+	publicPath := filepath.Join(dir, "public.pem")
+	require.NoError(t, os.WriteFile(privatePath, privatePEM, 0600))
+	require.NoError(t, os.WriteFile(publicPath, publicPEM, 0644))
+	return privatePath, publicPath
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	privatePath, publicPath := writeKeyPair(t)
+	publicKey, err := LoadPublicKey(publicPath)
+	require.NoError(t, err)
+	privateKey, err := LoadPrivateKey(privatePath)
+	require.NoError(t, err)
+
+	data := []byte("test data")
+	ciphertext, encryptedKey, err := Encrypt(publicKey, data)
+	require.NoError(t, err)
+	assert.NotEqual(t, data, ciphertext)
+
+	t.Run("success", func(t *testing.T) {
+		plaintext, err := Decrypt(privateKey, ciphertext, encryptedKey)
+		assert.NoError(t, err)
+		assert.Equal(t, data, plaintext)
+	})
+	t.Run("tampered data", func(t *testing.T) {
+		tampered := append([]byte{}, ciphertext...)
+		tampered[len(tampered)-1] ^= 0xff
+		_, err := Decrypt(privateKey, tampered, encryptedKey)
+		assert.Error(t, err)
+	})
+	t.Run("invalid key", func(t *testing.T) {
+		_, err := Decrypt(privateKey, ciphertext, "invalid key")
+		assert.Error(t, err)
+	})
+}
+
+func TestLoadRepositoryKeys(t *testing.T) {
+	publicKey, err := LoadPublicKey("../../public.pem")
+	require.NoError(t, err)
+	privateKey, err := LoadPrivateKey("../../server.key")
+	require.NoError(t, err)
+	assert.True(t, publicKey.Equal(&privateKey.PublicKey))
+}
@@ -5,11 +5,16 @@
 // Основные функции пакета включают:
 // - Создание ошибки с указанием сообщения и кода статуса.
 // - Получение сообщения и кода статуса из ошибки.
+// - Ответ на HTTP-запрос с сообщением ошибки и идентификатором запроса.
 package errors
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"net/http"
+
+	"github.com/eac0de/getmetrics/pkg/requestid"
 )
 
 // ErrorWithHTTPStatus представляет ошибку с сопутствующим HTTP-статусом.
@@ -52,3 +57,20 @@ func GetMessageAndStatusCode(err error) (string, int) {
 	}
 	return err.Error(), http.StatusInternalServerError
 }
+
+// WithRequestID добавляет к сообщению об ошибке идентификатор запроса из ctx, если он есть,
+// чтобы ответ клиенту можно было сопоставить с записями лога сервера.
+func WithRequestID(ctx context.Context, msg string) string {
+	id := requestid.FromContext(ctx)
+	if id == "" {
+		return msg
+	}
+	return fmt.Sprintf("%s (request_id: %s)", msg, id)
+}
+
+// WriteHTTPError отвечает на запрос сообщением и HTTP-статусом ошибки
+// с идентификатором запроса из его контекста.
+func WriteHTTPError(w http.ResponseWriter, r *http.Request, err error) {
+	msg, statusCode := GetMessageAndStatusCode(err)
+	http.Error(w, WithRequestID(r.Context(), msg), statusCode)
+}
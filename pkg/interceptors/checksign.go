@@ -0,0 +1,90 @@
+// Package interceptors предоставляет перехватчики gRPC, аналогичные промежуточным обработчикам
+// HTTP из пакета middlewares.
+//
+// Основные функции пакета включают:
+// - Подпись запросов и проверку подписи HMAC на сервере.
+// - Сжатие запросов Gzip на клиенте.
+// - Логирование вызовов на сервере.
+package interceptors
+
+import (
+	"context"
+
+	"github.com/eac0de/getmetrics/pkg/hasher"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// SignMetadataKey - ключ метаданных gRPC, в котором передается HMAC-подпись сообщения.
+const SignMetadataKey = "hashsha256"
+
+// GetCheckSignInterceptor возвращает серверный перехватчик для проверки подписи HMAC запросов.
+//
+// Принимает секретный ключ в виде строки. Если ключ пуст, перехватчик не выполняет проверку.
+// В противном случае сравнивает подпись из метаданных hashsha256 с подписью сообщения запроса
+// и подписывает ответ, передавая подпись в заголовке hashsha256.
+//
+// Если подпись не соответствует, возвращает ошибку с кодом InvalidArgument.
+func GetCheckSignInterceptor(secretKey string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if secretKey == "" {
+			return handler(ctx, req)
+		}
+		var sign string
+		md, ok := metadata.FromIncomingContext(ctx)
+		if ok {
+			if values := md.Get(SignMetadataKey); len(values) > 0 {
+				sign = values[0]
+			}
+		}
+		hash, err := hashMessage(req, secretKey)
+		if err != nil {
+			return nil, status.Error(codes.Internal, "Unable to read message")
+		}
+		if hash != sign {
+			return nil, status.Error(codes.InvalidArgument, "Signature does not match data")
+		}
+		resp, err := handler(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		respHash, err := hashMessage(resp, secretKey)
+		if err == nil {
+			grpc.SetHeader(ctx, metadata.Pairs(SignMetadataKey, respHash))
+		}
+		return resp, nil
+	}
+}
+
+// GetSignClientInterceptor возвращает клиентский перехватчик, который подписывает сообщение запроса
+// ключом secretKey и передает подпись в метаданных hashsha256.
+//
+// Если ключ пуст, запрос отправляется без подписи.
+func GetSignClientInterceptor(secretKey string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if secretKey != "" {
+			hash, err := hashMessage(req, secretKey)
+			if err != nil {
+				return err
+			}
+			ctx = metadata.AppendToOutgoingContext(ctx, SignMetadataKey, hash)
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// hashMessage вычисляет HMAC-подпись детерминированной сериализации сообщения.
+func hashMessage(message any, secretKey string) (string, error) {
+	protoMessage, ok := message.(proto.Message)
+	if !ok {
+		return "", status.Error(codes.Internal, "message is not a protobuf message")
+	}
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(protoMessage)
+	if err != nil {
+		return "", err
+	}
+	return hasher.HashSumToString(data, secretKey), nil
+}
@@ -0,0 +1,84 @@
+package interceptors
+
+import (
+	"context"
+	"testing"
+
+	"github.com/eac0de/getmetrics/pkg/hasher"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// Вспомогательная функция для генерации подписи сообщения
+func signMessage(t *testing.T, message proto.Message, secretKey string) string {
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hasher.HashSumToString(data, secretKey)
+}
+
+func TestCheckSignInterceptor(t *testing.T) {
+	secretKey := "mysecretkey"
+	req := wrapperspb.String("test body")
+	info := &grpc.UnaryServerInfo{FullMethod: "/test"}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return req, nil
+	}
+	tests := []struct {
+		name      string
+		secretKey string
+		sign      string
+		code      codes.Code
+	}{
+		{
+			name:      "valid signature",
+			secretKey: secretKey,
+			sign:      signMessage(t, req, secretKey),
+			code:      codes.OK,
+		},
+		{
+			name:      "invalid signature",
+			secretKey: secretKey,
+			sign:      "invalidsignature",
+			code:      codes.InvalidArgument,
+		},
+		{
+			name: "no secret key",
+			code: codes.OK,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(SignMetadataKey, test.sign))
+			_, err := GetCheckSignInterceptor(test.secretKey)(ctx, req, info, handler)
+			if code := status.Code(err); code != test.code {
+				t.Errorf("Expected code %v, got %v", test.code, code)
+			}
+		})
+	}
+}
+
+func TestSignClientInterceptor(t *testing.T) {
+	secretKey := "mysecretkey"
+	req := wrapperspb.String("test body")
+	var sign string
+	invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		if values := md.Get(SignMetadataKey); len(values) > 0 {
+			sign = values[0]
+		}
+		return nil
+	}
+	err := GetSignClientInterceptor(secretKey)(context.Background(), "/test", req, nil, nil, invoker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := signMessage(t, req, secretKey); sign != want {
+		t.Errorf("Expected sign %q, got %q", want, sign)
+	}
+}
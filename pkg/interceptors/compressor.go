@@ -0,0 +1,17 @@
+package interceptors
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding/gzip"
+)
+
+// GzipClientInterceptor - клиентский перехватчик, который сжимает запросы с помощью Gzip.
+//
+// Импорт пакета регистрирует компрессор gzip, поэтому сервер, использующий этот пакет,
+// принимает сжатые запросы и отвечает сжатыми ответами на них.
+func GzipClientInterceptor(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	opts = append(opts, grpc.UseCompressor(gzip.Name))
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
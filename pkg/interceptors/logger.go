@@ -0,0 +1,78 @@
+package interceptors
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/eac0de/getmetrics/pkg/requestid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// GetLoggerInterceptor возвращает серверный перехватчик для логирования вызовов.
+//
+// Для каждого вызова записывает в logger одну запись с полями method, code, duration,
+// remote_ip, request_id, bytes_in и bytes_out, а при ошибке - error с ее сообщением.
+// Размеры считаются по сообщениям protobuf.
+// Идентификатор запроса берется из контекста, поэтому RequestIDInterceptor должен стоять раньше.
+func GetLoggerInterceptor(logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		duration := time.Since(start)
+		var bytesIn, bytesOut int
+		if message, ok := req.(proto.Message); ok {
+			bytesIn = proto.Size(message)
+		}
+		if message, ok := resp.(proto.Message); ok && err == nil {
+			bytesOut = proto.Size(message)
+		}
+		attrs := []slog.Attr{
+			slog.String("method", info.FullMethod),
+			slog.String("code", status.Code(err).String()),
+			slog.Duration("duration", duration),
+			slog.String("remote_ip", remoteIP(ctx)),
+			slog.String("request_id", requestid.FromContext(ctx)),
+			slog.Int("bytes_in", bytesIn),
+			slog.Int("bytes_out", bytesOut),
+		}
+		if err != nil {
+			attrs = append(attrs, slog.String("error", status.Convert(err).Message()))
+		}
+		logger.LogAttrs(ctx, slog.LevelInfo, "gRPC call", attrs...)
+		return resp, err
+	}
+}
+
+// remoteIP возвращает адрес клиента из метаданных x-real-ip, а если их нет - адрес соединения.
+func remoteIP(ctx context.Context) string {
+	if ip := metadataValue(ctx, RealIPMetadataKey); ip != "" {
+		return ip
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+// metadataValue возвращает первое значение входящих метаданных по ключу или пустую строку.
+func metadataValue(ctx context.Context, key string) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
@@ -0,0 +1,44 @@
+package interceptors
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/eac0de/getmetrics/pkg/requestid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestLoggerInterceptor(t *testing.T) {
+	var buf bytes.Buffer
+	interceptor := GetLoggerInterceptor(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	req := wrapperspb.String("Alloc")
+	resp := wrapperspb.Double(1.5)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		RealIPMetadataKey, "10.0.0.5",
+		requestid.MetadataKey, "req-1",
+	))
+	info := &grpc.UnaryServerInfo{FullMethod: "/metrics.MetricsService/GetMetric"}
+	_, err := RequestIDInterceptor(ctx, req, info, func(ctx context.Context, req any) (any, error) {
+		return interceptor(ctx, req, info, func(ctx context.Context, req any) (any, error) {
+			return resp, nil
+		})
+	})
+	require.NoError(t, err)
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "/metrics.MetricsService/GetMetric", record["method"])
+	assert.Equal(t, "OK", record["code"])
+	assert.Equal(t, "10.0.0.5", record["remote_ip"])
+	assert.Equal(t, "req-1", record["request_id"])
+	assert.NotZero(t, record["bytes_in"])
+	assert.NotZero(t, record["bytes_out"])
+}
@@ -0,0 +1,24 @@
+package interceptors
+
+import (
+	"context"
+
+	"github.com/eac0de/getmetrics/pkg/requestid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// RequestIDInterceptor - серверный перехватчик, который назначает вызову идентификатор запроса.
+//
+// Идентификатор берется из метаданных x-request-id, а если их нет или значение недопустимо,
+// создается новый. Идентификатор сохраняется в контексте вызова и возвращается клиенту
+// в заголовке ответа x-request-id.
+func RequestIDInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	id := metadataValue(ctx, requestid.MetadataKey)
+	if !requestid.Valid(id) {
+		id = requestid.New()
+	}
+	// Ошибка возможна только вне gRPC-сервера, например при прямом вызове перехватчика
+	grpc.SetHeader(ctx, metadata.Pairs(requestid.MetadataKey, id))
+	return handler(requestid.NewContext(ctx, id), req)
+}
@@ -0,0 +1,30 @@
+package interceptors
+
+import (
+	"context"
+	"testing"
+
+	"github.com/eac0de/getmetrics/pkg/requestid"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestRequestIDInterceptor(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/metrics.MetricsService/UpdateMetrics"}
+	var gotID string
+	handler := func(ctx context.Context, req any) (any, error) {
+		gotID = requestid.FromContext(ctx)
+		return nil, nil
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(requestid.MetadataKey, "req-1"))
+	_, err := RequestIDInterceptor(ctx, nil, info, handler)
+	assert.NoError(t, err)
+	assert.Equal(t, "req-1", gotID)
+
+	_, err = RequestIDInterceptor(context.Background(), nil, info, handler)
+	assert.NoError(t, err)
+	assert.True(t, requestid.Valid(gotID))
+	assert.NotEqual(t, "req-1", gotID)
+}
@@ -0,0 +1,41 @@
+package interceptors
+
+import (
+	"context"
+	"net"
+	"slices"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// RealIPMetadataKey - ключ метаданных gRPC, в котором клиент передает свой IP-адрес.
+const RealIPMetadataKey = "x-real-ip"
+
+// GetTrustedSubnetInterceptor возвращает серверный перехватчик для проверки адреса клиента.
+//
+// Принимает доверенную подсеть и список полных имен методов, для которых выполняется проверка.
+// Если подсеть не задана, перехватчик не выполняет проверку. В противном случае проверяет,
+// что метаданные x-real-ip содержат IP-адрес из доверенной подсети.
+//
+// Если адрес отсутствует или не входит в подсеть, возвращает ошибку с кодом PermissionDenied.
+func GetTrustedSubnetInterceptor(trustedSubnet *net.IPNet, methods ...string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if trustedSubnet == nil || !slices.Contains(methods, info.FullMethod) {
+			return handler(ctx, req)
+		}
+		var ip net.IP
+		md, ok := metadata.FromIncomingContext(ctx)
+		if ok {
+			if values := md.Get(RealIPMetadataKey); len(values) > 0 {
+				ip = net.ParseIP(values[0])
+			}
+		}
+		if ip == nil || !trustedSubnet.Contains(ip) {
+			return nil, status.Error(codes.PermissionDenied, "Client address is not trusted")
+		}
+		return handler(ctx, req)
+	}
+}
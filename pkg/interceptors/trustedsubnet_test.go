@@ -0,0 +1,46 @@
+package interceptors
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestTrustedSubnetInterceptor(t *testing.T) {
+	_, trustedSubnet, err := net.ParseCIDR("192.168.1.0/24")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return req, nil
+	}
+	interceptor := GetTrustedSubnetInterceptor(trustedSubnet, "/update")
+	tests := []struct {
+		name   string
+		method string
+		realIP string
+		code   codes.Code
+	}{
+		{name: "trusted address", method: "/update", realIP: "192.168.1.10", code: codes.OK},
+		{name: "untrusted address", method: "/update", realIP: "10.0.0.1", code: codes.PermissionDenied},
+		{name: "missing address", method: "/update", code: codes.PermissionDenied},
+		{name: "unchecked method", method: "/get", code: codes.OK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			if test.realIP != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(RealIPMetadataKey, test.realIP))
+			}
+			_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: test.method}, handler)
+			if code := status.Code(err); code != test.code {
+				t.Errorf("Expected code %v, got %v", test.code, code)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+// Package logging предоставляет создание структурированного логгера log/slog по настройкам приложения.
+//
+// Этот пакет реализует выбор уровня логирования и формата вывода.
+// Основные функции пакета включают:
+// - Разбор уровня логирования из строки.
+// - Создание логгера с обработчиком JSON или текста.
+// - Добавление идентификатора запроса из контекста к каждой записи.
+// - Адаптер логгера для библиотек, ожидающих интерфейс Printf.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/eac0de/getmetrics/pkg/requestid"
+)
+
+const (
+	// FormatJSON - вывод записей в формате JSON, по одной на строку.
+	FormatJSON = "json"
+	// FormatText - вывод записей в формате key=value.
+	FormatText = "text"
+
+	// RequestIDKey - ключ атрибута с идентификатором запроса.
+	RequestIDKey = "request_id"
+)
+
+// ParseLevel преобразует строку в уровень логирования.
+//
+// Поддерживаются значения debug, info, warn (warning) и error без учета регистра.
+// Пустая строка соответствует уровню info.
+func ParseLevel(level string) (slog.Level, error) {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return slog.LevelInfo, fmt.Errorf("unknown log level: %s", level)
+}
+
+// New создает логгер, который пишет в w записи не ниже указанного уровня.
+//
+// Формат задается значениями FormatJSON или FormatText, пустая строка соответствует FormatJSON.
+// Записи, сделанные с контекстом запроса, содержат его идентификатор, см. NewContextHandler.
+func New(w io.Writer, level string, format string) (*slog.Logger, error) {
+	lvl, err := ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch strings.ToLower(format) {
+	case "", FormatJSON:
+		return slog.New(NewContextHandler(slog.NewJSONHandler(w, opts))), nil
+	case FormatText:
+		return slog.New(NewContextHandler(slog.NewTextHandler(w, opts))), nil
+	}
+	return nil, fmt.Errorf("unknown log format: %s", format)
+}
+
+// ContextHandler добавляет к записям атрибут request_id с идентификатором запроса из контекста.
+type ContextHandler struct {
+	slog.Handler
+}
+
+// NewContextHandler оборачивает обработчик h в ContextHandler.
+func NewContextHandler(h slog.Handler) *ContextHandler {
+	return &ContextHandler{Handler: h}
+}
+
+// Handle добавляет идентификатор запроса, если он есть в ctx и не указан в записи явно.
+func (h *ContextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := requestid.FromContext(ctx); id != "" && !hasAttr(record, RequestIDKey) {
+		record.AddAttrs(slog.String(RequestIDKey, id))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+// WithAttrs возвращает обработчик с дополнительными атрибутами.
+func (h *ContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return NewContextHandler(h.Handler.WithAttrs(attrs))
+}
+
+// WithGroup возвращает обработчик, помещающий следующие атрибуты в группу name.
+func (h *ContextHandler) WithGroup(name string) slog.Handler {
+	return NewContextHandler(h.Handler.WithGroup(name))
+}
+
+func hasAttr(record slog.Record, key string) bool {
+	found := false
+	record.Attrs(func(attr slog.Attr) bool {
+		found = attr.Key == key
+		return !found
+	})
+	return found
+}
+
+// PrintfLogger адаптирует slog.Logger к интерфейсу с методами Printf и Fatalf.
+type PrintfLogger struct {
+	Logger *slog.Logger
+	Level  slog.Level // Уровень, с которым записываются сообщения Printf
+}
+
+// Printf записывает форматированное сообщение с уровнем Level.
+func (l PrintfLogger) Printf(format string, args ...any) {
+	l.Logger.Log(context.Background(), l.Level, strings.TrimSpace(fmt.Sprintf(format, args...)))
+}
+
+// Fatalf записывает форматированное сообщение с уровнем error и завершает программу, как log.Fatalf.
+func (l PrintfLogger) Fatalf(format string, args ...any) {
+	l.Logger.Error(strings.TrimSpace(fmt.Sprintf(format, args...)))
+	os.Exit(1)
+}
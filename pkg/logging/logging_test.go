@@ -0,0 +1,87 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/eac0de/getmetrics/pkg/requestid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		level string
+		want  slog.Level
+	}{
+		{level: "", want: slog.LevelInfo},
+		{level: "DEBUG", want: slog.LevelDebug},
+		{level: "warning", want: slog.LevelWarn},
+		{level: "error", want: slog.LevelError},
+	}
+	for _, test := range tests {
+		level, err := ParseLevel(test.level)
+		assert.NoError(t, err)
+		assert.Equal(t, test.want, level)
+	}
+	_, err := ParseLevel("verbose")
+	assert.Error(t, err)
+}
+
+func TestNew(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "warn", FormatJSON)
+	require.NoError(t, err)
+	logger.Info("skipped")
+	logger.Warn("written", "key", "value")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 1)
+	var record map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+	assert.Equal(t, "WARN", record["level"])
+	assert.Equal(t, "written", record["msg"])
+	assert.Equal(t, "value", record["key"])
+
+	buf.Reset()
+	logger, err = New(&buf, "info", FormatText)
+	require.NoError(t, err)
+	logger.Info("written")
+	assert.Contains(t, buf.String(), "msg=written")
+
+	_, err = New(&buf, "info", "xml")
+	assert.Error(t, err)
+}
+
+func TestContextHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "info", FormatJSON)
+	require.NoError(t, err)
+	ctx := requestid.NewContext(context.Background(), "req-1")
+
+	readRecord := func() map[string]any {
+		var record map[string]any
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+		buf.Reset()
+		return record
+	}
+
+	logger.InfoContext(ctx, "with id")
+	assert.Equal(t, "req-1", readRecord()[RequestIDKey])
+
+	logger.With("component", "test").InfoContext(ctx, "derived logger")
+	record := readRecord()
+	assert.Equal(t, "req-1", record[RequestIDKey])
+	assert.Equal(t, "test", record["component"])
+
+	logger.InfoContext(ctx, "explicit id", RequestIDKey, "req-2")
+	assert.Equal(t, 1, strings.Count(buf.String(), RequestIDKey))
+	assert.Equal(t, "req-2", readRecord()[RequestIDKey])
+
+	logger.Info("without context")
+	assert.NotContains(t, readRecord(), RequestIDKey)
+}
@@ -0,0 +1,60 @@
+// Package middlewares предоставляет промежуточные обработчики для расшифровки тела запросов.
+//
+// Этот пакет реализует расшифровку данных, зашифрованных гибридной схемой RSA + AES.
+// Основные функции пакета включают:
+// - Расшифровку тела запроса закрытым ключом RSA до проверки подписи и разжатия.
+package middlewares
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"io"
+	"net/http"
+
+	"github.com/eac0de/getmetrics/pkg/encryptor"
+)
+
+// GetDecryptMiddleware возвращает промежуточный обработчик для расшифровки тела запроса.
+//
+// Принимает закрытый ключ RSA. Если ключ не задан, возвращается промежуточный обработчик,
+// который передает запрос дальше без изменений. В противном случае, если запрос содержит
+// заголовок X-Encrypted-Key, тело запроса расшифровывается и заменяется открытыми данными.
+// Запрос без заголовка передается дальше без изменений, только если requireEncrypted равен false.
+//
+// Если расшифровать тело не удалось или запрос без заголовка не допускается,
+// отправляет ответ с кодом ошибки 400 (Bad Request).
+func GetDecryptMiddleware(privateKey *rsa.PrivateKey, requireEncrypted bool) func(http.Handler) http.Handler {
+	if privateKey == nil {
+		return func(next http.Handler) http.Handler {
+			return next
+		}
+	}
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			encryptedKey := r.Header.Get(encryptor.EncryptedKeyHeader)
+			if encryptedKey == "" {
+				if requireEncrypted {
+					http.Error(w, "Request body must be encrypted", http.StatusBadRequest)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+			bodyBytes, err := io.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, "Unable to read body", http.StatusInternalServerError)
+				return
+			}
+			data, err := encryptor.Decrypt(privateKey, bodyBytes, encryptedKey)
+			if err != nil {
+				http.Error(w, "Unable to decrypt body", http.StatusBadRequest)
+				return
+			}
+			r.Header.Del(encryptor.EncryptedKeyHeader)
+			r.Body = io.NopCloser(bytes.NewBuffer(data))
+			r.ContentLength = int64(len(data))
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,104 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eac0de/getmetrics/pkg/encryptor"
+)
+
+// Тест расшифровки тела запроса
+func TestDecryptMiddleware(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := []byte("test body")
+	ciphertext, encryptedKey, err := encryptor.Encrypt(&privateKey.PublicKey, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Создаем фейковый обработчик, который возвращает полученное тело
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+		w.Write(data)
+	})
+	middleware := GetDecryptMiddleware(privateKey, false)(handler)
+
+	tests := []struct {
+		name         string
+		body         []byte
+		encryptedKey string
+		status       int
+		respBody     string
+	}{
+		{
+			name:         "encrypted body",
+			body:         ciphertext,
+			encryptedKey: encryptedKey,
+			status:       http.StatusOK,
+			respBody:     string(body),
+		},
+		{
+			name:     "plain body",
+			body:     body,
+			status:   http.StatusOK,
+			respBody: string(body),
+		},
+		{
+			name:         "invalid body",
+			body:         body,
+			encryptedKey: encryptedKey,
+			status:       http.StatusBadRequest,
+			respBody:     "Unable to decrypt body\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/test", bytes.NewReader(test.body))
+			if test.encryptedKey != "" {
+				req.Header.Set(encryptor.EncryptedKeyHeader, test.encryptedKey)
+			}
+			rec := httptest.NewRecorder()
+			middleware.ServeHTTP(rec, req)
+			if rec.Code != test.status {
+				t.Errorf("Expected status %d, got %d", test.status, rec.Code)
+			}
+			if rec.Body.String() != test.respBody {
+				t.Errorf("Expected body %q, got %q", test.respBody, rec.Body.String())
+			}
+		})
+	}
+}
+
+// Тест отказа в приеме незашифрованного тела, если шифрование обязательно
+func TestDecryptMiddlewareRequireEncrypted(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	req := httptest.NewRequest(http.MethodPost, "/test", bytes.NewReader([]byte("test body")))
+	rec := httptest.NewRecorder()
+	GetDecryptMiddleware(privateKey, true)(handler).ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+
+	// Без закрытого ключа шифрование не требуется
+	req = httptest.NewRequest(http.MethodPost, "/test", bytes.NewReader([]byte("test body")))
+	rec = httptest.NewRecorder()
+	GetDecryptMiddleware(nil, true)(handler).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+}
@@ -2,14 +2,18 @@
 // включая логирование запросов и ответов в HTTP-сервере.
 //
 // Этот пакет реализует функциональность для логирования информации о HTTP-запросах
-// и ответах, включая метод запроса, статус ответа, путь URL, продолжительность обработки
-// и размер ответа.
+// и ответах, включая метод запроса, статус ответа, путь URL, продолжительность обработки,
+// адрес клиента, идентификатор запроса и размеры запроса и ответа.
 package middlewares
 
 import (
-	"log"
+	"io"
+	"log/slog"
+	"net"
 	"net/http"
 	"time"
+
+	"github.com/eac0de/getmetrics/pkg/requestid"
 )
 
 type (
@@ -24,6 +28,12 @@ type (
 		responseData *responseData // Указатель на responseData.
 		http.ResponseWriter
 	}
+
+	// countingReader подсчитывает количество байт, прочитанных из тела запроса.
+	countingReader struct {
+		io.ReadCloser
+		size int64
+	}
 )
 
 // Write записывает тело ответа и обновляет размер ответа.
@@ -42,22 +52,66 @@ func (lw *logResponseWriter) WriteHeader(statusCode int) {
 	lw.responseData.status = statusCode // Устанавливаем статус-код.
 }
 
-// LoggerMiddleware возвращает промежуточный обработчик для логирования запросов и ответов.
+// Flush отправляет буферизованные данные клиенту, если исходный http.ResponseWriter это поддерживает.
+func (lw *logResponseWriter) Flush() {
+	http.NewResponseController(lw.ResponseWriter).Flush()
+}
+
+// Unwrap возвращает исходный http.ResponseWriter для http.ResponseController.
+func (lw *logResponseWriter) Unwrap() http.ResponseWriter {
+	return lw.ResponseWriter
+}
+
+// Read читает тело запроса и увеличивает счетчик прочитанных байт.
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.ReadCloser.Read(p)
+	cr.size += int64(n)
+	return n, err
+}
+
+// GetLoggerMiddleware возвращает промежуточный обработчик для логирования запросов и ответов.
 //
-// Логирует метод запроса, статус-код ответа, путь URL, продолжительность обработки
-// и размер ответа. Используйте этот middleware для отслеживания производительности
-// и анализа трафика вашего HTTP-сервера.
-func LoggerMiddleware(h http.Handler) http.Handler {
-	fn := func(w http.ResponseWriter, r *http.Request) {
-		var (
-			respData = responseData{0, 0} // Инициализируем данные о ответе.
-			lw       = logResponseWriter{responseData: &respData, ResponseWriter: w}
-			duration time.Duration
-		)
-		start := time.Now()          // Запоминаем время начала обработки.
-		h.ServeHTTP(&lw, r)          // Обрабатываем запрос.
-		duration = time.Since(start) // Вычисляем продолжительность обработки.
-		log.Printf("%s %v %s %s %v bytes", r.Method, lw.responseData.status, r.URL.Path, duration, lw.responseData.size)
+// Для каждого запроса записывает в logger одну запись с полями method, path, status,
+// duration, remote_ip, request_id, bytes_in и bytes_out. Размеры считаются по телу запроса
+// и ответа в том виде, в котором они передаются по сети, то есть до распаковки и после сжатия.
+// Идентификатор запроса берется из контекста, поэтому RequestIDMiddleware должен стоять раньше.
+func GetLoggerMiddleware(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			var (
+				respData = responseData{0, http.StatusOK} // Инициализируем данные о ответе.
+				lw       = logResponseWriter{responseData: &respData, ResponseWriter: w}
+				body     = &countingReader{ReadCloser: r.Body}
+			)
+			if r.Body != nil {
+				r.Body = body
+			}
+			start := time.Now()           // Запоминаем время начала обработки.
+			h.ServeHTTP(&lw, r)           // Обрабатываем запрос.
+			duration := time.Since(start) // Вычисляем продолжительность обработки.
+			logger.LogAttrs(r.Context(), slog.LevelInfo, "HTTP request",
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", respData.status),
+				slog.Duration("duration", duration),
+				slog.String("remote_ip", remoteIP(r)),
+				slog.String("request_id", requestid.FromContext(r.Context())),
+				slog.Int64("bytes_in", body.size),
+				slog.Int("bytes_out", respData.size),
+			)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// remoteIP возвращает адрес клиента из заголовка X-Real-IP, а если его нет - адрес соединения.
+func remoteIP(r *http.Request) string {
+	if ip := r.Header.Get("X-Real-IP"); ip != "" {
+		return ip
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
 	}
-	return http.HandlerFunc(fn)
+	return host
 }
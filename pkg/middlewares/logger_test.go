@@ -2,124 +2,122 @@ package middlewares
 
 import (
 	"bytes"
-	"log"
+	"encoding/json"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
-// Тестирует LoggerMiddleware для успешного запроса.
+// newTestLogger создает логгер, записывающий JSON в буфер.
+func newTestLogger() (*slog.Logger, *bytes.Buffer) {
+	var buf bytes.Buffer
+	return slog.New(slog.NewJSONHandler(&buf, nil)), &buf
+}
+
+// parseLogRecord разбирает единственную запись лога из буфера.
+func parseLogRecord(t *testing.T, buf *bytes.Buffer) map[string]any {
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 1)
+	var record map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+	return record
+}
+
+// Тестирует GetLoggerMiddleware для успешного запроса.
 func TestLoggerMiddleware_Success(t *testing.T) {
-	// Создаем фейковый логгер для захвата логов.
-	var logBuf bytes.Buffer
-	log.SetOutput(&logBuf)
+	logger, logBuf := newTestLogger()
 
-	// Создаем фейковый обработчик, который будет возвращать 200 OK.
+	// Создаем фейковый обработчик, который читает тело и возвращает 200 OK.
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		buf.ReadFrom(r.Body)
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("test response"))
 	})
 
 	// Создаем тестовый HTTP-запрос.
-	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req := httptest.NewRequest(http.MethodPost, "/test", strings.NewReader("request"))
+	req.RemoteAddr = "192.168.1.10:41000"
+	req.Header.Set("X-Request-ID", "req-1")
 	rec := httptest.NewRecorder()
 
-	// Оборачиваем обработчик в наш middleware.
-	middleware := LoggerMiddleware(handler)
-
 	// Выполняем запрос через middleware.
-	middleware.ServeHTTP(rec, req)
-
-	// Проверяем, что ответ имеет правильный статус.
-	if status := rec.Code; status != http.StatusOK {
-		t.Errorf("Expected status %d, got %d", http.StatusOK, status)
-	}
-
-	// Проверяем, что тело ответа записано корректно.
-	expectedBody := "test response"
-	if rec.Body.String() != expectedBody {
-		t.Errorf("Expected body %q, got %q", expectedBody, rec.Body.String())
-	}
-
-	// Проверяем содержимое логов.
-	logOutput := logBuf.String()
-	if !strings.Contains(logOutput, "GET 200 /test") {
-		t.Errorf("Log output does not contain expected data: %s", logOutput)
-	}
-	if !strings.Contains(logOutput, "bytes") {
-		t.Errorf("Log output does not contain byte size: %s", logOutput)
-	}
+	RequestIDMiddleware(GetLoggerMiddleware(logger)(handler)).ServeHTTP(rec, req)
+
+	// Проверяем, что ответ не изменился.
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "test response", rec.Body.String())
+
+	// Проверяем поля записи лога.
+	record := parseLogRecord(t, logBuf)
+	assert.Equal(t, "INFO", record["level"])
+	assert.Equal(t, "POST", record["method"])
+	assert.Equal(t, "/test", record["path"])
+	assert.Equal(t, float64(http.StatusOK), record["status"])
+	assert.Equal(t, "192.168.1.10", record["remote_ip"])
+	assert.Equal(t, "req-1", record["request_id"])
+	assert.Equal(t, float64(len("request")), record["bytes_in"])
+	assert.Equal(t, float64(len("test response")), record["bytes_out"])
 }
 
-// Тестирует LoggerMiddleware для запроса с ошибкой (например, 404).
+// Тестирует GetLoggerMiddleware для запроса с ошибкой (например, 404).
 func TestLoggerMiddleware_NotFound(t *testing.T) {
-	// Создаем фейковый логгер для захвата логов.
-	var logBuf bytes.Buffer
-	log.SetOutput(&logBuf)
+	logger, logBuf := newTestLogger()
 
 	// Создаем фейковый обработчик, который будет возвращать 404 Not Found.
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		http.NotFound(w, r)
 	})
 
-	// Создаем тестовый HTTP-запрос.
 	req := httptest.NewRequest(http.MethodGet, "/not-found", nil)
+	req.Header.Set("X-Real-IP", "10.0.0.5")
 	rec := httptest.NewRecorder()
+	GetLoggerMiddleware(logger)(handler).ServeHTTP(rec, req)
 
-	// Оборачиваем обработчик в наш middleware.
-	middleware := LoggerMiddleware(handler)
-
-	// Выполняем запрос через middleware.
-	middleware.ServeHTTP(rec, req)
-
-	// Проверяем, что ответ имеет правильный статус.
-	if status := rec.Code; status != http.StatusNotFound {
-		t.Errorf("Expected status %d, got %d", http.StatusNotFound, status)
-	}
-
-	// Проверяем содержимое логов.
-	logOutput := logBuf.String()
-	if !strings.Contains(logOutput, "GET 404 /not-found") {
-		t.Errorf("Log output does not contain expected data: %s", logOutput)
-	}
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	record := parseLogRecord(t, logBuf)
+	assert.Equal(t, float64(http.StatusNotFound), record["status"])
+	assert.Equal(t, "/not-found", record["path"])
+	assert.Equal(t, "10.0.0.5", record["remote_ip"])
 }
 
-// Тестирует LoggerMiddleware для медленного запроса (проверка продолжительности).
+// Тестирует GetLoggerMiddleware для медленного запроса (проверка продолжительности).
 func TestLoggerMiddleware_SlowRequest(t *testing.T) {
-	// Создаем фейковый логгер для захвата логов.
-	var logBuf bytes.Buffer
-	log.SetOutput(&logBuf)
+	logger, logBuf := newTestLogger()
 
 	// Создаем фейковый обработчик, который будет обрабатывать запрос с задержкой.
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		time.Sleep(100 * time.Millisecond)
-		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("slow response"))
 	})
 
-	// Создаем тестовый HTTP-запрос.
 	req := httptest.NewRequest(http.MethodGet, "/slow", nil)
 	rec := httptest.NewRecorder()
+	GetLoggerMiddleware(logger)(handler).ServeHTTP(rec, req)
 
-	// Оборачиваем обработчик в наш middleware.
-	middleware := LoggerMiddleware(handler)
+	// Статус по умолчанию - 200, продолжительность записывается в наносекундах.
+	record := parseLogRecord(t, logBuf)
+	assert.Equal(t, float64(http.StatusOK), record["status"])
+	assert.GreaterOrEqual(t, record["duration"], float64(100*time.Millisecond))
+}
 
-	// Выполняем запрос через middleware.
-	middleware.ServeHTTP(rec, req)
-
-	// Проверяем, что ответ имеет правильный статус.
-	if status := rec.Code; status != http.StatusOK {
-		t.Errorf("Expected status %d, got %d", http.StatusOK, status)
-	}
-
-	// Проверяем содержимое логов.
-	logOutput := logBuf.String()
-	if !strings.Contains(logOutput, "GET 200 /slow") {
-		t.Errorf("Log output does not contain expected data: %s", logOutput)
-	}
-	if !strings.Contains(logOutput, "bytes") {
-		t.Errorf("Log output does not contain byte size: %s", logOutput)
-	}
+// Тестирует, что GetLoggerMiddleware не мешает обработчику отправлять данные частями.
+func TestLoggerMiddleware_Flush(t *testing.T) {
+	logger, logBuf := newTestLogger()
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("data: 1\n\n"))
+		assert.NoError(t, http.NewResponseController(w).Flush())
+	})
+	rec := httptest.NewRecorder()
+	GetLoggerMiddleware(logger)(handler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/stream", nil))
+
+	assert.True(t, rec.Flushed)
+	record := parseLogRecord(t, logBuf)
+	assert.Equal(t, float64(9), record["bytes_out"])
 }
@@ -0,0 +1,25 @@
+// Package middlewares предоставляет промежуточные обработчики для назначения идентификатора запроса.
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/eac0de/getmetrics/pkg/requestid"
+)
+
+// RequestIDMiddleware назначает запросу идентификатор.
+//
+// Идентификатор берется из заголовка X-Request-ID, а если заголовка нет или его значение
+// недопустимо, создается новый. Идентификатор сохраняется в контексте запроса
+// и возвращается клиенту в заголовке X-Request-ID ответа.
+func RequestIDMiddleware(h http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestid.Header)
+		if !requestid.Valid(id) {
+			id = requestid.New()
+		}
+		w.Header().Set(requestid.Header, id)
+		h.ServeHTTP(w, r.WithContext(requestid.NewContext(r.Context(), id)))
+	}
+	return http.HandlerFunc(fn)
+}
@@ -0,0 +1,45 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eac0de/getmetrics/pkg/requestid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	var gotID string
+	handler := RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotID = requestid.FromContext(r.Context())
+	}))
+
+	tests := []struct {
+		name     string
+		header   string
+		generate bool
+	}{
+		{name: "client id", header: "req-1"},
+		{name: "missing id", header: "", generate: true},
+		{name: "invalid id", header: "bad id\n", generate: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if test.header != "" {
+				req.Header.Set(requestid.Header, test.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if test.generate {
+				assert.True(t, requestid.Valid(gotID))
+				assert.NotEqual(t, test.header, gotID)
+			} else {
+				assert.Equal(t, test.header, gotID)
+			}
+			assert.Equal(t, gotID, rec.Header().Get(requestid.Header))
+		})
+	}
+}
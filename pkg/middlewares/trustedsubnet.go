@@ -0,0 +1,36 @@
+// Package middlewares предоставляет промежуточные обработчики для ограничения доступа по адресу клиента.
+//
+// Этот пакет реализует проверку IP-адреса клиента из заголовка X-Real-IP на принадлежность
+// доверенной подсети.
+package middlewares
+
+import (
+	"net"
+	"net/http"
+)
+
+// GetTrustedSubnetMiddleware возвращает промежуточный обработчик для проверки адреса клиента.
+//
+// Принимает доверенную подсеть. Если подсеть не задана, возвращается промежуточный обработчик,
+// который не выполняет проверку. В противном случае проверяет, что заголовок X-Real-IP
+// содержит IP-адрес из доверенной подсети.
+//
+// Если заголовок отсутствует или адрес не входит в подсеть, отправляет ответ с кодом ошибки 403 (Forbidden).
+func GetTrustedSubnetMiddleware(trustedSubnet *net.IPNet) func(http.Handler) http.Handler {
+	if trustedSubnet == nil {
+		return func(next http.Handler) http.Handler {
+			return next
+		}
+	}
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ip := net.ParseIP(r.Header.Get("X-Real-IP"))
+			if ip == nil || !trustedSubnet.Contains(ip) {
+				http.Error(w, "Client address is not trusted", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
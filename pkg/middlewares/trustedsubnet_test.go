@@ -0,0 +1,69 @@
+package middlewares
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Тест проверки адреса клиента
+func TestTrustedSubnetMiddleware(t *testing.T) {
+	_, trustedSubnet, err := net.ParseCIDR("192.168.1.0/24")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Создаем фейковый обработчик, который возвращает статус 200
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name          string
+		trustedSubnet *net.IPNet
+		realIP        string
+		status        int
+	}{
+		{
+			name:          "trusted address",
+			trustedSubnet: trustedSubnet,
+			realIP:        "192.168.1.10",
+			status:        http.StatusOK,
+		},
+		{
+			name:          "untrusted address",
+			trustedSubnet: trustedSubnet,
+			realIP:        "10.0.0.1",
+			status:        http.StatusForbidden,
+		},
+		{
+			name:          "missing header",
+			trustedSubnet: trustedSubnet,
+			status:        http.StatusForbidden,
+		},
+		{
+			name:          "invalid header",
+			trustedSubnet: trustedSubnet,
+			realIP:        "not an ip",
+			status:        http.StatusForbidden,
+		},
+		{
+			name:   "no trusted subnet",
+			status: http.StatusOK,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/update/", nil)
+			if test.realIP != "" {
+				req.Header.Set("X-Real-IP", test.realIP)
+			}
+			rec := httptest.NewRecorder()
+			GetTrustedSubnetMiddleware(test.trustedSubnet)(handler).ServeHTTP(rec, req)
+			if rec.Code != test.status {
+				t.Errorf("Expected status %d, got %d", test.status, rec.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+// Package remotewrite предоставляет разбор сообщений Prometheus remote_write 1.0.
+//
+// Пакет реализует кодирование и декодирование сообщения WriteRequest из схемы prometheus/prompb
+// в части, необходимой для приема значений: ряды с метками и выборками. Нативные гистограммы
+// только подсчитываются, exemplars и метаданные пропускаются.
+package remotewrite
+
+import (
+	"fmt"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Номера полей схемы prometheus/prompb.
+const (
+	writeRequestTimeseries = 1
+
+	timeSeriesLabels     = 1
+	timeSeriesSamples    = 2
+	timeSeriesHistograms = 4
+
+	labelName  = 1
+	labelValue = 2
+
+	sampleValue     = 1
+	sampleTimestamp = 2
+)
+
+// WriteRequest - сообщение remote_write.
+type WriteRequest struct {
+	Timeseries []TimeSeries
+}
+
+// TimeSeries - ряд с метками и выборками.
+type TimeSeries struct {
+	Labels  []Label
+	Samples []Sample
+	// Histograms - количество нативных гистограмм ряда, их значения не разбираются.
+	Histograms int
+}
+
+// Label - метка ряда.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample - значение ряда с временной меткой в миллисекундах.
+type Sample struct {
+	Value     float64
+	Timestamp int64
+}
+
+// Unmarshal декодирует WriteRequest из формата protobuf. Неизвестные поля пропускаются.
+func Unmarshal(data []byte) (*WriteRequest, error) {
+	req := &WriteRequest{}
+	err := consumeFields(data, func(num protowire.Number, typ protowire.Type, value []byte) error {
+		if num != writeRequestTimeseries {
+			return nil
+		}
+		b, err := consumeBytes(typ, value)
+		if err != nil {
+			return err
+		}
+		ts, err := unmarshalTimeSeries(b)
+		if err != nil {
+			return err
+		}
+		req.Timeseries = append(req.Timeseries, ts)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// Marshal кодирует WriteRequest в формат protobuf.
+func (r *WriteRequest) Marshal() []byte {
+	var data []byte
+	for _, ts := range r.Timeseries {
+		var b []byte
+		for _, label := range ts.Labels {
+			var l []byte
+			l = protowire.AppendTag(l, labelName, protowire.BytesType)
+			l = protowire.AppendString(l, label.Name)
+			l = protowire.AppendTag(l, labelValue, protowire.BytesType)
+			l = protowire.AppendString(l, label.Value)
+			b = protowire.AppendTag(b, timeSeriesLabels, protowire.BytesType)
+			b = protowire.AppendBytes(b, l)
+		}
+		for _, sample := range ts.Samples {
+			var s []byte
+			s = protowire.AppendTag(s, sampleValue, protowire.Fixed64Type)
+			s = protowire.AppendFixed64(s, math.Float64bits(sample.Value))
+			s = protowire.AppendTag(s, sampleTimestamp, protowire.VarintType)
+			s = protowire.AppendVarint(s, uint64(sample.Timestamp))
+			b = protowire.AppendTag(b, timeSeriesSamples, protowire.BytesType)
+			b = protowire.AppendBytes(b, s)
+		}
+		data = protowire.AppendTag(data, writeRequestTimeseries, protowire.BytesType)
+		data = protowire.AppendBytes(data, b)
+	}
+	return data
+}
+
+// Name возвращает значение метки __name__ или пустую строку.
+func (ts *TimeSeries) Name() string {
+	for _, label := range ts.Labels {
+		if label.Name == "__name__" {
+			return label.Value
+		}
+	}
+	return ""
+}
+
+func unmarshalTimeSeries(data []byte) (TimeSeries, error) {
+	var ts TimeSeries
+	err := consumeFields(data, func(num protowire.Number, typ protowire.Type, value []byte) error {
+		switch num {
+		case timeSeriesLabels:
+			b, err := consumeBytes(typ, value)
+			if err != nil {
+				return err
+			}
+			label, err := unmarshalLabel(b)
+			if err != nil {
+				return err
+			}
+			ts.Labels = append(ts.Labels, label)
+		case timeSeriesSamples:
+			b, err := consumeBytes(typ, value)
+			if err != nil {
+				return err
+			}
+			sample, err := unmarshalSample(b)
+			if err != nil {
+				return err
+			}
+			ts.Samples = append(ts.Samples, sample)
+		case timeSeriesHistograms:
+			ts.Histograms++
+		}
+		return nil
+	})
+	return ts, err
+}
+
+func unmarshalLabel(data []byte) (Label, error) {
+	var label Label
+	err := consumeFields(data, func(num protowire.Number, typ protowire.Type, value []byte) error {
+		if num != labelName && num != labelValue {
+			return nil
+		}
+		b, err := consumeBytes(typ, value)
+		if err != nil {
+			return err
+		}
+		if num == labelName {
+			label.Name = string(b)
+		} else {
+			label.Value = string(b)
+		}
+		return nil
+	})
+	return label, err
+}
+
+func unmarshalSample(data []byte) (Sample, error) {
+	var sample Sample
+	err := consumeFields(data, func(num protowire.Number, typ protowire.Type, value []byte) error {
+		switch num {
+		case sampleValue:
+			if typ != protowire.Fixed64Type {
+				return fmt.Errorf("sample value has wire type %d", typ)
+			}
+			v, _ := protowire.ConsumeFixed64(value)
+			sample.Value = math.Float64frombits(v)
+		case sampleTimestamp:
+			if typ != protowire.VarintType {
+				return fmt.Errorf("sample timestamp has wire type %d", typ)
+			}
+			v, _ := protowire.ConsumeVarint(value)
+			sample.Timestamp = int64(v)
+		}
+		return nil
+	})
+	return sample, err
+}
+
+// consumeFields вызывает fn для каждого поля сообщения. value содержит закодированное значение поля.
+func consumeFields(data []byte, fn func(num protowire.Number, typ protowire.Type, value []byte) error) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		m := protowire.ConsumeFieldValue(num, typ, data)
+		if m < 0 {
+			return protowire.ParseError(m)
+		}
+		err := fn(num, typ, data[:m])
+		if err != nil {
+			return err
+		}
+		data = data[m:]
+	}
+	return nil
+}
+
+func consumeBytes(typ protowire.Type, value []byte) ([]byte, error) {
+	if typ != protowire.BytesType {
+		return nil, fmt.Errorf("expected length-delimited field, got wire type %d", typ)
+	}
+	b, _ := protowire.ConsumeBytes(value)
+	return b, nil
+}
@@ -0,0 +1,66 @@
+package remotewrite
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestMarshalUnmarshal(t *testing.T) {
+	req := &WriteRequest{Timeseries: []TimeSeries{
+		{
+			Labels:  []Label{{Name: "__name__", Value: "http_requests_total"}, {Name: "code", Value: "200"}},
+			Samples: []Sample{{Value: 10, Timestamp: 1700000000000}, {Value: 12, Timestamp: 1700000015000}},
+		},
+		{
+			Labels:  []Label{{Name: "__name__", Value: "temperature"}},
+			Samples: []Sample{{Value: -1.5, Timestamp: -1}},
+		},
+	}}
+	got, err := Unmarshal(req.Marshal())
+	require.NoError(t, err)
+	assert.Equal(t, req, got)
+	assert.Equal(t, "http_requests_total", got.Timeseries[0].Name())
+}
+
+func TestUnmarshalSkipsUnknownFields(t *testing.T) {
+	data := (&WriteRequest{Timeseries: []TimeSeries{{
+		Labels:  []Label{{Name: "__name__", Value: "up"}},
+		Samples: []Sample{{Value: 1, Timestamp: 1}},
+	}}}).Marshal()
+	// Метаданные (поле 3) и нативная гистограмма ряда (поле 4)
+	data = protowire.AppendTag(data, 3, protowire.BytesType)
+	data = protowire.AppendBytes(data, []byte{})
+	var ts []byte
+	ts = protowire.AppendTag(ts, timeSeriesHistograms, protowire.BytesType)
+	ts = protowire.AppendBytes(ts, []byte{})
+	data = protowire.AppendTag(data, writeRequestTimeseries, protowire.BytesType)
+	data = protowire.AppendBytes(data, ts)
+
+	req, err := Unmarshal(data)
+	require.NoError(t, err)
+	require.Len(t, req.Timeseries, 2)
+	assert.Equal(t, "up", req.Timeseries[0].Name())
+	assert.Equal(t, 1, req.Timeseries[1].Histograms)
+	assert.Empty(t, req.Timeseries[1].Name())
+}
+
+func TestUnmarshalInvalid(t *testing.T) {
+	_, err := Unmarshal([]byte{0x0a, 0x05, 0x01})
+	assert.Error(t, err)
+
+	var sample []byte
+	sample = protowire.AppendTag(sample, sampleValue, protowire.VarintType)
+	sample = protowire.AppendVarint(sample, math.MaxUint32)
+	var ts []byte
+	ts = protowire.AppendTag(ts, timeSeriesSamples, protowire.BytesType)
+	ts = protowire.AppendBytes(ts, sample)
+	var data []byte
+	data = protowire.AppendTag(data, writeRequestTimeseries, protowire.BytesType)
+	data = protowire.AppendBytes(data, ts)
+	_, err = Unmarshal(data)
+	assert.Error(t, err)
+}
@@ -0,0 +1,57 @@
+// Package requestid предоставляет идентификатор запроса, который связывает записи лога
+// агента и сервера, относящиеся к одному отчету.
+//
+// Идентификатор передается в HTTP-заголовке X-Request-ID или в метаданных gRPC x-request-id
+// и хранится в контексте запроса.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+const (
+	// Header - HTTP-заголовок с идентификатором запроса.
+	Header = "X-Request-ID"
+	// MetadataKey - ключ метаданных gRPC с идентификатором запроса.
+	MetadataKey = "x-request-id"
+	// maxLength - максимальная длина идентификатора, принимаемого от клиента.
+	maxLength = 128
+)
+
+type contextKey struct{}
+
+// New создает случайный идентификатор запроса.
+func New() string {
+	b := make([]byte, 16)
+	// crypto/rand.Read не возвращает ошибок на поддерживаемых платформах
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Valid проверяет идентификатор, полученный от клиента. Допускаются непустые строки
+// длиной до 128 символов из печатных символов ASCII без пробелов, чтобы идентификатор
+// нельзя было использовать для подделки записей лога.
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// NewContext возвращает копию ctx с идентификатором запроса.
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext возвращает идентификатор запроса из ctx или пустую строку.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
@@ -0,0 +1,17 @@
+package retry
+
+import (
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// IsRetriableGRPC определяет, имеет ли смысл повторить вызов gRPC.
+//
+// Повторяются вызовы, завершившиеся с кодами Unavailable, ResourceExhausted и Aborted.
+func IsRetriableGRPC(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.Aborted:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+)
+
+// StatusError описывает неуспешный HTTP-ответ сервера.
+type StatusError struct {
+	StatusCode int    // Код HTTP-статуса ответа
+	Body       string // Тело ответа
+}
+
+// Error возвращает описание ошибки с кодом статуса и телом ответа.
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d: %s", e.StatusCode, e.Body)
+}
+
+// IsRetriableHTTP определяет, имеет ли смысл повторить HTTP-запрос.
+//
+// Повторяются таймауты, ошибки соединения и ответы со статусами 5xx и 429 (Too Many Requests).
+func IsRetriableHTTP(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= http.StatusInternalServerError ||
+			statusErr.StatusCode == http.StatusTooManyRequests
+	}
+	return isNetworkError(err)
+}
+
+// isNetworkError сообщает, является ли err временной сетевой ошибкой: таймаутом, ошибкой
+// соединения или обрывом соединения. Ошибки TLS, ненайденные в DNS имена и некорректные адреса
+// не повторяются.
+func isNetworkError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,32 @@
+package retry
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// IsRetriablePg определяет, имеет ли смысл повторить операцию с PostgreSQL.
+//
+// Повторяются ошибки соединения, ошибки сериализации и взаимоблокировки, нехватка ресурсов
+// и остановка сервера, а также ошибки, которые драйвер помечает как безопасные для повтора.
+func IsRetriablePg(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgerrcode.IsConnectionException(pgErr.Code) ||
+			pgerrcode.IsTransactionRollback(pgErr.Code) ||
+			pgerrcode.IsInsufficientResources(pgErr.Code) ||
+			pgErr.Code == pgerrcode.AdminShutdown ||
+			pgErr.Code == pgerrcode.CrashShutdown ||
+			pgErr.Code == pgerrcode.CannotConnectNow
+	}
+	if pgconn.SafeToRetry(err) || pgconn.Timeout(err) {
+		return true
+	}
+	return isNetworkError(err)
+}
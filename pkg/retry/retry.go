@@ -0,0 +1,70 @@
+// Package retry предоставляет политику повторных попыток с экспоненциальной задержкой и джиттером.
+//
+// Этот пакет реализует повтор операций, завершившихся временной ошибкой, и классификацию таких ошибок.
+// Основные функции пакета включают:
+// - Выполнение операции с повторами согласно политике.
+// - Вычисление задержки перед очередной попыткой.
+// - Определение временных ошибок HTTP-клиента и PostgreSQL.
+package retry
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// Policy описывает политику повторных попыток.
+//
+// Нулевое значение Policy выполняет операцию ровно один раз.
+type Policy struct {
+	Attempts  int           // Максимальное число попыток, включая первую
+	BaseDelay time.Duration // Задержка перед второй попыткой
+	MaxDelay  time.Duration // Максимальная задержка между попытками
+	Jitter    float64       // Доля задержки от 0 до 1, на которую задержка случайно уменьшается
+}
+
+// Do выполняет fn, повторяя вызов, пока fn возвращает ошибку, для которой isRetriable
+// возвращает true, и не исчерпано число попыток.
+//
+// Возвращает последнюю ошибку fn или ошибку контекста, если он был отменен во время ожидания.
+func (p Policy) Do(ctx context.Context, fn func() error, isRetriable func(error) bool) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = fn()
+		if err == nil || attempt+1 >= p.Attempts || !isRetriable(err) {
+			return err
+		}
+		timer := time.NewTimer(p.Delay(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Delay возвращает задержку перед попыткой с номером attempt+1.
+//
+// Задержка растет как BaseDelay * 2^attempt, ограничивается MaxDelay
+// и случайно уменьшается не более чем на долю Jitter.
+func (p Policy) Delay(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 0; i < attempt; i++ {
+		delay *= 2
+		if p.MaxDelay > 0 && delay >= p.MaxDelay {
+			break
+		}
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if p.Jitter > 0 {
+		jitter := p.Jitter
+		if jitter > 1 {
+			jitter = 1
+		}
+		delay -= time.Duration(rand.Float64() * jitter * float64(delay))
+	}
+	return delay
+}
@@ -0,0 +1,140 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDo(t *testing.T) {
+	errTemporary := errors.New("temporary")
+	errPermanent := errors.New("permanent")
+	isRetriable := func(err error) bool { return errors.Is(err, errTemporary) }
+	policy := Policy{Attempts: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond}
+
+	tests := []struct {
+		name      string
+		errs      []error
+		wantCalls int
+		wantErr   error
+	}{
+		{
+			name:      "success",
+			errs:      []error{nil},
+			wantCalls: 1,
+		},
+		{
+			name:      "success after retries",
+			errs:      []error{errTemporary, errTemporary, nil},
+			wantCalls: 3,
+		},
+		{
+			name:      "attempts exhausted",
+			errs:      []error{errTemporary, errTemporary, errTemporary, nil},
+			wantCalls: 3,
+			wantErr:   errTemporary,
+		},
+		{
+			name:      "permanent error",
+			errs:      []error{errPermanent, nil},
+			wantCalls: 1,
+			wantErr:   errPermanent,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			calls := 0
+			err := policy.Do(context.Background(), func() error {
+				err := test.errs[calls]
+				calls++
+				return err
+			}, isRetriable)
+			assert.Equal(t, test.wantCalls, calls)
+			assert.Equal(t, test.wantErr, err)
+		})
+	}
+	t.Run("zero policy", func(t *testing.T) {
+		calls := 0
+		err := Policy{}.Do(context.Background(), func() error {
+			calls++
+			return errTemporary
+		}, isRetriable)
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, errTemporary, err)
+	})
+	t.Run("canceled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := Policy{Attempts: 3, BaseDelay: time.Hour}.Do(ctx, func() error {
+			return errTemporary
+		}, isRetriable)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestDelay(t *testing.T) {
+	policy := Policy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+	assert.Equal(t, time.Second, policy.Delay(0))
+	assert.Equal(t, 2*time.Second, policy.Delay(1))
+	assert.Equal(t, 4*time.Second, policy.Delay(2))
+	assert.Equal(t, 5*time.Second, policy.Delay(3))
+	assert.Equal(t, 5*time.Second, policy.Delay(100))
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		delay := policy.Delay(1)
+		assert.GreaterOrEqual(t, delay, time.Second)
+		assert.LessOrEqual(t, delay, 2*time.Second)
+	}
+}
+
+func TestIsRetriableHTTP(t *testing.T) {
+	assert.True(t, IsRetriableHTTP(&StatusError{StatusCode: http.StatusBadGateway}))
+	assert.True(t, IsRetriableHTTP(&StatusError{StatusCode: http.StatusTooManyRequests}))
+	assert.False(t, IsRetriableHTTP(&StatusError{StatusCode: http.StatusBadRequest}))
+	assert.True(t, IsRetriableHTTP(fmt.Errorf("post: %w", syscall.ECONNREFUSED)))
+	assert.False(t, IsRetriableHTTP(context.Canceled))
+	assert.False(t, IsRetriableHTTP(errors.New("invalid payload")))
+
+	// Ошибки TLS и некорректный адрес оборачиваются в *url.Error, но не повторяются
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	_, err := http.Get(server.URL)
+	assert.Error(t, err)
+	assert.False(t, IsRetriableHTTP(err))
+	_, err = http.Get("ftp://" + server.Listener.Addr().String())
+	assert.Error(t, err)
+	assert.False(t, IsRetriableHTTP(err))
+
+	// Ошибки подключения, которые не исчезнут при повторе, не повторяются
+	dnsErr := &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: "metrics.invalid", IsNotFound: true}}
+	assert.False(t, IsRetriableHTTP(fmt.Errorf("post: %w", dnsErr)))
+	_, err = net.Dial("tcp", "127.0.0.1:port")
+	assert.Error(t, err)
+	assert.False(t, IsRetriableHTTP(err))
+
+	// Отказ в соединении повторяется
+	addr := server.Listener.Addr().String()
+	server.Close()
+	_, err = http.Get("http://" + addr)
+	assert.Error(t, err)
+	assert.True(t, IsRetriableHTTP(err))
+}
+
+func TestIsRetriablePg(t *testing.T) {
+	assert.True(t, IsRetriablePg(&pgconn.PgError{Code: "08006"}))
+	assert.True(t, IsRetriablePg(&pgconn.PgError{Code: "40001"}))
+	assert.True(t, IsRetriablePg(&pgconn.PgError{Code: "57P01"}))
+	assert.False(t, IsRetriablePg(&pgconn.PgError{Code: "23505"}))
+	assert.True(t, IsRetriablePg(fmt.Errorf("exec: %w", syscall.ECONNRESET)))
+	assert.False(t, IsRetriablePg(errors.New("syntax error")))
+}
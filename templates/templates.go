@@ -0,0 +1,9 @@
+// Package templates содержит HTML-шаблоны сервера, встроенные в исполняемый файл.
+package templates
+
+import "embed"
+
+// FS - файловая система с шаблонами.
+//
+//go:embed *.html
+var FS embed.FS